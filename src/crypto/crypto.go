@@ -6,16 +6,38 @@ import (
 	"crypto/cipher"
 	"crypto/md5"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
 	"io"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/curve25519"
 )
 
 const (
 
 	// DefaultCipherKeyLength is the required number of bytes for a cipher key.
 	DefaultCipherKeyLength = 32
+
+	// DefaultKDF is the key derivation function used to produce cipher keys from passwords.
+	DefaultKDF = "argon2id"
+
+	// DefaultKDFTime is the number of Argon2id passes over the memory.
+	DefaultKDFTime = 1
+
+	// DefaultKDFMemory is the memory used by Argon2id (in KiB).
+	DefaultKDFMemory = 64 * 1024
+
+	// DefaultKDFThreads is the number of threads used by Argon2id.
+	DefaultKDFThreads = 4
+
+	// DefaultSaltLength is the number of random bytes used to salt a key derivation.
+	DefaultSaltLength = 16
+
+	// DefaultRecipientKeyLength is the required number of bytes for an X25519 recipient key.
+	DefaultRecipientKeyLength = curve25519.ScalarSize
 )
 
 var (
@@ -26,6 +48,18 @@ var (
 	// ErrCipherKeyLength is issued when a key is not long enough.
 	ErrCipherKeyLength = fmt.Errorf("cipher key must be %d bytes", DefaultCipherKeyLength)
 
+	// ErrCiphertext is issued when data to decrypt is not valid ciphertext.
+	ErrCiphertext = fmt.Errorf("data is not valid ciphertext")
+
+	// ErrRecipientKey is issued when a recipient key is not a valid X25519 key.
+	ErrRecipientKey = fmt.Errorf("recipient key must be a base64 encoded %d byte X25519 key", DefaultRecipientKeyLength)
+
+	// ErrWrappedKey is issued when a wrapped data key can't be unwrapped.
+	ErrWrappedKey = fmt.Errorf("data key is not wrapped for this recipient key")
+
+	// ErrKDF is issued when a key derivation function is not supported.
+	ErrKDF = fmt.Errorf("unsupported key derivation function")
+
 	// ErrCipherPassword is issued when a cipher key cannot be generated from the provided password.
 	ErrCipherPassword = fmt.Errorf("cannot generate cipher key from provided password")
 )
@@ -42,8 +76,66 @@ func CipherKeyCheck(key []byte) error {
 	return nil
 }
 
+// KDFParams are the parameters used to derive a
+// cipher key from a password. They are not secret,
+// so can be stored alongside the encrypted data.
+type KDFParams struct {
+	Algorithm string `json:"algorithm"` // the key derivation function (see DefaultKDF)
+	Version   int    `json:"version"`   // the version of the key derivation function
+	Salt      []byte `json:"salt"`      // the random salt
+	Time      uint32 `json:"time"`      // the number of passes over the memory
+	Memory    uint32 `json:"memory"`    // the memory used (in KiB)
+	Threads   uint8  `json:"threads"`   // the number of threads used
+}
+
+// NewKDFParams will produce a set of key derivation
+// parameters using the default key derivation function
+// and a new random salt.
+func NewKDFParams() (*KDFParams, error) {
+	salt := make([]byte, DefaultSaltLength)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	return &KDFParams{
+		Algorithm: DefaultKDF,
+		Version:   argon2.Version,
+		Salt:      salt,
+		Time:      DefaultKDFTime,
+		Memory:    DefaultKDFMemory,
+		Threads:   DefaultKDFThreads,
+	}, nil
+}
+
+// DeriveCipherKey will take a password and produce a
+// 32 byte cipher key using the provided key derivation
+// parameters.
+//
+// Only the default parameters (see NewKDFParams) are
+// accepted, as the parameters are read from project
+// metadata which could be written by anyone.
+func DeriveCipherKey(password string, params *KDFParams) ([]byte, error) {
+	if len(password) == 0 {
+		return nil, ErrCipherPassword
+	}
+	if params == nil || params.Algorithm != DefaultKDF || params.Version != argon2.Version {
+		return nil, ErrKDF
+	}
+	if len(params.Salt) != DefaultSaltLength || params.Time != DefaultKDFTime || params.Memory != DefaultKDFMemory || params.Threads != DefaultKDFThreads {
+		return nil, ErrKDF
+	}
+	key := argon2.IDKey([]byte(password), params.Salt, params.Time, params.Memory, params.Threads, DefaultCipherKeyLength)
+	if err := CipherKeyCheck(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
 // Password2cipherkey will take a password and produce
-// a 32 byte cipher key.
+// a 32 byte cipher key from an unsalted MD5 hash.
+//
+// Note: This is only kept so that data encrypted by
+// earlier versions of stark can be decrypted, use
+// DeriveCipherKey for new data.
 func Password2cipherkey(password string) ([]byte, error) {
 	if len(password) == 0 {
 		return nil, ErrCipherPassword
@@ -61,57 +153,196 @@ func Password2cipherkey(password string) ([]byte, error) {
 
 // Encrypt will encrypt plaintext using symmetric key encryption.
 func Encrypt(data string, cipherKey []byte) (string, error) {
+	ciphertextByte, err := seal([]byte(data), cipherKey)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(ciphertextByte), nil
+}
+
+// Decrypt will decrypt plaintext using symmetric key encryption.
+func Decrypt(data string, cipherKey []byte) (string, error) {
 
 	// check the key
 	if err := CipherKeyCheck(cipherKey); err != nil {
 		return "", err
 	}
 
-	// prepare the cipher
-	block, err := aes.NewCipher(cipherKey)
+	// decode
+	ciphertextByte, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return "", ErrCiphertext
+	}
+	plaintextByte, err := open(ciphertextByte, cipherKey)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintextByte), nil
+}
+
+// NewDataKey will produce a random 32 byte cipher key,
+// for encrypting data which is shared with recipients.
+func NewDataKey() ([]byte, error) {
+	dataKey := make([]byte, DefaultCipherKeyLength)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, err
+	}
+	return dataKey, nil
+}
+
+// NewRecipientKeys will derive an X25519 key pair from
+// a secret seed (e.g. the bytes of a node's private
+// key), so the same seed always gives the same keys.
+//
+// It returns the private key and the base64 encoded
+// public key, which can be given to others so that
+// they can wrap data keys for this recipient.
+func NewRecipientKeys(seed []byte) ([]byte, string, error) {
+	if len(seed) == 0 {
+		return nil, "", ErrRecipientKey
+	}
+	privateKey := sha256.Sum256(append([]byte("stark recipient key"), seed...))
+	publicKey, err := curve25519.X25519(privateKey[:], curve25519.Basepoint)
+	if err != nil {
+		return nil, "", err
+	}
+	return privateKey[:], base64.StdEncoding.EncodeToString(publicKey), nil
+}
+
+// RecipientKeyCheck will check a base64 encoded
+// recipient key is a valid X25519 public key.
+func RecipientKeyCheck(recipientKey string) error {
+	key, err := base64.StdEncoding.DecodeString(recipientKey)
+	if err != nil || len(key) != DefaultRecipientKeyLength {
+		return ErrRecipientKey
+	}
+	return nil
+}
+
+// WrapKey will encrypt a data key so that only the
+// holder of the private key for the base64 encoded
+// X25519 recipient key can decrypt it.
+//
+// An ephemeral X25519 key pair is used to agree a
+// wrapping key with the recipient, the ephemeral
+// public key is included in the wrapped key.
+func WrapKey(dataKey []byte, recipientKey string) (string, error) {
+	if err := CipherKeyCheck(dataKey); err != nil {
+		return "", err
+	}
+	if err := RecipientKeyCheck(recipientKey); err != nil {
+		return "", err
+	}
+	recipientPub, _ := base64.StdEncoding.DecodeString(recipientKey)
+
+	// agree a wrapping key using an ephemeral key pair
+	ephemeralPriv := make([]byte, curve25519.ScalarSize)
+	if _, err := io.ReadFull(rand.Reader, ephemeralPriv); err != nil {
+		return "", err
+	}
+	ephemeralPub, err := curve25519.X25519(ephemeralPriv, curve25519.Basepoint)
 	if err != nil {
 		return "", err
 	}
-	gcm, err := cipher.NewGCM(block)
+	wrappingKey, err := agreeKey(ephemeralPriv, recipientPub, ephemeralPub, recipientPub)
 	if err != nil {
 		return "", err
 	}
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+
+	// wrap the data key
+	sealed, err := seal(dataKey, wrappingKey)
+	if err != nil {
 		return "", err
 	}
+	return base64.StdEncoding.EncodeToString(append(ephemeralPub, sealed...)), nil
+}
 
-	// encode
-	ciphertextByte := gcm.Seal(nonce, nonce, []byte(data), nil)
-	return base64.StdEncoding.EncodeToString(ciphertextByte), err
+// UnwrapKey will decrypt a data key which was wrapped
+// for the recipient key belonging to the provided
+// X25519 private key.
+func UnwrapKey(wrappedKey string, privateKey []byte) ([]byte, error) {
+	if len(privateKey) != DefaultRecipientKeyLength {
+		return nil, ErrRecipientKey
+	}
+	data, err := base64.StdEncoding.DecodeString(wrappedKey)
+	if err != nil || len(data) < curve25519.PointSize {
+		return nil, ErrWrappedKey
+	}
+	ephemeralPub, sealed := data[:curve25519.PointSize], data[curve25519.PointSize:]
+	recipientPub, err := curve25519.X25519(privateKey, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	wrappingKey, err := agreeKey(privateKey, ephemeralPub, ephemeralPub, recipientPub)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := open(sealed, wrappingKey)
+	if err != nil {
+		return nil, ErrWrappedKey
+	}
+	if err := CipherKeyCheck(dataKey); err != nil {
+		return nil, ErrWrappedKey
+	}
+	return dataKey, nil
+}
 
+// agreeKey will produce a 32 byte wrapping key from an
+// X25519 key agreement, bound to both public keys.
+func agreeKey(privateKey, peerKey, ephemeralPub, recipientPub []byte) ([]byte, error) {
+	shared, err := curve25519.X25519(privateKey, peerKey)
+	if err != nil {
+		return nil, err
+	}
+	hasher := sha256.New()
+	for _, b := range [][]byte{shared, ephemeralPub, recipientPub} {
+		if _, err := hasher.Write(b); err != nil {
+			return nil, err
+		}
+	}
+	return hasher.Sum(nil), nil
 }
 
-// Decrypt will decrypt plaintext using symmetric key encryption.
-func Decrypt(data string, cipherKey []byte) (string, error) {
+// seal will encrypt data with AES-GCM, prefixing the
+// ciphertext with the random nonce.
+func seal(data, cipherKey []byte) ([]byte, error) {
 
 	// check the key
 	if err := CipherKeyCheck(cipherKey); err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// prepare cipher
-	block, err := aes.NewCipher(cipherKey)
+	// prepare the cipher
+	gcm, err := newGCM(cipherKey)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	gcm, err := cipher.NewGCM(block)
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
+
+// open will decrypt data which was encrypted by seal.
+func open(data, cipherKey []byte) ([]byte, error) {
+	gcm, err := newGCM(cipherKey)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	nonceSize := gcm.NonceSize()
+	if len(data) < nonceSize {
+		return nil, ErrCiphertext
+	}
+	nonce, ciphertextByteClean := data[:nonceSize], data[nonceSize:]
+	return gcm.Open(nil, nonce, ciphertextByteClean, nil)
+}
 
-	// decode
-	ciphertextByte, _ := base64.StdEncoding.DecodeString(data)
-	nonce, ciphertextByteClean := ciphertextByte[:nonceSize], ciphertextByte[nonceSize:]
-	plaintextByte, err := gcm.Open(nil, nonce, ciphertextByteClean, nil)
+// newGCM will prepare an AES-GCM cipher for the key.
+func newGCM(cipherKey []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(cipherKey)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return string(plaintextByte), nil
+	return cipher.NewGCM(block)
 }
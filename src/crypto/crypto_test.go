@@ -19,6 +19,51 @@ func TestCipherKey(t *testing.T) {
 	}
 }
 
+// TestDeriveCipherKey will test the salted key derivation.
+func TestDeriveCipherKey(t *testing.T) {
+	params, err := NewKDFParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := DeriveCipherKey(password, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key2, err := DeriveCipherKey(password, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(key) != string(key2) {
+		t.Fatal("key derivation is not repeatable")
+	}
+
+	// check a new salt gives a new key
+	params2, err := NewKDFParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key2, err = DeriveCipherKey(password, params2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(key) == string(key2) {
+		t.Fatal("different salts produced the same key")
+	}
+
+	// check bad inputs
+	if _, err := DeriveCipherKey("", params); err == nil {
+		t.Fatal("derived cipher key from empty password")
+	}
+	params2.Memory = 4 * 1024 * 1024
+	if _, err := DeriveCipherKey(password, params2); err != ErrKDF {
+		t.Fatal("derived cipher key with non-default parameters")
+	}
+	params.Algorithm = "md5"
+	if _, err := DeriveCipherKey(password, params); err != ErrKDF {
+		t.Fatal("derived cipher key with an unsupported algorithm")
+	}
+}
+
 // TestEncryption will test encyption and decryption.
 func TestEncryption(t *testing.T) {
 
@@ -45,4 +90,51 @@ func TestEncryption(t *testing.T) {
 	if string(data) != string(dData) {
 		t.Fatal("could not decrypt data")
 	}
+
+	// test invalid ciphertext
+	if _, err := Decrypt("short", key); err != ErrCiphertext {
+		t.Fatal("decrypted invalid ciphertext")
+	}
+}
+
+// TestWrapKey will test wrapping a data key for a recipient.
+func TestWrapKey(t *testing.T) {
+	privateKey, recipientKey, err := NewRecipientKeys([]byte(password))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, recipientKey2, err := NewRecipientKeys([]byte(password))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recipientKey != recipientKey2 {
+		t.Fatal("recipient key derivation is not repeatable")
+	}
+	dataKey, err := NewDataKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapped, err := WrapKey(dataKey, recipientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unwrapped, err := UnwrapKey(wrapped, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(unwrapped) != string(dataKey) {
+		t.Fatal("could not unwrap data key")
+	}
+
+	// check another recipient can't unwrap the key
+	otherKey, _, err := NewRecipientKeys([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UnwrapKey(wrapped, otherKey); err != ErrWrappedKey {
+		t.Fatal("unwrapped data key with the wrong private key")
+	}
+	if _, err := WrapKey(dataKey, "not a key"); err != ErrRecipientKey {
+		t.Fatal("wrapped data key for an invalid recipient key")
+	}
 }
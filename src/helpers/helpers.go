@@ -9,6 +9,10 @@ import (
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// ErrNotPinned is issued by a storage backend when an
+// unpin is requested for a CID which is not pinned.
+var ErrNotPinned = fmt.Errorf("CID is not pinned")
+
 // CheckFileExists checks a returns true if
 // a file exists and is not a directory.
 func CheckFileExists(filename string) bool {
@@ -0,0 +1,134 @@
+package ipfs
+
+import (
+	"context"
+	"fmt"
+
+	config "github.com/ipfs/go-ipfs-config"
+	httpapi "github.com/ipfs/go-ipfs-http-client"
+	"github.com/ipfs/go-ipfs/repo/fsrepo"
+	libp2pcrypto "github.com/libp2p/go-libp2p-core/crypto"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// HTTPClient is a Client that talks to an already running IPFS
+// daemon via its HTTP API, instead of running an IPFS node
+// in-process.
+//
+// It shares the IO and PubSub methods of the Client and
+// overrides the methods which need direct access to a node.
+type HTTPClient struct {
+	Client
+	id     string // the peer ID of the daemon
+	online bool   // true if the daemon has networking enabled
+}
+
+// NewHTTPClient will initialise a client for the IPFS daemon
+// listening on the provided API multiaddr (e.g.
+// /ip4/127.0.0.1/tcp/5001).
+func NewHTTPClient(apiAddr string) (*HTTPClient, error) {
+	addr, err := ma.NewMultiaddr(apiAddr)
+	if err != nil {
+		return nil, err
+	}
+	api, err := httpapi.NewApi(addr)
+	if err != nil {
+		return nil, err
+	}
+	return newHTTPClient(api)
+}
+
+// NewHTTPClientFromRepo will initialise a client for the IPFS
+// daemon which has locked the provided repo, using the API
+// address advertised in the repo. If no repoPath is provided,
+// the system default repo is used.
+func NewHTTPClientFromRepo(repoPath string) (*HTTPClient, error) {
+	if len(repoPath) == 0 {
+		var err error
+		repoPath, err = config.PathRoot()
+		if err != nil {
+			return nil, err
+		}
+	}
+	api, err := httpapi.NewPathApi(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	return newHTTPClient(api)
+}
+
+// RepoLocked returns true if the provided repo is locked by
+// another process (e.g. a running IPFS daemon). If no repoPath
+// is provided, the system default repo is checked.
+func RepoLocked(repoPath string) (bool, error) {
+	if len(repoPath) == 0 {
+		var err error
+		repoPath, err = config.PathRoot()
+		if err != nil {
+			return false, err
+		}
+	}
+	if !fsrepo.IsInitialized(repoPath) {
+		return false, nil
+	}
+	return fsrepo.LockedByOtherProcess(repoPath)
+}
+
+// EndSession closes down the client. The daemon is left
+// running.
+func (client *HTTPClient) EndSession() error {
+	client.online = false
+	return nil
+}
+
+// PrintNodeID will print the daemon's identity.
+func (client *HTTPClient) PrintNodeID() string {
+	return client.id
+}
+
+// PrivateKey will always return ErrNoPrivateKey, as
+// the daemon does not share its private key.
+func (client *HTTPClient) PrivateKey() (libp2pcrypto.PrivKey, error) {
+	return nil, ErrNoPrivateKey
+}
+
+// GetPublicIPv4Addr uses the daemon's listening addresses to
+// return the public ipv4 address of the host machine, if
+// available.
+func (client *HTTPClient) GetPublicIPv4Addr() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultAPITimeout)
+	defer cancel()
+	addrs, err := client.ipfs.Swarm().LocalAddrs(ctx)
+	if err != nil {
+		return "", err
+	}
+	return findPublicIPv4Addr(addrs)
+}
+
+// Online will return true if the daemon is online.
+func (client *HTTPClient) Online() bool {
+	return client.online
+}
+
+// newHTTPClient checks the daemon is reachable and
+// returns the wrapped API.
+func newHTTPClient(api *httpapi.HttpApi) (*HTTPClient, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultAPITimeout)
+	defer cancel()
+
+	// get the daemon identity (also checks the API is reachable)
+	self, err := api.Key().Self(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not reach IPFS daemon: %w", err)
+	}
+
+	// the swarm is unavailable if the daemon is running offline
+	_, err = api.Swarm().LocalAddrs(ctx)
+	return &HTTPClient{
+		Client: Client{
+			ipfs: api,
+		},
+		id:     self.ID().Pretty(),
+		online: err == nil,
+	}, nil
+}
@@ -17,11 +17,24 @@ import (
 	"github.com/ipfs/interface-go-ipfs-core/options"
 	"github.com/ipfs/interface-go-ipfs-core/path"
 	icorepath "github.com/ipfs/interface-go-ipfs-core/path"
+	starkshard "github.com/will-rowe/stark/src/shard"
 )
 
-// Unpin will unpin a CID from the IPFS.
+// Pin will pin a CID in the IPFS, fetching it from the
+// network if needed. If recursive is true, everything the
+// CID links to is also pinned.
+func (client *Client) Pin(ctx context.Context, cidStr string, recursive bool) error {
+	return client.ipfs.Pin().Add(ctx, path.New(cidStr), options.Pin.Recursive(recursive))
+}
+
+// Unpin will unpin a CID from the IPFS. It returns
+// ErrNotPinned if the CID is not pinned.
 func (client *Client) Unpin(ctx context.Context, cidStr string) error {
-	return client.ipfs.Pin().Rm(ctx, path.New(cidStr))
+	err := client.ipfs.Pin().Rm(ctx, path.New(cidStr))
+	if err != nil && strings.Contains(err.Error(), "not pinned") {
+		return ErrNotPinned
+	}
+	return err
 }
 
 // AddFile will add a file (or directory) to the IPFS and return
@@ -61,39 +74,36 @@ func (client *Client) GetFile(ctx context.Context, cidStr, outputPath string) er
 	return nil
 }
 
-// NewDagNode will create a new UNIXFS formatted DAG node in the IPFS.
+// NewDagNode will create a new HAMT-sharded UNIXFS directory in the IPFS.
 func (client *Client) NewDagNode(ctx context.Context) (string, error) {
-	path, err := client.ipfs.Object().New(ctx, options.Object.Type("unixfs-dir"))
-	if err != nil {
-		return "", err
-	}
-	return path.Cid().String(), nil
+	return starkshard.NewDirectory(ctx, client.ipfs.Dag())
 }
 
-// AddLink will add a link under the specified path. Child path can point to a
-// subdirectory within the patent, it will be created if not present.
+// AddLink will add a link under the specified label,
+// replacing any existing link with this label. Basic
+// UNIXFS directories are converted to HAMT directories.
 // It will return the new base CID and any error.
 func (client *Client) AddLink(ctx context.Context, baseCID, childCID, linkLabel string) (string, error) {
-	path, err := client.ipfs.Object().AddLink(ctx, path.New(baseCID), linkLabel, path.New(childCID), options.Object.Create(true))
-	if err != nil {
-		return "", err
-	}
-	return path.Cid().String(), nil
+	return starkshard.AddLink(ctx, client.ipfs.Dag(), baseCID, childCID, linkLabel)
+}
+
+// AddLinks will add several links to a node in a single
+// update, using the map keys as the link labels and the
+// values as the child CIDs.
+// It will return the new base CID and any error.
+func (client *Client) AddLinks(ctx context.Context, baseCID string, links map[string]string) (string, error) {
+	return starkshard.AddLinks(ctx, client.ipfs.Dag(), baseCID, links)
 }
 
-// RmLink will remove a link under the specified path.
+// RmLink will remove a link under the specified label.
 // It will return the new base CID and any error.
 func (client *Client) RmLink(ctx context.Context, baseCID, linkLabel string) (string, error) {
-	path, err := client.ipfs.Object().RmLink(ctx, path.New(baseCID), linkLabel)
-	if err != nil {
-		return "", err
-	}
-	return path.Cid().String(), nil
+	return starkshard.RmLink(ctx, client.ipfs.Dag(), baseCID, linkLabel)
 }
 
 // GetNodeLinks returns the links from a node.
 func (client *Client) GetNodeLinks(ctx context.Context, nodeCID string) ([]*ipld.Link, error) {
-	linkList, err := client.ipfs.Object().Links(ctx, path.New(nodeCID))
+	linkList, err := starkshard.GetLinks(ctx, client.ipfs.Dag(), nodeCID)
 	if err != nil {
 		return nil, err
 	}
@@ -103,6 +113,13 @@ func (client *Client) GetNodeLinks(ctx context.Context, nodeCID string) ([]*ipld
 	return linkList, nil
 }
 
+// ForEachLink will call the provided function for each
+// link from a node, fetching the directory shards as
+// they are needed.
+func (client *Client) ForEachLink(ctx context.Context, nodeCID string, f func(*ipld.Link) error) error {
+	return starkshard.ForEachLink(ctx, client.ipfs.Dag(), nodeCID, f)
+}
+
 // GetNodeData will output a reader for the raw bytes
 // contained in an IPFS DAG node.
 func (client *Client) GetNodeData(ctx context.Context, nodeCID string) (io.Reader, error) {
@@ -152,6 +169,47 @@ func (client *Client) DagPut(ctx context.Context, data []byte, pinning bool) (st
 	return nds[0].Cid().String(), nil
 }
 
+// DagPutBatch will append several JSON documents to an
+// IPFS dag, committing the nodes in a single batch. The
+// CIDs are returned in the same order as the data.
+func (client *Client) DagPutBatch(ctx context.Context, data [][]byte, pinning bool) ([]string, error) {
+
+	// get the node adder
+	var adder ipld.NodeAdder = client.ipfs.Dag()
+	if pinning {
+		adder = client.ipfs.Dag().Pinning()
+	}
+
+	// create and buffer the IPLD format node(s) for each document
+	buf := ipld.NewBatch(ctx, adder)
+	cids := make([]string, len(data))
+	for i, d := range data {
+		file := files.NewReaderFile(bytes.NewReader(d))
+		if file == nil {
+			return nil, fmt.Errorf("failed to convert input data for IPFS")
+		}
+		nds, err := coredag.ParseInputs(DefaultIenc, DefaultFormatParser, file, DefaultMhType, -1)
+		if err != nil {
+			return nil, err
+		}
+		if len(nds) == 0 {
+			return nil, fmt.Errorf("no node returned from ParseInputs")
+		}
+		for _, nd := range nds {
+			if err := buf.Add(ctx, nd); err != nil {
+				return nil, err
+			}
+		}
+		cids[i] = nds[0].Cid().String()
+	}
+
+	// commit the batched nodes
+	if err := buf.Commit(); err != nil {
+		return nil, err
+	}
+	return cids, nil
+}
+
 // DagGet will fetch a DAG node from the IPFS using the
 // provided CID.
 func (client *Client) DagGet(ctx context.Context, queryCID string) (interface{}, error) {
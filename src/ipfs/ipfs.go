@@ -4,11 +4,13 @@ package ipfs
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"math"
 	"net"
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	config "github.com/ipfs/go-ipfs-config"
 	"github.com/ipfs/go-ipfs/core" // This package is needed so that all the preloaded plugins are loaded automatically
@@ -18,6 +20,7 @@ import (
 	"github.com/ipfs/go-ipfs/repo"
 	"github.com/ipfs/go-ipfs/repo/fsrepo"
 	icore "github.com/ipfs/interface-go-ipfs-core"
+	libp2pcrypto "github.com/libp2p/go-libp2p-core/crypto"
 	"github.com/libp2p/go-libp2p-core/peer"
 	peerstore "github.com/libp2p/go-libp2p-peerstore"
 	ma "github.com/multiformats/go-multiaddr"
@@ -37,6 +40,12 @@ const (
 
 	// DefaultMhType is the multihash to use for DAG put operations.
 	DefaultMhType = uint64(math.MaxUint64) // use default hash (sha256 for cbor, sha1 for git..)
+
+	// DefaultAPITimeout is the maximum time to wait for an IPFS daemon to respond via the HTTP API.
+	DefaultAPITimeout = 5 * time.Second
+
+	// DefaultKeypairSize is the number of bits used for the identity generated during repo initialisation.
+	DefaultKeypairSize = 2048
 )
 
 var (
@@ -64,44 +73,30 @@ var (
 		"/ip4/94.130.135.167/udp/4001/quic/p2p/QmUEMvxS2e7iDrereVYc5SWPauXPyNwxcy9BXZrC1QTcHE",
 	}
 
+	// pluginOnce ensures the IPFS plugins are only injected once per session.
+	pluginOnce sync.Once
+
+	// pluginErr records any error encountered during plugin injection.
+	pluginErr error
+
 	// ErrRepoAlreadyInitialised is issued when an IPFS repo has already been initialised.
 	ErrRepoAlreadyInitialised = fmt.Errorf("specified IPFS repo is already initialised")
 
+	// ErrNoRepoPath is issued when an empty IPFS repo path is provided.
+	ErrNoRepoPath = fmt.Errorf("no IPFS repo path provided")
+
 	// ErrNoLinks is issued when no links are found in an IPFS DAG node.
 	ErrNoLinks = fmt.Errorf("no links found in IPFS DAG node")
 
 	// ErrOffline indicates node is offline.
 	ErrOffline = fmt.Errorf("the IPFS node is offline")
-)
 
-// init will setup the IPFS repo
-func init() {
-	var err error
-	if len(defaultIpfsRepo) == 0 {
-		defaultIpfsRepo, err = config.PathRoot()
-		if err != nil {
-			panic(err)
-		}
-	}
-	if !fsrepo.IsInitialized(defaultIpfsRepo) {
-		panic("default IPFS repo needs initialising (run `ipfs init`)")
-	}
+	// ErrNotPinned is issued when an unpin is requested for a CID which is not pinned.
+	ErrNotPinned = fmt.Errorf("%w (or pinned indirectly)", helpers.ErrNotPinned)
 
-	// check if repo is already locked
-	locked, err := fsrepo.LockedByOtherProcess(defaultIpfsRepo)
-	if err != nil {
-		panic(err)
-	}
-	if locked {
-		//fmt.Println("IPFS already runnning - skipping IPFS plugin initialisation")
-		return
-	}
-
-	// initialise the plugins
-	if _, err := initIPFSplugins(defaultIpfsRepo); err != nil {
-		panic(err)
-	}
-}
+	// ErrNoPrivateKey indicates the node's private key is not available (e.g. it is held by an IPFS daemon).
+	ErrNoPrivateKey = fmt.Errorf("the private key for the IPFS node is not available")
+)
 
 // Client is a wrapper that groups and controls access to the IPFS
 // for the starkDB.
@@ -137,23 +132,19 @@ func (client *Client) PrintNodeID() string {
 	return client.node.Identity.Pretty()
 }
 
+// PrivateKey returns the private key for the node's
+// identity.
+func (client *Client) PrivateKey() (libp2pcrypto.PrivKey, error) {
+	if client.node == nil || client.node.PrivateKey == nil {
+		return nil, ErrNoPrivateKey
+	}
+	return client.node.PrivateKey, nil
+}
+
 // GetPublicIPv4Addr uses the host addresses to return the
 // public ipv4 address of the host machine, if available.
 func (client *Client) GetPublicIPv4Addr() (string, error) {
-	for _, addr := range client.node.PeerHost.Addrs() {
-		parts := strings.Split(addr.String(), "/")
-		if len(parts) < 3 {
-			continue
-		}
-		if parts[1] != "ip4" {
-			continue
-		}
-		parsed := net.ParseIP(parts[2])
-		if parsed != nil && helpers.IsPublicIPv4(parsed) {
-			return addr.String(), nil
-		}
-	}
-	return "", fmt.Errorf("no public IPv4 address was found for IPFS node")
+	return findPublicIPv4Addr(client.node.PeerHost.Addrs())
 }
 
 // GetPeers will return currently connected peers.
@@ -217,17 +208,84 @@ func (client *Client) Connect(ctx context.Context, peers []string, logger chan i
 
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
+// ClientOption is a wrapper struct used to pass functional
+// options to the IPFS client constructor.
+type ClientOption func(cfg *clientConfig) error
+
+// clientConfig holds the settings used to build the IPFS node.
+type clientConfig struct {
+	repoPath string // the IPFS repo to open (initialised if needed)
+	offline  bool   // if true, the node is built with networking disabled
+}
+
+// SetRepoPath is an option setter for the NewIPFSclient
+// constructor that sets the IPFS repo to use. If the repo
+// has not been initialised, a new one will be created.
+func SetRepoPath(repoPath string) ClientOption {
+	return func(cfg *clientConfig) error {
+		if len(repoPath) == 0 {
+			return ErrNoRepoPath
+		}
+		cfg.repoPath = repoPath
+		return nil
+	}
+}
+
+// WithOffline is an option setter for the NewIPFSclient
+// constructor that builds the IPFS node with networking
+// disabled (no DHT routing, PubSub or peer connections).
+func WithOffline() ClientOption {
+	return func(cfg *clientConfig) error {
+		cfg.offline = true
+		return nil
+	}
+}
+
 // NewIPFSclient will initialise the IPFS client.
 //
 // The IPFS code used in starkDB is based on the IPFS as a
 // library example (go-ipfs v0.5.0)
 //
 // Note: if no repoPath provided, this function will attempt to
-// use the IPFS default repo path.
-func NewIPFSclient(ctx context.Context) (*Client, error) {
+// use the IPFS default repo path. If the repo has not been
+// initialised, this function will initialise it. The IPFS
+// plugins are loaded from the first repo used in the session
+// (see loadPlugins).
+func NewIPFSclient(ctx context.Context, options ...ClientOption) (*Client, error) {
+
+	// add the provided options
+	cfg := &clientConfig{
+		repoPath: defaultIpfsRepo,
+	}
+	for _, option := range options {
+		if err := option(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	// find the system default repo if none provided
+	if len(cfg.repoPath) == 0 {
+		var err error
+		cfg.repoPath, err = config.PathRoot()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// load the plugins (only happens on the first call)
+	if err := loadPlugins(cfg.repoPath); err != nil {
+		return nil, err
+	}
+
+	// initialise the repo if needed
+	if !fsrepo.IsInitialized(cfg.repoPath) {
+		if err := InitRepo(cfg.repoPath); err != nil {
+			return nil, err
+		}
+	}
 
 	// open the repo
-	repo, err := fsrepo.Open(defaultIpfsRepo)
+	repo, err := fsrepo.Open(cfg.repoPath)
 	if err != nil {
 		return nil, err
 	}
@@ -246,15 +304,27 @@ func NewIPFSclient(ctx context.Context) (*Client, error) {
 		},
 	}
 
+	// strip out the networking if running offline
+	if cfg.offline {
+		defaultNodeOpts = &core.BuildCfg{
+			Online:    false,
+			Permanent: true,
+			Repo:      repo,
+		}
+	}
+
 	// construct the node
 	node, err := core.NewNode(ctx, defaultNodeOpts)
 	if err != nil {
+		repo.Close()
 		return nil, err
 	}
 
 	// get the API
 	api, err := coreapi.NewCoreAPI(node)
 	if err != nil {
+		node.Close()
+		repo.Close()
 		return nil, err
 	}
 
@@ -266,10 +336,72 @@ func NewIPFSclient(ctx context.Context) (*Client, error) {
 	}, nil
 }
 
+// findPublicIPv4Addr returns the first public ipv4
+// address from a list of multiaddrs.
+func findPublicIPv4Addr(addrs []ma.Multiaddr) (string, error) {
+	for _, addr := range addrs {
+		parts := strings.Split(addr.String(), "/")
+		if len(parts) < 3 {
+			continue
+		}
+		if parts[1] != "ip4" {
+			continue
+		}
+		parsed := net.ParseIP(parts[2])
+		if parsed != nil && helpers.IsPublicIPv4(parsed) {
+			return addr.String(), nil
+		}
+	}
+	return "", fmt.Errorf("no public IPv4 address was found for IPFS node")
+}
+
+// InitRepo will create and initialise a new IPFS repo
+// at the provided path, using a newly generated identity.
+func InitRepo(repoPath string) error {
+	if len(repoPath) == 0 {
+		return ErrNoRepoPath
+	}
+	if fsrepo.IsInitialized(repoPath) {
+		return ErrRepoAlreadyInitialised
+	}
+	if err := helpers.CheckDir(repoPath); err != nil {
+		return err
+	}
+
+	// the datastore plugins are needed to write the repo
+	if err := loadPlugins(repoPath); err != nil {
+		return err
+	}
+
+	// generate a config with a new identity
+	cfg, err := config.Init(ioutil.Discard, DefaultKeypairSize)
+	if err != nil {
+		return err
+	}
+
+	// write the repo
+	return fsrepo.Init(repoPath, cfg)
+}
+
+// loadPlugins will initialise and inject the IPFS plugins
+// the first time it is called. Subsequent calls return the
+// result of the first call.
+//
+// Note: plugins are only loaded from the plugins directory
+// of the first repo used in the session, any other repos
+// opened later in the session will use the same plugins.
+func loadPlugins(repoPath string) error {
+	pluginOnce.Do(func() {
+		_, pluginErr = initIPFSplugins(repoPath)
+	})
+	return pluginErr
+}
+
 // initIPFSplugins is used to initialized IPFS plugins
 // before creating a new IPFS node.
 //
-// Note: This should only be called once per sesh.
+// Note: This should only be called once per sesh (use
+// loadPlugins).
 func initIPFSplugins(repoPath string) (*loader.PluginLoader, error) {
 	pl, err := loader.NewPluginLoader(filepath.Join(repoPath, "plugins"))
 	if err != nil {
@@ -0,0 +1,55 @@
+package ipfs
+
+import (
+	"context"
+
+	"github.com/ipfs/interface-go-ipfs-core/options"
+	"github.com/ipfs/interface-go-ipfs-core/path"
+)
+
+// NameKey returns the IPNS name for a key in the node's
+// keystore, generating the key if it doesn't exist yet.
+func (client *Client) NameKey(ctx context.Context, keyName string) (string, error) {
+	keys, err := client.ipfs.Key().List(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, key := range keys {
+		if key.Name() == keyName {
+			return key.Path().String(), nil
+		}
+	}
+	key, err := client.ipfs.Key().Generate(ctx, keyName, options.Key.Type(options.Ed25519Key))
+	if err != nil {
+		return "", err
+	}
+	return key.Path().String(), nil
+}
+
+// PublishName will publish a CID to IPNS under the provided
+// key from the node's keystore, generating the key if it
+// doesn't exist yet. It returns the IPNS name.
+func (client *Client) PublishName(ctx context.Context, keyName, cidStr string) (string, error) {
+	if _, err := client.NameKey(ctx, keyName); err != nil {
+		return "", err
+	}
+	entry, err := client.ipfs.Name().Publish(ctx, path.New(cidStr), options.Name.Key(keyName))
+	if err != nil {
+		return "", err
+	}
+	return "/ipns/" + entry.Name(), nil
+}
+
+// ResolveName will resolve an IPNS name to the CID it
+// currently points to.
+func (client *Client) ResolveName(ctx context.Context, name string) (string, error) {
+	p, err := client.ipfs.Name().Resolve(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := client.ipfs.ResolvePath(ctx, p)
+	if err != nil {
+		return "", err
+	}
+	return resolved.Cid().String(), nil
+}
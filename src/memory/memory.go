@@ -0,0 +1,362 @@
+//Package memory is a pure in-memory blockstore that satisfies the starkDB storage backend.
+package memory
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+
+	blockservice "github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-cid"
+	datastore "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	ipld "github.com/ipfs/go-ipld-format"
+	merkle "github.com/ipfs/go-merkledag"
+	icore "github.com/ipfs/interface-go-ipfs-core"
+	libp2pcrypto "github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	starkhelpers "github.com/will-rowe/stark/src/helpers"
+	starkshard "github.com/will-rowe/stark/src/shard"
+)
+
+const (
+
+	// DefaultMhType is the multihash to use for DAG put operations.
+	DefaultMhType = uint64(math.MaxUint64) // use default hash (sha256 for cbor)
+)
+
+var (
+
+	// ErrNoLinks is issued when no links are found in a DAG node.
+	ErrNoLinks = fmt.Errorf("no links found in DAG node")
+
+	// ErrNameNotFound is issued when an IPNS name has not been published in the store.
+	ErrNameNotFound = fmt.Errorf("IPNS name has not been published in the in-memory store")
+
+	// ErrNotPinned is issued when a pin is requested for an unknown CID, or an unpin for a CID which is not pinned.
+	ErrNotPinned = fmt.Errorf("%w (or not held) in the in-memory store", starkhelpers.ErrNotPinned)
+
+	// ErrOffline indicates the in-memory store has no network.
+	ErrOffline = fmt.Errorf("the in-memory store is offline")
+)
+
+// Store is an in-memory blockstore which groups and
+// controls access to DAG nodes for the starkDB.
+//
+// It produces the same CIDs as the IPFS client but
+// has no networking, so PubSub methods will return
+// ErrOffline. IPNS names are held in the store and
+// can only be resolved by databases sharing it.
+type Store struct {
+	sync.Mutex                      // protects the pin set, names and private key
+	dag        ipld.DAGService      // the DAG service wrapping the in-memory blockstore
+	pins       map[string]struct{}  // the CIDs which have been pinned
+	names      map[string]string    // the CIDs which have been published to IPNS names
+	privKey    libp2pcrypto.PrivKey // the private key generated for the store
+}
+
+// NewStore will initialise an empty in-memory store.
+func NewStore() *Store {
+	bs := blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore()))
+	bserv := blockservice.New(bs, offline.Exchange(bs))
+	return &Store{
+		dag:   merkle.NewDAGService(bserv),
+		pins:  make(map[string]struct{}),
+		names: make(map[string]string),
+	}
+}
+
+// EndSession closes down the store.
+func (store *Store) EndSession() error {
+	store.Lock()
+	defer store.Unlock()
+	store.pins = make(map[string]struct{})
+	return nil
+}
+
+// PrintNodeID returns the peer ID for the private
+// key of the store (see PrivateKey), so that Records
+// signed by the store can be matched to it. It returns
+// an empty string if the key can't be generated.
+func (store *Store) PrintNodeID() string {
+	privKey, err := store.PrivateKey()
+	if err != nil {
+		return ""
+	}
+	id, err := peer.IDFromPrivateKey(privKey)
+	if err != nil {
+		return ""
+	}
+	return id.Pretty()
+}
+
+// PrivateKey returns a private key for the store,
+// which is generated the first time it is requested
+// and lasts for the lifetime of the store.
+func (store *Store) PrivateKey() (libp2pcrypto.PrivKey, error) {
+	store.Lock()
+	defer store.Unlock()
+	if store.privKey == nil {
+		privKey, _, err := libp2pcrypto.GenerateEd25519Key(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		store.privKey = privKey
+	}
+	return store.privKey, nil
+}
+
+// GetPublicIPv4Addr will always return ErrOffline.
+func (store *Store) GetPublicIPv4Addr() (string, error) {
+	return "", ErrOffline
+}
+
+// GetPeers will always return ErrOffline.
+func (store *Store) GetPeers(ctx context.Context) ([]string, error) {
+	return nil, ErrOffline
+}
+
+// Online will always return false.
+func (store *Store) Online() bool {
+	return false
+}
+
+// Connect is a no-op for the in-memory store.
+func (store *Store) Connect(ctx context.Context, peers []string, logger chan interface{}) {
+	return
+}
+
+// GetPSMchan returns a nil channel as the in-memory
+// store does not receive PubSub messages.
+func (store *Store) GetPSMchan() chan icore.PubSubMessage {
+	return nil
+}
+
+// GetPSEchan returns a nil channel as the in-memory
+// store does not receive PubSub errors.
+func (store *Store) GetPSEchan() chan error {
+	return nil
+}
+
+// SendMessage will always return ErrOffline.
+func (store *Store) SendMessage(ctx context.Context, topic string, message []byte) error {
+	return ErrOffline
+}
+
+// Subscribe will always return ErrOffline.
+func (store *Store) Subscribe(ctx context.Context, topic string) error {
+	return ErrOffline
+}
+
+// Unsubscribe will always return ErrOffline.
+func (store *Store) Unsubscribe() error {
+	return ErrOffline
+}
+
+// NameKey returns the IPNS name for a key. The in-memory
+// store has no keystore, so the key name is used as the
+// IPNS name.
+func (store *Store) NameKey(ctx context.Context, keyName string) (string, error) {
+	return "/ipns/" + keyName, nil
+}
+
+// PublishName will publish a CID to the IPNS name for
+// the provided key. It returns the IPNS name.
+func (store *Store) PublishName(ctx context.Context, keyName, cidStr string) (string, error) {
+	name, err := store.NameKey(ctx, keyName)
+	if err != nil {
+		return "", err
+	}
+	store.Lock()
+	store.names[name] = cleanPath(cidStr)
+	store.Unlock()
+	return name, nil
+}
+
+// ResolveName will resolve an IPNS name published in
+// the store to the CID it currently points to.
+func (store *Store) ResolveName(ctx context.Context, name string) (string, error) {
+	store.Lock()
+	defer store.Unlock()
+	cidStr, ok := store.names[name]
+	if !ok {
+		return "", ErrNameNotFound
+	}
+	return cidStr, nil
+}
+
+// Pin will pin a CID held in the store. The store has
+// no network to fetch from, so the CID must already be
+// held. The recursive flag is ignored.
+func (store *Store) Pin(ctx context.Context, cidStr string, recursive bool) error {
+	c, err := cid.Decode(cleanPath(cidStr))
+	if err != nil {
+		return err
+	}
+	if _, err := store.dag.Get(ctx, c); err != nil {
+		return ErrNotPinned
+	}
+	store.Lock()
+	store.pins[c.String()] = struct{}{}
+	store.Unlock()
+	return nil
+}
+
+// Unpin will unpin a CID from the store. It returns
+// ErrNotPinned if the CID is not pinned.
+func (store *Store) Unpin(ctx context.Context, cidStr string) error {
+	c, err := cid.Decode(cleanPath(cidStr))
+	if err != nil {
+		return err
+	}
+	store.Lock()
+	defer store.Unlock()
+	if _, ok := store.pins[c.String()]; !ok {
+		return ErrNotPinned
+	}
+	delete(store.pins, c.String())
+	return nil
+}
+
+// NewDagNode will create a new HAMT-sharded UNIXFS directory in the store.
+func (store *Store) NewDagNode(ctx context.Context) (string, error) {
+	return starkshard.NewDirectory(ctx, store.dag)
+}
+
+// AddLink will add a link under the specified label,
+// replacing any existing link with this label.
+// It will return the new base CID and any error.
+func (store *Store) AddLink(ctx context.Context, baseCID, childCID, linkLabel string) (string, error) {
+	return starkshard.AddLink(ctx, store.dag, baseCID, childCID, linkLabel)
+}
+
+// AddLinks will add several links to a node in a single
+// update, using the map keys as the link labels and the
+// values as the child CIDs.
+// It will return the new base CID and any error.
+func (store *Store) AddLinks(ctx context.Context, baseCID string, links map[string]string) (string, error) {
+	return starkshard.AddLinks(ctx, store.dag, baseCID, links)
+}
+
+// RmLink will remove a link under the specified label.
+// It will return the new base CID and any error.
+func (store *Store) RmLink(ctx context.Context, baseCID, linkLabel string) (string, error) {
+	return starkshard.RmLink(ctx, store.dag, baseCID, linkLabel)
+}
+
+// GetNodeLinks returns the links from a node.
+func (store *Store) GetNodeLinks(ctx context.Context, nodeCID string) ([]*ipld.Link, error) {
+	linkList, err := starkshard.GetLinks(ctx, store.dag, nodeCID)
+	if err != nil {
+		return nil, err
+	}
+	if len(linkList) == 0 {
+		return nil, ErrNoLinks
+	}
+	return linkList, nil
+}
+
+// ForEachLink will call the provided function for each
+// link from a node, fetching the directory shards as
+// they are needed.
+func (store *Store) ForEachLink(ctx context.Context, nodeCID string, f func(*ipld.Link) error) error {
+	return starkshard.ForEachLink(ctx, store.dag, nodeCID, f)
+}
+
+// DagPut will convert JSON data to a CBOR node
+// and add it to the store.
+func (store *Store) DagPut(ctx context.Context, data []byte, pinning bool) (string, error) {
+	node, err := cbor.FromJSON(bytes.NewReader(data), DefaultMhType, -1)
+	if err != nil {
+		return "", err
+	}
+	if err := store.dag.Add(ctx, node); err != nil {
+		return "", err
+	}
+	if pinning {
+		store.Lock()
+		store.pins[node.Cid().String()] = struct{}{}
+		store.Unlock()
+	}
+	return node.Cid().String(), nil
+}
+
+// DagPutBatch will convert several JSON documents to
+// CBOR nodes and add them to the store in one batch.
+// The CIDs are returned in the same order as the data.
+func (store *Store) DagPutBatch(ctx context.Context, data [][]byte, pinning bool) ([]string, error) {
+	buf := ipld.NewBatch(ctx, store.dag)
+	cids := make([]string, len(data))
+	for i, d := range data {
+		node, err := cbor.FromJSON(bytes.NewReader(d), DefaultMhType, -1)
+		if err != nil {
+			return nil, err
+		}
+		if err := buf.Add(ctx, node); err != nil {
+			return nil, err
+		}
+		cids[i] = node.Cid().String()
+	}
+	if err := buf.Commit(); err != nil {
+		return nil, err
+	}
+	if pinning {
+		store.Lock()
+		for _, c := range cids {
+			store.pins[c] = struct{}{}
+		}
+		store.Unlock()
+	}
+	return cids, nil
+}
+
+// DagGet will fetch a DAG node from the store using
+// the provided CID. A path can be appended to the CID
+// to resolve a field within the node.
+func (store *Store) DagGet(ctx context.Context, queryCID string) (interface{}, error) {
+	parts := strings.Split(cleanPath(queryCID), "/")
+	c, err := cid.Decode(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	obj, err := store.dag.Get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	// detect what we're dealing with
+	switch c.Type() {
+	case cid.DagProtobuf:
+		if _, isProtoNode := obj.(*merkle.ProtoNode); !isProtoNode {
+			return nil, fmt.Errorf("node can't be cast to protobuf for: %v", c.String())
+		}
+	case cid.DagCBOR:
+		if _, isCborNode := obj.(*cbor.Node); !isCborNode {
+			return nil, fmt.Errorf("node can't be cast to cbor for: %v", c.String())
+		}
+	default:
+		return nil, fmt.Errorf("unsupported IPLD CID format (%v = %v)", queryCID, c.Type())
+	}
+
+	// grab the specified field if one was given
+	if len(parts) > 1 {
+		final, _, err := obj.Resolve(parts[1:])
+		if err != nil {
+			return nil, err
+		}
+		return final, nil
+	}
+	return obj, nil
+}
+
+// cleanPath removes any IPFS namespace from a CID path.
+func cleanPath(p string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(p, "/ipfs/"), "/")
+}
@@ -0,0 +1,270 @@
+//Package shard stores a starkDB project snapshot as a HAMT-sharded UNIXFS directory.
+package shard
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	merkle "github.com/ipfs/go-merkledag"
+	unixfs "github.com/ipfs/go-unixfs"
+	"github.com/ipfs/go-unixfs/hamt"
+)
+
+const (
+
+	// DefaultShardWidth is the fanout of each HAMT shard node.
+	DefaultShardWidth = 256
+)
+
+var (
+
+	// ErrLinkNotFound is issued when a link name is not found in a directory.
+	ErrLinkNotFound = fmt.Errorf("link not found in directory")
+
+	// ErrNotDirectory is issued when a node is not a UNIXFS directory.
+	ErrNotDirectory = fmt.Errorf("node is not a UNIXFS directory")
+)
+
+// NewDirectory will create an empty HAMT-sharded directory
+// in the DAG service and return its CID.
+func NewDirectory(ctx context.Context, dserv ipld.DAGService) (string, error) {
+	shard, err := hamt.NewShard(dserv, DefaultShardWidth)
+	if err != nil {
+		return "", err
+	}
+	return save(ctx, dserv, shard)
+}
+
+// AddLink will link a child node to a directory under the
+// provided name, replacing any existing link with the same
+// name. A basic UNIXFS directory is converted to a HAMT
+// directory before the link is added.
+//
+// It returns the CID of the updated directory.
+func AddLink(ctx context.Context, dserv ipld.DAGService, dirCID, childCID, name string) (string, error) {
+	return AddLinks(ctx, dserv, dirCID, map[string]string{name: childCID})
+}
+
+// AddLinks will link several child nodes to a directory,
+// using the map keys as the link names and the values as
+// the child CIDs. The directory is only written once,
+// after all the links have been added.
+//
+// It returns the CID of the updated directory.
+func AddLinks(ctx context.Context, dserv ipld.DAGService, dirCID string, links map[string]string) (string, error) {
+	shard, err := load(ctx, dserv, dirCID)
+	if err != nil {
+		return "", err
+	}
+	names := make([]string, 0, len(links))
+	for name := range links {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c, err := decode(links[name])
+		if err != nil {
+			return "", err
+		}
+		child, err := dserv.Get(ctx, c)
+		if err != nil {
+			return "", err
+		}
+		if err := shard.Set(ctx, name, child); err != nil {
+			return "", err
+		}
+	}
+	return save(ctx, dserv, shard)
+}
+
+// RmLink will remove a link from a directory. A basic
+// UNIXFS directory is converted to a HAMT directory before
+// the link is removed.
+//
+// It returns the CID of the updated directory.
+func RmLink(ctx context.Context, dserv ipld.DAGService, dirCID, name string) (string, error) {
+	shard, err := load(ctx, dserv, dirCID)
+	if err != nil {
+		return "", err
+	}
+	if err := shard.Remove(ctx, name); err != nil {
+		if err == os.ErrNotExist {
+			return "", fmt.Errorf("%v: %v", ErrLinkNotFound, name)
+		}
+		return "", err
+	}
+	return save(ctx, dserv, shard)
+}
+
+// ForEachLink will call the provided function for each
+// link in a directory. HAMT shards are fetched as the
+// links are walked, so the whole directory is never held
+// in memory at once. Basic UNIXFS directories are also
+// supported.
+func ForEachLink(ctx context.Context, dserv ipld.DAGService, dirCID string, f func(*ipld.Link) error) error {
+	node, fsNode, err := get(ctx, dserv, dirCID)
+	if err != nil {
+		return err
+	}
+	switch fsNode.Type() {
+	case unixfs.THAMTShard:
+		shard, err := hamt.NewHamtFromDag(dserv, node)
+		if err != nil {
+			return err
+		}
+		return shard.ForEachLink(ctx, f)
+	case unixfs.TDirectory:
+		for _, link := range node.Links() {
+			if err := f(link); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("%v: %v", ErrNotDirectory, dirCID)
+	}
+}
+
+// GetLinks returns all the links in a directory.
+func GetLinks(ctx context.Context, dserv ipld.DAGService, dirCID string) ([]*ipld.Link, error) {
+	var links []*ipld.Link
+	err := ForEachLink(ctx, dserv, dirCID, func(link *ipld.Link) error {
+		links = append(links, link)
+		return nil
+	})
+	return links, err
+}
+
+// ChildShards returns the links from a HAMT directory
+// node to its child shards, leaving out the links to
+// directory entries. A basic UNIXFS directory has no
+// child shards.
+func ChildShards(node *merkle.ProtoNode) ([]*ipld.Link, error) {
+	fsNode, err := unixfs.FSNodeFromBytes(node.Data())
+	if err != nil {
+		return nil, err
+	}
+	switch fsNode.Type() {
+	case unixfs.THAMTShard:
+		padLen := len(fmt.Sprintf("%X", fsNode.Fanout()-1))
+		var shards []*ipld.Link
+		for _, link := range node.Links() {
+			if len(link.Name) == padLen {
+				shards = append(shards, link)
+			}
+		}
+		return shards, nil
+	case unixfs.TDirectory:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("%v: %v", ErrNotDirectory, node.Cid())
+	}
+}
+
+// load is a helper function that returns the HAMT for a
+// directory, converting basic UNIXFS directories. The
+// links of a basic directory are moved into the HAMT
+// without fetching the linked nodes.
+func load(ctx context.Context, dserv ipld.DAGService, dirCID string) (*hamt.Shard, error) {
+	node, fsNode, err := get(ctx, dserv, dirCID)
+	if err != nil {
+		return nil, err
+	}
+	switch fsNode.Type() {
+	case unixfs.THAMTShard:
+		return hamt.NewHamtFromDag(dserv, node)
+	case unixfs.TDirectory:
+		shard, err := hamt.NewShard(&linkDAG{dserv}, DefaultShardWidth)
+		if err != nil {
+			return nil, err
+		}
+		for _, link := range node.Links() {
+			if err := shard.Set(ctx, link.Name, &linkNode{link: link}); err != nil {
+				return nil, err
+			}
+		}
+		return shard, nil
+	default:
+		return nil, fmt.Errorf("%v: %v", ErrNotDirectory, dirCID)
+	}
+}
+
+// get is a helper function that collects a directory
+// node and its UNIXFS metadata from the DAG service.
+func get(ctx context.Context, dserv ipld.DAGService, dirCID string) (*merkle.ProtoNode, *unixfs.FSNode, error) {
+	c, err := decode(dirCID)
+	if err != nil {
+		return nil, nil, err
+	}
+	node, err := dserv.Get(ctx, c)
+	if err != nil {
+		return nil, nil, err
+	}
+	protoNode, ok := node.(*merkle.ProtoNode)
+	if !ok {
+		return nil, nil, fmt.Errorf("%v: %v", ErrNotDirectory, dirCID)
+	}
+	fsNode, err := unixfs.FSNodeFromBytes(protoNode.Data())
+	if err != nil {
+		return nil, nil, err
+	}
+	return protoNode, fsNode, nil
+}
+
+// save is a helper function that writes a HAMT to the
+// DAG service and returns the CID of its root node.
+func save(ctx context.Context, dserv ipld.DAGService, shard *hamt.Shard) (string, error) {
+	node, err := shard.Node()
+	if err != nil {
+		return "", err
+	}
+	if err := dserv.Add(ctx, node); err != nil {
+		return "", err
+	}
+	return node.Cid().String(), nil
+}
+
+// decode is a helper function that decodes a CID string,
+// which may be prefixed with /ipfs/.
+func decode(cidStr string) (cid.Cid, error) {
+	return cid.Decode(strings.TrimPrefix(strings.TrimPrefix(cidStr, "/ipfs/"), "/"))
+}
+
+// linkNode stands in for the node of an existing link
+// when the link is moved into a HAMT, so that the node
+// doesn't need to be fetched. Only the CID and size of
+// the node are available.
+type linkNode struct {
+	ipld.Node
+	link *ipld.Link
+}
+
+// Cid returns the CID of the linked node.
+func (node *linkNode) Cid() cid.Cid {
+	return node.link.Cid
+}
+
+// Size returns the cumulative size of the linked node.
+func (node *linkNode) Size() (uint64, error) {
+	return node.link.Size, nil
+}
+
+// linkDAG is a DAG service which skips adding linkNodes,
+// as the nodes they stand in for are already held.
+type linkDAG struct {
+	ipld.DAGService
+}
+
+// Add will add a node to the DAG service, unless it is
+// a linkNode.
+func (dag *linkDAG) Add(ctx context.Context, node ipld.Node) error {
+	if _, ok := node.(*linkNode); ok {
+		return nil
+	}
+	return dag.DAGService.Add(ctx, node)
+}
@@ -0,0 +1,131 @@
+package shard
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	ipld "github.com/ipfs/go-ipld-format"
+	merkle "github.com/ipfs/go-merkledag"
+	mdtest "github.com/ipfs/go-merkledag/test"
+	unixfs "github.com/ipfs/go-unixfs"
+)
+
+var (
+	numLinks = 1000
+)
+
+// TestDirectory will test adding, replacing and removing links.
+func TestDirectory(t *testing.T) {
+	ctx := context.Background()
+	dserv := mdtest.Mock()
+	dirCID, err := NewDirectory(ctx, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// add enough links to split the directory into shards
+	child := merkle.NodeWithData([]byte("child"))
+	if err := dserv.Add(ctx, child); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < numLinks; i++ {
+		if dirCID, err = AddLink(ctx, dserv, dirCID, child.Cid().String(), fmt.Sprintf("key %d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if dirCID, err = AddLink(ctx, dserv, dirCID, child.Cid().String(), "key 0"); err != nil {
+		t.Fatal(err)
+	}
+	links, err := GetLinks(ctx, dserv, dirCID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != numLinks {
+		t.Fatalf("expected %d links, got %d", numLinks, len(links))
+	}
+	root, _, err := get(ctx, dserv, dirCID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shards, err := ChildShards(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shards) == 0 {
+		t.Fatal("directory was not split into shards")
+	}
+
+	// remove a link
+	if dirCID, err = RmLink(ctx, dserv, dirCID, "key 1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RmLink(ctx, dserv, dirCID, "key 1"); err == nil {
+		t.Fatal("removed a missing link")
+	}
+	count := 0
+	if err := ForEachLink(ctx, dserv, dirCID, func(link *ipld.Link) error {
+		if link.Name == "key 1" {
+			return fmt.Errorf("removed link still present")
+		}
+		count++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if count != numLinks-1 {
+		t.Fatalf("expected %d links, got %d", numLinks-1, count)
+	}
+}
+
+// TestBasicDirectory will test a basic UNIXFS directory
+// is read and then converted on write, without fetching
+// the linked nodes.
+func TestBasicDirectory(t *testing.T) {
+	ctx := context.Background()
+	dserv := mdtest.Mock()
+	child := merkle.NodeWithData([]byte("child"))
+	if err := dserv.Add(ctx, child); err != nil {
+		t.Fatal(err)
+	}
+	missing := merkle.NodeWithData([]byte("not held"))
+	dir := unixfs.EmptyDirNode()
+	if err := dir.AddNodeLink("key A", child); err != nil {
+		t.Fatal(err)
+	}
+	if err := dir.AddNodeLink("key C", missing); err != nil {
+		t.Fatal(err)
+	}
+	if err := dserv.Add(ctx, dir); err != nil {
+		t.Fatal(err)
+	}
+	links, err := GetLinks(ctx, dserv, dir.Cid().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 2 {
+		t.Fatal("could not read basic directory")
+	}
+	dirCID, err := AddLink(ctx, dserv, dir.Cid().String(), child.Cid().String(), "key B")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, fsNode, err := get(ctx, dserv, dirCID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fsNode.Type() != unixfs.THAMTShard {
+		t.Fatal("basic directory was not converted to a HAMT directory")
+	}
+	if links, err = GetLinks(ctx, dserv, dirCID); err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 3 {
+		t.Fatalf("expected 3 links after conversion, got %d", len(links))
+	}
+	for _, link := range links {
+		if link.Name == "key C" && link.Cid != missing.Cid() {
+			t.Fatal("link to a node which is not held was not converted")
+		}
+	}
+}
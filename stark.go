@@ -10,7 +10,7 @@ Features include:
 
 - use PubSub messaging to share and collect data records as they are created
 
-- track record history and rollback revisions (rollback feature WIP)
+- track record history and rollback revisions
 
 - attach and sync files to records (WIP)
 
@@ -23,8 +23,9 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
-	starkipfs "github.com/will-rowe/stark/src/ipfs"
+	starkhelpers "github.com/will-rowe/stark/src/helpers"
 )
 
 const (
@@ -55,34 +56,133 @@ const (
 
 	// DefaultStarkEnvVariable is the env variable starkDB looks for when told to use encryption.
 	DefaultStarkEnvVariable = "STARK_DB_PASSWORD"
+
+	// DefaultStarkNewEnvVariable is the env variable the stark rekey command reads the new password from.
+	DefaultStarkNewEnvVariable = "STARK_DB_NEW_PASSWORD"
+
+	// AnnouncementSchemaVersion is the version of the Announcement schema used by this package.
+	AnnouncementSchemaVersion = 1
+
+	// DefaultInlineRecordSize is the maximum size (in bytes) of a Record which is included in an Announcement.
+	DefaultInlineRecordSize = 2048
+
+	// DefaultSyncTimeout is the maximum time allowed for applying a Record during auto sync.
+	DefaultSyncTimeout = 10 * time.Second
+
+	// DefaultProjectMetaTimeout is the maximum time allowed for fetching the project metadata.
+	DefaultProjectMetaTimeout = 30 * time.Second
+
+	// DefaultShardTimeout is the maximum time allowed between project directory links arriving when a snapshot is loaded.
+	DefaultShardTimeout = 2 * time.Second
+
+	// DefaultIPNSKeyPrefix is prefixed to the project name to give the keystore key used for publishing the project to IPNS.
+	DefaultIPNSKeyPrefix = "stark-"
+
+	// DefaultIPNSTimeout is the maximum time allowed for publishing or resolving an IPNS name.
+	DefaultIPNSTimeout = 60 * time.Second
+
+	// DefaultMirrorInterval is the time between checks for a new head snapshot when mirroring.
+	DefaultMirrorInterval = 1 * time.Minute
+
+	// DefaultSyncWait is the time SyncFromPeers waits for project peers to announce their head snapshots.
+	DefaultSyncWait = 30 * time.Second
+
+	// DefaultRotateBatchSize is the number of Records re-encrypted by RotateKey between snapshots.
+	DefaultRotateBatchSize = 100
+
+	// DefaultSnapshotInterval is the time between head snapshot announcements when announcing.
+	DefaultSnapshotInterval = 30 * time.Second
+
+	// DefaultForkSeparator is used to build the key for a forked Record (key + separator + CID).
+	DefaultForkSeparator = ".fork-"
+
+	// SnapshotParentLink is the reserved link name used to link a project snapshot to its parent snapshot.
+	SnapshotParentLink = "_parentSnapshot"
+
+	// SnapshotMetaLink is the reserved link name used to link a project snapshot to its metadata.
+	SnapshotMetaLink = "_snapshotMeta"
+
+	// ProjectMetaLink is the reserved link name used to link a project snapshot to the project metadata (e.g. the key derivation parameters).
+	ProjectMetaLink = "_projectMeta"
+
+	// FieldUUID names the Record UUID field in an encryption policy.
+	FieldUUID = "uuid"
+
+	// FieldAlias names the Record alias field in an encryption policy.
+	FieldAlias = "alias"
+
+	// FieldDescription names the Record description field in an encryption policy.
+	FieldDescription = "description"
+
+	// FieldSequencerOutputDir names the Record localSequencerOutputDir field in an encryption policy.
+	FieldSequencerOutputDir = "localSequencerOutputDir"
+
+	// FieldLinkedSamples names the Record linkedSamples field in an encryption policy.
+	FieldLinkedSamples = "linkedSamples"
+
+	// FieldLinkedLibraries names the Record linkedLibraries field in an encryption policy.
+	FieldLinkedLibraries = "linkedLibraries"
+
+	// FieldBarcodes names the Record barcodes field in an encryption policy.
+	FieldBarcodes = "barcodes"
+
+	// FieldHistory names the text of the Record history comments in an encryption policy.
+	FieldHistory = "history"
 )
 
 var (
 
+	// ErrAnnouncement is issued when a PubSub message is not a valid Announcement.
+	ErrAnnouncement = fmt.Errorf("invalid announcement received")
+
+	// ErrAnnouncementVersion is issued when an Announcement has an unsupported schema version.
+	ErrAnnouncementVersion = fmt.Errorf("unsupported announcement schema version")
+
 	// ErrAttemptedOverwrite indicates a starkDB key is already in use for a Record with non-matching UUID.
 	ErrAttemptedOverwrite = fmt.Errorf("starkDB key is already in use for a Record with non-matching UUID")
 
 	// ErrAttemptedUpdate indicates a Record with matching UUID is already in the IPFS and has a more recent update timestamp.
 	ErrAttemptedUpdate = fmt.Errorf("cannot update a Record in starkDB with an older version")
 
+	// ErrBatchDuplicate is issued when a key is used more than once in a batch.
+	ErrBatchDuplicate = fmt.Errorf("key is used more than once in the batch")
+
 	// ErrBootstrappers is issued when not enough bootstrappers are accessible.
 	ErrBootstrappers = fmt.Errorf("not enough bootstrappers found (minimum required: %d)", DefaultMinBootstrappers)
 
+	// ErrCipherKey is issued when a cipher key can't be derived for an encrypted DB instance.
+	ErrCipherKey = fmt.Errorf("could not derive cipher key")
+
 	// ErrCipherPasswordMismatch is issued when a password does not decrypt a Record.
 	ErrCipherPasswordMismatch = fmt.Errorf("provided password cannot decrypt Record")
 
 	// ErrDbOption is issued for incorrect database initialisation options.
 	ErrDbOption = fmt.Errorf("starkDB option could not be set")
 
+	// ErrDeleteConflict is issued when an announced delete does not match the Record held for the key.
+	ErrDeleteConflict = fmt.Errorf("deleted Record does not match the Record held for the key")
+
+	// ErrEmptyBatch is issued when a batch contains no KeyRecordPairs.
+	ErrEmptyBatch = fmt.Errorf("no KeyRecordPairs were provided in the batch")
+
 	// ErrEncrypted is issued when an encryption is attempted on an encrypted Record.
 	ErrEncrypted = fmt.Errorf("data is encrypted with passphrase")
 
+	// ErrHistoricOpt is issued when a historic time is requested without a snapshot to search from.
+	ErrHistoricOpt = fmt.Errorf("can't use WithHistoricTime without a snapshot CID")
+
 	// ErrInvalidSnapshot indicates a snapshotted IPFS DAG node can't be accessed.
 	ErrInvalidSnapshot = fmt.Errorf("cannot access the database snapshot")
 
 	// ErrLinkExists indicates a Record is already linked to the provided UUID.
 	ErrLinkExists = fmt.Errorf("Record already linked to the provided UUID")
 
+	// ErrMirrorOpt is issued for a db option mirroring conflict.
+	ErrMirrorOpt = fmt.Errorf("can't use WithMirror when WithNoPinning")
+
+	// ErrNoBackend indicates no storage backend was provided.
+	ErrNoBackend = fmt.Errorf("no storage backend was provided")
+
 	// ErrNoCID indicates no CID was provided.
 	ErrNoCID = fmt.Errorf("no CID was provided")
 
@@ -94,6 +194,9 @@ var (
 		return fmt.Errorf("key not found: %v", key)
 	}
 
+	// ErrNotPinned is issued by a Backend when an unpin is requested for a CID which is not pinned (Backends can wrap it).
+	ErrNotPinned = starkhelpers.ErrNotPinned
+
 	// ErrNodeFormat is issued when a CID points to a node with an unsupported format.
 	ErrNodeFormat = fmt.Errorf("database entry points to a non-CBOR node")
 
@@ -103,15 +206,39 @@ var (
 	// ErrNodeOnline indicates the node is online.
 	ErrNodeOnline = fmt.Errorf("IPFS node is online")
 
+	// ErrNotRecipient is issued when a Record shared with recipients was not shared with the database.
+	ErrNotRecipient = fmt.Errorf("database is not a recipient of the Record")
+
 	// ErrNoEnvSet is issued when no env variable is found.
 	ErrNoEnvSet = fmt.Errorf("no %s environment variable found", DefaultStarkEnvVariable)
 
+	// ErrNoConflictPolicy indicates no conflict policy was provided.
+	ErrNoConflictPolicy = fmt.Errorf("no conflict policy was provided")
+
+	// ErrNoCommonAncestor is issued when two snapshots do not share an ancestor in their lineage.
+	ErrNoCommonAncestor = fmt.Errorf("snapshots do not share a common ancestor")
+
+	// ErrNoHistoricTime indicates no time was provided to open a historic database at.
+	ErrNoHistoricTime = fmt.Errorf("no historic time was provided")
+
+	// ErrNoMirror is issued when a mirror is requested but the database was not opened with a mirror source.
+	ErrNoMirror = fmt.Errorf("database was not opened with WithMirror")
+
 	// ErrNoPeerID indicates the IPFS node has no peer ID.
 	ErrNoPeerID = fmt.Errorf("no PeerID listed for the current IPFS node")
 
 	// ErrNoProject indicates no project name was given.
 	ErrNoProject = fmt.Errorf("project name is required for a starkDB")
 
+	// ErrNoRecord indicates no Record was given.
+	ErrNoRecord = fmt.Errorf("no Record was provided")
+
+	// ErrNoRepoPath indicates no IPFS repo path was given.
+	ErrNoRepoPath = fmt.Errorf("IPFS repo path cannot be empty")
+
+	// ErrNoSyncResponse is issued when no project peers announce a snapshot in response to a sync request.
+	ErrNoSyncResponse = fmt.Errorf("no project peers responded to the sync request")
+
 	// ErrNoSub indicates the IPFS node is not registered for PubSub.
 	ErrNoSub = fmt.Errorf("IPFS node has no topic registered for PubSub")
 
@@ -123,6 +250,9 @@ var (
 		return fmt.Errorf("failed to reach Pinata API: %w", err)
 	}
 
+	// ErrOfflineOpt is issued for a db option networking conflict.
+	ErrOfflineOpt = fmt.Errorf("can't use WithAnnouncing, WithAutoSync, WithIPFSAPI, WithIPNS, WithMirror or WithPinata (or attach to a running IPFS daemon) when WithOffline")
+
 	// ErrPinataOpt is issued for a db option pinning conflict.
 	ErrPinataOpt = fmt.Errorf("can't use WithPinata when WithNoPinning")
 
@@ -132,11 +262,56 @@ var (
 	// ErrPinataSecret is issued when the no env variable for the Pinata secret is set.
 	ErrPinataSecret = fmt.Errorf("no %s environment variable found", DefaultPinataSecretKey)
 
+	// ErrProjectMeta is issued when key derivation parameters are not linked to the database snapshot lineage.
+	ErrProjectMeta = fmt.Errorf("project metadata is not linked to the database snapshot lineage")
+
+	// ErrReadOnly is issued when a write is attempted on a database opened at a historic snapshot or as a mirror.
+	ErrReadOnly = fmt.Errorf("database was opened read-only (at a historic snapshot or as a mirror)")
+
+	// ErrReservedKey is issued when a Record key clashes with a reserved snapshot link name.
+	ErrReservedKey = fmt.Errorf("key is reserved for snapshot lineage (%v, %v, %v)", SnapshotParentLink, SnapshotMetaLink, ProjectMetaLink)
+
+	// ErrRecipientExists is issued when a recipient is added to a Record more than once.
+	ErrRecipientExists = fmt.Errorf("recipient has already been added to the Record")
+
+	// ErrRecipientLocal is issued when the database's own recipient key is removed from a Record.
+	ErrRecipientLocal = fmt.Errorf("can't remove the database's own recipient key from a Record")
+
+	// ErrRecipientNotFound is issued when a recipient to remove has not been added to a Record.
+	ErrRecipientNotFound = fmt.Errorf("recipient has not been added to the Record")
+
+	// ErrRecordDeleted is issued when an announced Record was deleted by another database before it was applied.
+	ErrRecordDeleted = fmt.Errorf("Record was deleted by another database")
+
+	// ErrRecordField is issued when an encryption policy names an unknown Record field.
+	ErrRecordField = fmt.Errorf("unknown Record field")
+
 	// ErrRecordHistory indicates two Records with the same UUID a gap in their history.
 	ErrRecordHistory = fmt.Errorf("both Records share UUID but have a gap in their history")
 
+	// ErrRollbackTarget is issued when a rollback target can't be found in a Record's history.
+	ErrRollbackTarget = fmt.Errorf("rollback target not found in Record history")
+
+	// ErrRotationUnfinished is issued when a Record is encrypted with the database password while a cipher key rotation is unfinished.
+	ErrRotationUnfinished = fmt.Errorf("cipher key rotation is unfinished, resume RotateKey before writing encrypted Records")
+
+	// ErrSyncActive is issued when SyncFromPeers is called while auto sync holds the PubSub subscription.
+	ErrSyncActive = fmt.Errorf("can't run SyncFromPeers while auto sync is listening")
+
+	// ErrSignature is issued when a Record signature can't be verified.
+	ErrSignature = fmt.Errorf("Record signature is invalid")
+
+	// ErrSnapshotNotFound is issued when no snapshot can be found for the requested time.
+	ErrSnapshotNotFound = fmt.Errorf("no snapshot found at or before the requested time")
+
 	// ErrSnapshotUpdate is issued when a link can't be made between the new Record and existing project base node.
 	ErrSnapshotUpdate = fmt.Errorf("could not update database snapshot")
+
+	// ErrUnsigned is issued when a Record has not been signed.
+	ErrUnsigned = fmt.Errorf("Record is not signed")
+
+	// ErrUntrustedAuthor is issued when a Record is signed by an author who is not trusted by the database.
+	ErrUntrustedAuthor = fmt.Errorf("Record author is not trusted")
 )
 
 // Db is the starkDB database.
@@ -144,22 +319,63 @@ type Db struct {
 	sync.RWMutex // protects access to the bound IPFS node
 	ctx          context.Context
 	ctxCancel    context.CancelFunc
-	ipfsClient   *starkipfs.Client // wraps the IPFS core API, node and PubSub channels
+	backend      Backend           // wraps the storage API, node and PubSub channels (IPFS client by default)
 	cidLookup    map[string]string // quick access to Record CIDs using user-supplied keys
 
+	// background workers
+	syncTerminator chan struct{}       // closed to stop the auto sync listener
+	syncDone       chan struct{}       // closed once the auto sync listener has stopped
+	announcerStop  chan struct{}       // closed to stop the head snapshot announcer
+	announcerDone  chan struct{}       // closed once the head snapshot announcer has stopped
+	ipnsPublishing sync.WaitGroup      // tracks IPNS publishing started by Set
+	ipnsLock       sync.Mutex          // serialises IPNS publishing
+	mirrorCancel   context.CancelFunc  // cancels the mirror
+	mirrorDone     chan struct{}       // closed once the mirror has stopped
+	mirrorLock     sync.Mutex          // serialises mirroring
+	mirrored       map[string]struct{} // the CIDs pinned by the mirror during this session
+
+	// cipher keys
+	keyLock       sync.Mutex          // protects the cipher key cache, project metadata and recipient keys
+	cipherKeys    map[string][]byte   // the cipher keys derived during this session (KDF CID->key, empty CID = MD5 key)
+	projectMetas  map[string]struct{} // the project metadata CIDs found in the snapshot lineage
+	metaLineage   map[string]struct{} // the snapshots checked for project metadata (along with their ancestors)
+	recipientPriv []byte              // the private key used to unwrap data keys shared with the database
+	recipientKey  string              // the public recipient key for the database
+
+	// pending changes announced by other databases
+	pendingDeletes   map[string]string // key->CID for deletes announced before the Record arrived
+	pendingSnapshots map[string]string // sender->CID for announced snapshots which could not be merged
+
 	// user-defined settings
-	project        string           // the project which the database instance is managing
-	peers          []string         // list of addresses to use for IPFS peer discovery
-	snapshotCID    string           // the optional snapshot CID provided during database opening
-	pinning        bool             // if true, IPFS IO will be done with pinning
-	pinataInterval int              // the number of set operations permitted between pinata pinning (-1 = no pinata pinning)
-	announcing     bool             // if true, new records added to the IPFS will be broadcast on the pubsub topic for this project
-	cipherKey      []byte           // cipher key for encrypted DB instances
-	loggingChan    chan interface{} // user provided channel to collect logging info from database internals
+	project          string           // the project which the database instance is managing
+	peers            []string         // list of addresses to use for IPFS peer discovery
+	snapshotCID      string           // the optional snapshot CID provided during database opening
+	repoPath         string           // the IPFS repo to use (empty = system default repo)
+	apiAddr          string           // the HTTP API multiaddr of a running IPFS daemon to attach to (empty = run a node in-process)
+	pinning          bool             // if true, IPFS IO will be done with pinning
+	pinataInterval   int              // the number of set operations permitted between pinata pinning (-1 = no pinata pinning)
+	ipnsInterval     int              // the number of set operations permitted between IPNS publishing (<1 = no IPNS publishing)
+	ipnsName         string           // the IPNS name the project is published under (empty = no IPNS publishing)
+	announcing       bool             // if true, new records added to the IPFS will be broadcast on the pubsub topic for this project
+	autoSync         bool             // if true, records announced on the pubsub topic for this project are applied to the database
+	snapshotInterval time.Duration    // the time between head snapshot announcements (<=0 = no snapshot announcements)
+	offline          bool             // if true, the IPFS node is built with networking disabled and no bootstrapping occurs
+	readOnly         bool             // if true, the database was opened at a historic snapshot (or as a mirror) and writes are rejected
+	mirrorSource     string           // the snapshot CID or IPNS name to mirror (empty = not mirroring)
+	historicTime     time.Time        // the optional time to open the database at (zero = open at the provided snapshot)
+	password         string           // the password used to derive cipher keys for encrypted DB instances
+	cipherKey        []byte           // cipher key for encrypted DB instances
+	kdfID            string           // the CID of the key derivation parameters used for the cipher key
+	rotating         bool             // if true, a cipher key rotation is unfinished and Records can't be encrypted with the cipher key
+	clearFields      []string         // the Record fields left unencrypted by encrypted DB instances
+	conflictPolicy   ConflictPolicy   // decides how to handle conflicting Records received from other databases
+	requireSigned    bool             // if true, unsigned Records are rejected when they are retrieved
+	trustedAuthors   map[string]bool  // the peer IDs of the authors whose Records are accepted (empty = any author)
+	loggingChan      chan interface{} // user provided channel to collect logging info from database internals
 
 	// db stats
 	currentNumEntries int // the number of keys in the keystore (checked on db open and then incremented/decremented during Set/Delete ops)
-	sessionEntries    int // the number of keys added during the current database instance (not decremented after Delete ops)
+	sessionEntries    int // the number of Record writes (including Delete ops) made during the current database instance
 }
 
 // DbOption is a wrapper struct used to pass functional
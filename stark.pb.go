@@ -85,6 +85,61 @@ func (Status) EnumDescriptor() ([]byte, []int) {
 	return file_stark_proto_rawDescGZIP(), []int{0}
 }
 
+type Announcement_Type int32
+
+const (
+	Announcement_UNKNOWN      Announcement_Type = 0
+	Announcement_SET          Announcement_Type = 1 // a Record was added or updated
+	Announcement_DELETE       Announcement_Type = 2 // a Record was deleted
+	Announcement_SNAPSHOT     Announcement_Type = 3 // the head snapshot of the database
+	Announcement_SYNC_REQUEST Announcement_Type = 4 // a request for listening databases to announce their head snapshot
+)
+
+// Enum value maps for Announcement_Type.
+var (
+	Announcement_Type_name = map[int32]string{
+		0: "UNKNOWN",
+		1: "SET",
+		2: "DELETE",
+		3: "SNAPSHOT",
+		4: "SYNC_REQUEST",
+	}
+	Announcement_Type_value = map[string]int32{
+		"UNKNOWN":      0,
+		"SET":          1,
+		"DELETE":       2,
+		"SNAPSHOT":     3,
+		"SYNC_REQUEST": 4,
+	}
+)
+
+func (x Announcement_Type) Enum() *Announcement_Type {
+	p := new(Announcement_Type)
+	*p = x
+	return p
+}
+
+func (x Announcement_Type) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Announcement_Type) Descriptor() protoreflect.EnumDescriptor {
+	return file_stark_proto_enumTypes[1].Descriptor()
+}
+
+func (Announcement_Type) Type() protoreflect.EnumType {
+	return &file_stark_proto_enumTypes[1]
+}
+
+func (x Announcement_Type) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Announcement_Type.Descriptor instead.
+func (Announcement_Type) EnumDescriptor() ([]byte, []int) {
+	return file_stark_proto_rawDescGZIP(), []int{16, 0}
+}
+
 type KeyRecordPair struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -242,34 +297,18 @@ func (x *Response) GetRecord() *Record {
 	return nil
 }
 
-//
-//Record.
-//
-//This message is used to describe a sequencing metadata.
-//Record's will be able to encode runs, samples and libraries.
-//WIP.
-type Record struct {
+type BatchResponse struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	// reserved:
-	Uuid        string           `protobuf:"bytes,1,opt,name=uuid,proto3" json:"uuid,omitempty"`                        // universally unique id for the record
-	PreviousCID string           `protobuf:"bytes,2,opt,name=previousCID,proto3" json:"previousCID,omitempty"`          // the last known CID this record was pulled from (set during a Get operation)
-	History     []*RecordComment `protobuf:"bytes,3,rep,name=history,proto3" json:"history,omitempty"`                  // describes the history of the record - can be used to get timestamps for creation and last updated
-	Encrypted   bool             `protobuf:"varint,4,opt,name=encrypted,proto3" json:"encrypted,omitempty"`             // set true to indicate if fields have been encrypted
-	Status      Status           `protobuf:"varint,5,opt,name=status,proto3,enum=stark.Status" json:"status,omitempty"` // describes if untagged/tagged/etc.
-	// user updateable:
-	Alias                   string            `protobuf:"bytes,6,opt,name=alias,proto3" json:"alias,omitempty"`                                                                                                              // the record name / human readable id (used as the default RecordKey ID)
-	Description             string            `protobuf:"bytes,7,opt,name=description,proto3" json:"description,omitempty"`                                                                                                  // a short description of the record
-	LocalSequencerOutputDir string            `protobuf:"bytes,9,opt,name=localSequencerOutputDir,proto3" json:"localSequencerOutputDir,omitempty"`                                                                          // where the sequencer is outputing data for this record
-	LinkedSamples           map[string]string `protobuf:"bytes,10,rep,name=linkedSamples,proto3" json:"linkedSamples,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`     // all samples linked to this record (map relates sample UUIDs to a metadata location (e.g. a CID))
-	LinkedLibraries         map[string]string `protobuf:"bytes,11,rep,name=linkedLibraries,proto3" json:"linkedLibraries,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"` // all libraries linked to this record (map relates library UUIDs to a metadata location (e.g. a CID))
-	Barcodes                map[string]int32  `protobuf:"bytes,12,rep,name=barcodes,proto3" json:"barcodes,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`              // all barcodes used by this record (map links library UUID to barcode for that library)
+	Success     bool             `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Pairs       []*KeyRecordPair `protobuf:"bytes,2,rep,name=pairs,proto3" json:"pairs,omitempty"`             // the Records added to the database (in the order they were sent, with their new CIDs)
+	SnapshotCID string           `protobuf:"bytes,3,opt,name=snapshotCID,proto3" json:"snapshotCID,omitempty"` // the CID of the database snapshot after the batch
 }
 
-func (x *Record) Reset() {
-	*x = Record{}
+func (x *BatchResponse) Reset() {
+	*x = BatchResponse{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_stark_proto_msgTypes[3]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -277,13 +316,13 @@ func (x *Record) Reset() {
 	}
 }
 
-func (x *Record) String() string {
+func (x *BatchResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Record) ProtoMessage() {}
+func (*BatchResponse) ProtoMessage() {}
 
-func (x *Record) ProtoReflect() protoreflect.Message {
+func (x *BatchResponse) ProtoReflect() protoreflect.Message {
 	mi := &file_stark_proto_msgTypes[3]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -295,125 +334,193 @@ func (x *Record) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Record.ProtoReflect.Descriptor instead.
-func (*Record) Descriptor() ([]byte, []int) {
+// Deprecated: Use BatchResponse.ProtoReflect.Descriptor instead.
+func (*BatchResponse) Descriptor() ([]byte, []int) {
 	return file_stark_proto_rawDescGZIP(), []int{3}
 }
 
-func (x *Record) GetUuid() string {
+func (x *BatchResponse) GetSuccess() bool {
 	if x != nil {
-		return x.Uuid
+		return x.Success
 	}
-	return ""
+	return false
 }
 
-func (x *Record) GetPreviousCID() string {
+func (x *BatchResponse) GetPairs() []*KeyRecordPair {
 	if x != nil {
-		return x.PreviousCID
+		return x.Pairs
 	}
-	return ""
+	return nil
 }
 
-func (x *Record) GetHistory() []*RecordComment {
+func (x *BatchResponse) GetSnapshotCID() string {
 	if x != nil {
-		return x.History
+		return x.SnapshotCID
 	}
-	return nil
+	return ""
 }
 
-func (x *Record) GetEncrypted() bool {
-	if x != nil {
-		return x.Encrypted
+type RollbackRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key       string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	TargetCID string `protobuf:"bytes,2,opt,name=targetCID,proto3" json:"targetCID,omitempty"` // the CID of the Record version to restore (takes precedence over steps)
+	Steps     int32  `protobuf:"varint,3,opt,name=steps,proto3" json:"steps,omitempty"`        // the number of versions to step back through the Record history
+}
+
+func (x *RollbackRequest) Reset() {
+	*x = RollbackRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_stark_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
-	return false
 }
 
-func (x *Record) GetStatus() Status {
-	if x != nil {
-		return x.Status
+func (x *RollbackRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RollbackRequest) ProtoMessage() {}
+
+func (x *RollbackRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_stark_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return Status_UN_INITIALIZED
+	return mi.MessageOf(x)
 }
 
-func (x *Record) GetAlias() string {
+// Deprecated: Use RollbackRequest.ProtoReflect.Descriptor instead.
+func (*RollbackRequest) Descriptor() ([]byte, []int) {
+	return file_stark_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *RollbackRequest) GetKey() string {
 	if x != nil {
-		return x.Alias
+		return x.Key
 	}
 	return ""
 }
 
-func (x *Record) GetDescription() string {
+func (x *RollbackRequest) GetTargetCID() string {
 	if x != nil {
-		return x.Description
+		return x.TargetCID
 	}
 	return ""
 }
 
-func (x *Record) GetLocalSequencerOutputDir() string {
+func (x *RollbackRequest) GetSteps() int32 {
 	if x != nil {
-		return x.LocalSequencerOutputDir
+		return x.Steps
+	}
+	return 0
+}
+
+type RecordVersion struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Cid     string               `protobuf:"bytes,1,opt,name=cid,proto3" json:"cid,omitempty"`         // the CID of this version of the Record
+	Created *timestamp.Timestamp `protobuf:"bytes,2,opt,name=created,proto3" json:"created,omitempty"` // when the Record was created
+	Updated *timestamp.Timestamp `protobuf:"bytes,3,opt,name=updated,proto3" json:"updated,omitempty"` // when this version of the Record was last updated
+	Record  *Record              `protobuf:"bytes,4,opt,name=record,proto3" json:"record,omitempty"`   // the Record as it was stored at this version
+}
+
+func (x *RecordVersion) Reset() {
+	*x = RecordVersion{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_stark_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RecordVersion) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecordVersion) ProtoMessage() {}
+
+func (x *RecordVersion) ProtoReflect() protoreflect.Message {
+	mi := &file_stark_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecordVersion.ProtoReflect.Descriptor instead.
+func (*RecordVersion) Descriptor() ([]byte, []int) {
+	return file_stark_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *RecordVersion) GetCid() string {
+	if x != nil {
+		return x.Cid
 	}
 	return ""
 }
 
-func (x *Record) GetLinkedSamples() map[string]string {
+func (x *RecordVersion) GetCreated() *timestamp.Timestamp {
 	if x != nil {
-		return x.LinkedSamples
+		return x.Created
 	}
 	return nil
 }
 
-func (x *Record) GetLinkedLibraries() map[string]string {
+func (x *RecordVersion) GetUpdated() *timestamp.Timestamp {
 	if x != nil {
-		return x.LinkedLibraries
+		return x.Updated
 	}
 	return nil
 }
 
-func (x *Record) GetBarcodes() map[string]int32 {
+func (x *RecordVersion) GetRecord() *Record {
 	if x != nil {
-		return x.Barcodes
+		return x.Record
 	}
 	return nil
 }
 
-//
-//DbMeta.
-//
-//This message is used to encode runtime
-//information for a stark database.
-type DbMeta struct {
+type DiffRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Project     string            `protobuf:"bytes,1,opt,name=Project,proto3" json:"Project,omitempty"`                                                                                     // project name for the current database
-	Snapshot    string            `protobuf:"bytes,2,opt,name=Snapshot,proto3" json:"Snapshot,omitempty"`                                                                                   // CID for the current database state
-	NodeAddress string            `protobuf:"bytes,3,opt,name=NodeAddress,proto3" json:"NodeAddress,omitempty"`                                                                             // the IPFS node address for the database
-	Peers       []string          `protobuf:"bytes,4,rep,name=Peers,proto3" json:"Peers,omitempty"`                                                                                         // the IPFS peers in the swarm
-	Pinning     bool              `protobuf:"varint,5,opt,name=Pinning,proto3" json:"Pinning,omitempty"`                                                                                    // if true, database is pinning entries
-	Announcing  bool              `protobuf:"varint,6,opt,name=Announcing,proto3" json:"Announcing,omitempty"`                                                                              // if true, database is announcing entries on PubSub
-	CurrEntries int32             `protobuf:"varint,7,opt,name=CurrEntries,proto3" json:"CurrEntries,omitempty"`                                                                            // current number of entries in the database
-	Pairs       map[string]string `protobuf:"bytes,8,rep,name=Pairs,proto3" json:"Pairs,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"` // pairs of Keys -> Record CIDs held in the database
+	SnapshotA string `protobuf:"bytes,1,opt,name=snapshotA,proto3" json:"snapshotA,omitempty"` // the CID of the first project snapshot
+	SnapshotB string `protobuf:"bytes,2,opt,name=snapshotB,proto3" json:"snapshotB,omitempty"` // the CID of the second project snapshot
+	Fields    bool   `protobuf:"varint,3,opt,name=fields,proto3" json:"fields,omitempty"`      // if true, a field-level diff is produced for changed Records
 }
 
-func (x *DbMeta) Reset() {
-	*x = DbMeta{}
+func (x *DiffRequest) Reset() {
+	*x = DiffRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_stark_proto_msgTypes[4]
+		mi := &file_stark_proto_msgTypes[6]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *DbMeta) String() string {
+func (x *DiffRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*DbMeta) ProtoMessage() {}
+func (*DiffRequest) ProtoMessage() {}
 
-func (x *DbMeta) ProtoReflect() protoreflect.Message {
-	mi := &file_stark_proto_msgTypes[4]
+func (x *DiffRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_stark_proto_msgTypes[6]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -424,98 +531,139 @@ func (x *DbMeta) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use DbMeta.ProtoReflect.Descriptor instead.
-func (*DbMeta) Descriptor() ([]byte, []int) {
-	return file_stark_proto_rawDescGZIP(), []int{4}
+// Deprecated: Use DiffRequest.ProtoReflect.Descriptor instead.
+func (*DiffRequest) Descriptor() ([]byte, []int) {
+	return file_stark_proto_rawDescGZIP(), []int{6}
 }
 
-func (x *DbMeta) GetProject() string {
+func (x *DiffRequest) GetSnapshotA() string {
 	if x != nil {
-		return x.Project
+		return x.SnapshotA
 	}
 	return ""
 }
 
-func (x *DbMeta) GetSnapshot() string {
+func (x *DiffRequest) GetSnapshotB() string {
 	if x != nil {
-		return x.Snapshot
+		return x.SnapshotB
 	}
 	return ""
 }
 
-func (x *DbMeta) GetNodeAddress() string {
+func (x *DiffRequest) GetFields() bool {
 	if x != nil {
-		return x.NodeAddress
+		return x.Fields
 	}
-	return ""
+	return false
 }
 
-func (x *DbMeta) GetPeers() []string {
+type SnapshotDiff struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	SnapshotA string     `protobuf:"bytes,1,opt,name=snapshotA,proto3" json:"snapshotA,omitempty"` // the CID of the first project snapshot
+	SnapshotB string     `protobuf:"bytes,2,opt,name=snapshotB,proto3" json:"snapshotB,omitempty"` // the CID of the second project snapshot
+	Added     []string   `protobuf:"bytes,3,rep,name=added,proto3" json:"added,omitempty"`         // keys found in snapshot B but not in snapshot A
+	Removed   []string   `protobuf:"bytes,4,rep,name=removed,proto3" json:"removed,omitempty"`     // keys found in snapshot A but not in snapshot B
+	Changed   []*KeyDiff `protobuf:"bytes,5,rep,name=changed,proto3" json:"changed,omitempty"`     // keys found in both snapshots but linked to different Record CIDs
+}
+
+func (x *SnapshotDiff) Reset() {
+	*x = SnapshotDiff{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_stark_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SnapshotDiff) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SnapshotDiff) ProtoMessage() {}
+
+func (x *SnapshotDiff) ProtoReflect() protoreflect.Message {
+	mi := &file_stark_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SnapshotDiff.ProtoReflect.Descriptor instead.
+func (*SnapshotDiff) Descriptor() ([]byte, []int) {
+	return file_stark_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *SnapshotDiff) GetSnapshotA() string {
 	if x != nil {
-		return x.Peers
+		return x.SnapshotA
 	}
-	return nil
+	return ""
 }
 
-func (x *DbMeta) GetPinning() bool {
+func (x *SnapshotDiff) GetSnapshotB() string {
 	if x != nil {
-		return x.Pinning
+		return x.SnapshotB
 	}
-	return false
+	return ""
 }
 
-func (x *DbMeta) GetAnnouncing() bool {
+func (x *SnapshotDiff) GetAdded() []string {
 	if x != nil {
-		return x.Announcing
+		return x.Added
 	}
-	return false
+	return nil
 }
 
-func (x *DbMeta) GetCurrEntries() int32 {
+func (x *SnapshotDiff) GetRemoved() []string {
 	if x != nil {
-		return x.CurrEntries
+		return x.Removed
 	}
-	return 0
+	return nil
 }
 
-func (x *DbMeta) GetPairs() map[string]string {
+func (x *SnapshotDiff) GetChanged() []*KeyDiff {
 	if x != nil {
-		return x.Pairs
+		return x.Changed
 	}
 	return nil
 }
 
-//
-//RecordComment.
-//
-//This message is used to describe message history.
-type RecordComment struct {
+type KeyDiff struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Timestamp   *timestamp.Timestamp `protobuf:"bytes,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`     // timestamp for change
-	Text        string               `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`               // description of the change
-	PreviousCID string               `protobuf:"bytes,3,opt,name=previousCID,proto3" json:"previousCID,omitempty"` // last known CID for a Record (used to rollback the Record and undo the newly commented change)
+	Key    string       `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	CidA   string       `protobuf:"bytes,2,opt,name=cidA,proto3" json:"cidA,omitempty"`     // the Record CID in snapshot A
+	CidB   string       `protobuf:"bytes,3,opt,name=cidB,proto3" json:"cidB,omitempty"`     // the Record CID in snapshot B
+	Fields []*FieldDiff `protobuf:"bytes,4,rep,name=fields,proto3" json:"fields,omitempty"` // the changed Record fields (only if requested)
 }
 
-func (x *RecordComment) Reset() {
-	*x = RecordComment{}
+func (x *KeyDiff) Reset() {
+	*x = KeyDiff{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_stark_proto_msgTypes[5]
+		mi := &file_stark_proto_msgTypes[8]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *RecordComment) String() string {
+func (x *KeyDiff) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*RecordComment) ProtoMessage() {}
+func (*KeyDiff) ProtoMessage() {}
 
-func (x *RecordComment) ProtoReflect() protoreflect.Message {
-	mi := &file_stark_proto_msgTypes[5]
+func (x *KeyDiff) ProtoReflect() protoreflect.Message {
+	mi := &file_stark_proto_msgTypes[8]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -526,36 +674,864 @@ func (x *RecordComment) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use RecordComment.ProtoReflect.Descriptor instead.
-func (*RecordComment) Descriptor() ([]byte, []int) {
-	return file_stark_proto_rawDescGZIP(), []int{5}
+// Deprecated: Use KeyDiff.ProtoReflect.Descriptor instead.
+func (*KeyDiff) Descriptor() ([]byte, []int) {
+	return file_stark_proto_rawDescGZIP(), []int{8}
 }
 
-func (x *RecordComment) GetTimestamp() *timestamp.Timestamp {
+func (x *KeyDiff) GetKey() string {
 	if x != nil {
-		return x.Timestamp
+		return x.Key
 	}
-	return nil
+	return ""
 }
 
-func (x *RecordComment) GetText() string {
+func (x *KeyDiff) GetCidA() string {
 	if x != nil {
-		return x.Text
+		return x.CidA
 	}
 	return ""
 }
 
-func (x *RecordComment) GetPreviousCID() string {
+func (x *KeyDiff) GetCidB() string {
 	if x != nil {
-		return x.PreviousCID
+		return x.CidB
 	}
 	return ""
 }
 
-var File_stark_proto protoreflect.FileDescriptor
+func (x *KeyDiff) GetFields() []*FieldDiff {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
 
-var file_stark_proto_rawDesc = []byte{
-	0x0a, 0x0b, 0x73, 0x74, 0x61, 0x72, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x05, 0x73,
+type FieldDiff struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Field  string `protobuf:"bytes,1,opt,name=field,proto3" json:"field,omitempty"`   // the path to the field (e.g. description or history[1].text)
+	ValueA string `protobuf:"bytes,2,opt,name=valueA,proto3" json:"valueA,omitempty"` // the JSON encoded value in snapshot A (empty if unset)
+	ValueB string `protobuf:"bytes,3,opt,name=valueB,proto3" json:"valueB,omitempty"` // the JSON encoded value in snapshot B (empty if unset)
+}
+
+func (x *FieldDiff) Reset() {
+	*x = FieldDiff{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_stark_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *FieldDiff) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FieldDiff) ProtoMessage() {}
+
+func (x *FieldDiff) ProtoReflect() protoreflect.Message {
+	mi := &file_stark_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FieldDiff.ProtoReflect.Descriptor instead.
+func (*FieldDiff) Descriptor() ([]byte, []int) {
+	return file_stark_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *FieldDiff) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+func (x *FieldDiff) GetValueA() string {
+	if x != nil {
+		return x.ValueA
+	}
+	return ""
+}
+
+func (x *FieldDiff) GetValueB() string {
+	if x != nil {
+		return x.ValueB
+	}
+	return ""
+}
+
+type MergeRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Ancestor string `protobuf:"bytes,1,opt,name=ancestor,proto3" json:"ancestor,omitempty"` // the CID of the common ancestor snapshot (found using the snapshot lineage if empty)
+	HeadA    string `protobuf:"bytes,2,opt,name=headA,proto3" json:"headA,omitempty"`       // the CID of the first snapshot head (the current database snapshot if empty)
+	HeadB    string `protobuf:"bytes,3,opt,name=headB,proto3" json:"headB,omitempty"`       // the CID of the second snapshot head
+	DryRun   bool   `protobuf:"varint,4,opt,name=dryRun,proto3" json:"dryRun,omitempty"`    // if true, the merged snapshot is not applied to the database
+}
+
+func (x *MergeRequest) Reset() {
+	*x = MergeRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_stark_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MergeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MergeRequest) ProtoMessage() {}
+
+func (x *MergeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_stark_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MergeRequest.ProtoReflect.Descriptor instead.
+func (*MergeRequest) Descriptor() ([]byte, []int) {
+	return file_stark_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *MergeRequest) GetAncestor() string {
+	if x != nil {
+		return x.Ancestor
+	}
+	return ""
+}
+
+func (x *MergeRequest) GetHeadA() string {
+	if x != nil {
+		return x.HeadA
+	}
+	return ""
+}
+
+func (x *MergeRequest) GetHeadB() string {
+	if x != nil {
+		return x.HeadB
+	}
+	return ""
+}
+
+func (x *MergeRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+type MergeResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Success     bool             `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`        // true if all conflicting keys were resolved
+	SnapshotCID string           `protobuf:"bytes,2,opt,name=snapshotCID,proto3" json:"snapshotCID,omitempty"` // the CID of the merged snapshot
+	Ancestor    string           `protobuf:"bytes,3,opt,name=ancestor,proto3" json:"ancestor,omitempty"`       // the CID of the common ancestor snapshot used for the merge
+	Conflicts   []*MergeConflict `protobuf:"bytes,4,rep,name=conflicts,proto3" json:"conflicts,omitempty"`     // the keys which could not be resolved (head A is kept for these)
+}
+
+func (x *MergeResponse) Reset() {
+	*x = MergeResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_stark_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MergeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MergeResponse) ProtoMessage() {}
+
+func (x *MergeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_stark_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MergeResponse.ProtoReflect.Descriptor instead.
+func (*MergeResponse) Descriptor() ([]byte, []int) {
+	return file_stark_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *MergeResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *MergeResponse) GetSnapshotCID() string {
+	if x != nil {
+		return x.SnapshotCID
+	}
+	return ""
+}
+
+func (x *MergeResponse) GetAncestor() string {
+	if x != nil {
+		return x.Ancestor
+	}
+	return ""
+}
+
+func (x *MergeResponse) GetConflicts() []*MergeConflict {
+	if x != nil {
+		return x.Conflicts
+	}
+	return nil
+}
+
+type MergeConflict struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key         string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	CidAncestor string `protobuf:"bytes,2,opt,name=cidAncestor,proto3" json:"cidAncestor,omitempty"` // the Record CID in the ancestor snapshot (empty if not present)
+	CidA        string `protobuf:"bytes,3,opt,name=cidA,proto3" json:"cidA,omitempty"`               // the Record CID in head A (empty if not present)
+	CidB        string `protobuf:"bytes,4,opt,name=cidB,proto3" json:"cidB,omitempty"`               // the Record CID in head B (empty if not present)
+	Reason      string `protobuf:"bytes,5,opt,name=reason,proto3" json:"reason,omitempty"`           // why the conflict could not be resolved
+}
+
+func (x *MergeConflict) Reset() {
+	*x = MergeConflict{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_stark_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MergeConflict) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MergeConflict) ProtoMessage() {}
+
+func (x *MergeConflict) ProtoReflect() protoreflect.Message {
+	mi := &file_stark_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MergeConflict.ProtoReflect.Descriptor instead.
+func (*MergeConflict) Descriptor() ([]byte, []int) {
+	return file_stark_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *MergeConflict) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *MergeConflict) GetCidAncestor() string {
+	if x != nil {
+		return x.CidAncestor
+	}
+	return ""
+}
+
+func (x *MergeConflict) GetCidA() string {
+	if x != nil {
+		return x.CidA
+	}
+	return ""
+}
+
+func (x *MergeConflict) GetCidB() string {
+	if x != nil {
+		return x.CidB
+	}
+	return ""
+}
+
+func (x *MergeConflict) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type DeleteResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Success     bool   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	RemovedCID  string `protobuf:"bytes,2,opt,name=removedCID,proto3" json:"removedCID,omitempty"`   // the CID of the Record that was removed from the database
+	SnapshotCID string `protobuf:"bytes,3,opt,name=snapshotCID,proto3" json:"snapshotCID,omitempty"` // the CID of the database snapshot after the removal
+}
+
+func (x *DeleteResponse) Reset() {
+	*x = DeleteResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_stark_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeleteResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteResponse) ProtoMessage() {}
+
+func (x *DeleteResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_stark_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteResponse.ProtoReflect.Descriptor instead.
+func (*DeleteResponse) Descriptor() ([]byte, []int) {
+	return file_stark_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *DeleteResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *DeleteResponse) GetRemovedCID() string {
+	if x != nil {
+		return x.RemovedCID
+	}
+	return ""
+}
+
+func (x *DeleteResponse) GetSnapshotCID() string {
+	if x != nil {
+		return x.SnapshotCID
+	}
+	return ""
+}
+
+//
+//Record.
+//
+//This message is used to describe a sequencing metadata.
+//Record's will be able to encode runs, samples and libraries.
+//WIP.
+type Record struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// reserved:
+	Uuid        string            `protobuf:"bytes,1,opt,name=uuid,proto3" json:"uuid,omitempty"`                                                                                                      // universally unique id for the record
+	PreviousCID string            `protobuf:"bytes,2,opt,name=previousCID,proto3" json:"previousCID,omitempty"`                                                                                        // the last known CID this record was pulled from (set during a Get operation)
+	History     []*RecordComment  `protobuf:"bytes,3,rep,name=history,proto3" json:"history,omitempty"`                                                                                                // describes the history of the record - can be used to get timestamps for creation and last updated
+	Encrypted   bool              `protobuf:"varint,4,opt,name=encrypted,proto3" json:"encrypted,omitempty"`                                                                                           // set true to indicate if fields have been encrypted
+	Status      Status            `protobuf:"varint,5,opt,name=status,proto3,enum=stark.Status" json:"status,omitempty"`                                                                               // describes if untagged/tagged/etc.
+	Ciphertext  string            `protobuf:"bytes,13,opt,name=ciphertext,proto3" json:"ciphertext,omitempty"`                                                                                         // the encrypted fields of the record, sealed together (set when encrypted)
+	KdfID       string            `protobuf:"bytes,14,opt,name=kdfID,proto3" json:"kdfID,omitempty"`                                                                                                   // the CID of the key derivation parameters for the cipher key (empty = MD5 derived key, used by earlier versions)
+	Recipients  map[string]string `protobuf:"bytes,15,rep,name=recipients,proto3" json:"recipients,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"` // the recipients who can decrypt the record (map relates recipient public keys to the data key wrapped for that recipient)
+	Author      string            `protobuf:"bytes,16,opt,name=author,proto3" json:"author,omitempty"`                                                                                                 // the peer ID of the node which signed this version of the record (verified when the record is retrieved)
+	AuthorKey   []byte            `protobuf:"bytes,17,opt,name=authorKey,proto3" json:"authorKey,omitempty"`                                                                                           // the public key of the author
+	Signature   []byte            `protobuf:"bytes,18,opt,name=signature,proto3" json:"signature,omitempty"`                                                                                           // the author's signature over this version of the record (set when stored)
+	// user updateable:
+	Alias                   string            `protobuf:"bytes,6,opt,name=alias,proto3" json:"alias,omitempty"`                                                                                                              // the record name / human readable id (used as the default RecordKey ID)
+	Description             string            `protobuf:"bytes,7,opt,name=description,proto3" json:"description,omitempty"`                                                                                                  // a short description of the record
+	LocalSequencerOutputDir string            `protobuf:"bytes,9,opt,name=localSequencerOutputDir,proto3" json:"localSequencerOutputDir,omitempty"`                                                                          // where the sequencer is outputing data for this record
+	LinkedSamples           map[string]string `protobuf:"bytes,10,rep,name=linkedSamples,proto3" json:"linkedSamples,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`     // all samples linked to this record (map relates sample UUIDs to a metadata location (e.g. a CID))
+	LinkedLibraries         map[string]string `protobuf:"bytes,11,rep,name=linkedLibraries,proto3" json:"linkedLibraries,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"` // all libraries linked to this record (map relates library UUIDs to a metadata location (e.g. a CID))
+	Barcodes                map[string]int32  `protobuf:"bytes,12,rep,name=barcodes,proto3" json:"barcodes,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`              // all barcodes used by this record (map links library UUID to barcode for that library)
+}
+
+func (x *Record) Reset() {
+	*x = Record{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_stark_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Record) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Record) ProtoMessage() {}
+
+func (x *Record) ProtoReflect() protoreflect.Message {
+	mi := &file_stark_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Record.ProtoReflect.Descriptor instead.
+func (*Record) Descriptor() ([]byte, []int) {
+	return file_stark_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *Record) GetUuid() string {
+	if x != nil {
+		return x.Uuid
+	}
+	return ""
+}
+
+func (x *Record) GetPreviousCID() string {
+	if x != nil {
+		return x.PreviousCID
+	}
+	return ""
+}
+
+func (x *Record) GetHistory() []*RecordComment {
+	if x != nil {
+		return x.History
+	}
+	return nil
+}
+
+func (x *Record) GetEncrypted() bool {
+	if x != nil {
+		return x.Encrypted
+	}
+	return false
+}
+
+func (x *Record) GetStatus() Status {
+	if x != nil {
+		return x.Status
+	}
+	return Status_UN_INITIALIZED
+}
+
+func (x *Record) GetCiphertext() string {
+	if x != nil {
+		return x.Ciphertext
+	}
+	return ""
+}
+
+func (x *Record) GetKdfID() string {
+	if x != nil {
+		return x.KdfID
+	}
+	return ""
+}
+
+func (x *Record) GetRecipients() map[string]string {
+	if x != nil {
+		return x.Recipients
+	}
+	return nil
+}
+
+func (x *Record) GetAuthor() string {
+	if x != nil {
+		return x.Author
+	}
+	return ""
+}
+
+func (x *Record) GetAuthorKey() []byte {
+	if x != nil {
+		return x.AuthorKey
+	}
+	return nil
+}
+
+func (x *Record) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+func (x *Record) GetAlias() string {
+	if x != nil {
+		return x.Alias
+	}
+	return ""
+}
+
+func (x *Record) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Record) GetLocalSequencerOutputDir() string {
+	if x != nil {
+		return x.LocalSequencerOutputDir
+	}
+	return ""
+}
+
+func (x *Record) GetLinkedSamples() map[string]string {
+	if x != nil {
+		return x.LinkedSamples
+	}
+	return nil
+}
+
+func (x *Record) GetLinkedLibraries() map[string]string {
+	if x != nil {
+		return x.LinkedLibraries
+	}
+	return nil
+}
+
+func (x *Record) GetBarcodes() map[string]int32 {
+	if x != nil {
+		return x.Barcodes
+	}
+	return nil
+}
+
+//
+//DbMeta.
+//
+//This message is used to encode runtime
+//information for a stark database.
+type DbMeta struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Project     string            `protobuf:"bytes,1,opt,name=Project,proto3" json:"Project,omitempty"`                                                                                     // project name for the current database
+	Snapshot    string            `protobuf:"bytes,2,opt,name=Snapshot,proto3" json:"Snapshot,omitempty"`                                                                                   // CID for the current database state
+	NodeAddress string            `protobuf:"bytes,3,opt,name=NodeAddress,proto3" json:"NodeAddress,omitempty"`                                                                             // the IPFS node address for the database
+	Peers       []string          `protobuf:"bytes,4,rep,name=Peers,proto3" json:"Peers,omitempty"`                                                                                         // the IPFS peers in the swarm
+	Pinning     bool              `protobuf:"varint,5,opt,name=Pinning,proto3" json:"Pinning,omitempty"`                                                                                    // if true, database is pinning entries
+	Announcing  bool              `protobuf:"varint,6,opt,name=Announcing,proto3" json:"Announcing,omitempty"`                                                                              // if true, database is announcing entries on PubSub
+	CurrEntries int32             `protobuf:"varint,7,opt,name=CurrEntries,proto3" json:"CurrEntries,omitempty"`                                                                            // current number of entries in the database
+	Pairs       map[string]string `protobuf:"bytes,8,rep,name=Pairs,proto3" json:"Pairs,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"` // pairs of Keys -> Record CIDs held in the database
+	IPNSName    string            `protobuf:"bytes,9,opt,name=IPNSName,proto3" json:"IPNSName,omitempty"`                                                                                   // the IPNS name the database is published under (if publishing)
+}
+
+func (x *DbMeta) Reset() {
+	*x = DbMeta{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_stark_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DbMeta) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DbMeta) ProtoMessage() {}
+
+func (x *DbMeta) ProtoReflect() protoreflect.Message {
+	mi := &file_stark_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DbMeta.ProtoReflect.Descriptor instead.
+func (*DbMeta) Descriptor() ([]byte, []int) {
+	return file_stark_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *DbMeta) GetProject() string {
+	if x != nil {
+		return x.Project
+	}
+	return ""
+}
+
+func (x *DbMeta) GetSnapshot() string {
+	if x != nil {
+		return x.Snapshot
+	}
+	return ""
+}
+
+func (x *DbMeta) GetNodeAddress() string {
+	if x != nil {
+		return x.NodeAddress
+	}
+	return ""
+}
+
+func (x *DbMeta) GetPeers() []string {
+	if x != nil {
+		return x.Peers
+	}
+	return nil
+}
+
+func (x *DbMeta) GetPinning() bool {
+	if x != nil {
+		return x.Pinning
+	}
+	return false
+}
+
+func (x *DbMeta) GetAnnouncing() bool {
+	if x != nil {
+		return x.Announcing
+	}
+	return false
+}
+
+func (x *DbMeta) GetCurrEntries() int32 {
+	if x != nil {
+		return x.CurrEntries
+	}
+	return 0
+}
+
+func (x *DbMeta) GetPairs() map[string]string {
+	if x != nil {
+		return x.Pairs
+	}
+	return nil
+}
+
+func (x *DbMeta) GetIPNSName() string {
+	if x != nil {
+		return x.IPNSName
+	}
+	return ""
+}
+
+//
+//Announcement.
+//This message is used to announce changes to
+//a stark database on the PubSub topic for the
+//database project.
+type Announcement struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type          Announcement_Type    `protobuf:"varint,1,opt,name=type,proto3,enum=stark.Announcement_Type" json:"type,omitempty"` // the type of change being announced
+	Key           string               `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`                                 // the key of the Record (unused for SNAPSHOT and SYNC_REQUEST)
+	Cid           string               `protobuf:"bytes,3,opt,name=cid,proto3" json:"cid,omitempty"`                                 // the CID of the Record (unused for SNAPSHOT and SYNC_REQUEST)
+	SnapshotCID   string               `protobuf:"bytes,4,opt,name=snapshotCID,proto3" json:"snapshotCID,omitempty"`                 // the CID of the database snapshot after the change
+	Sender        string               `protobuf:"bytes,5,opt,name=sender,proto3" json:"sender,omitempty"`                           // the peer ID of the announcing node
+	SchemaVersion int32                `protobuf:"varint,6,opt,name=schemaVersion,proto3" json:"schemaVersion,omitempty"`            // the version of the announcement schema
+	Timestamp     *timestamp.Timestamp `protobuf:"bytes,7,opt,name=timestamp,proto3" json:"timestamp,omitempty"`                     // when the announcement was made
+	Record        *Record              `protobuf:"bytes,8,opt,name=record,proto3" json:"record,omitempty"`                           // the Record as stored (optional, only included if small)
+}
+
+func (x *Announcement) Reset() {
+	*x = Announcement{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_stark_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Announcement) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Announcement) ProtoMessage() {}
+
+func (x *Announcement) ProtoReflect() protoreflect.Message {
+	mi := &file_stark_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Announcement.ProtoReflect.Descriptor instead.
+func (*Announcement) Descriptor() ([]byte, []int) {
+	return file_stark_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *Announcement) GetType() Announcement_Type {
+	if x != nil {
+		return x.Type
+	}
+	return Announcement_UNKNOWN
+}
+
+func (x *Announcement) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *Announcement) GetCid() string {
+	if x != nil {
+		return x.Cid
+	}
+	return ""
+}
+
+func (x *Announcement) GetSnapshotCID() string {
+	if x != nil {
+		return x.SnapshotCID
+	}
+	return ""
+}
+
+func (x *Announcement) GetSender() string {
+	if x != nil {
+		return x.Sender
+	}
+	return ""
+}
+
+func (x *Announcement) GetSchemaVersion() int32 {
+	if x != nil {
+		return x.SchemaVersion
+	}
+	return 0
+}
+
+func (x *Announcement) GetTimestamp() *timestamp.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *Announcement) GetRecord() *Record {
+	if x != nil {
+		return x.Record
+	}
+	return nil
+}
+
+//
+//RecordComment.
+//
+//This message is used to describe message history.
+type RecordComment struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Timestamp   *timestamp.Timestamp `protobuf:"bytes,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`     // timestamp for change
+	Text        string               `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`               // description of the change
+	PreviousCID string               `protobuf:"bytes,3,opt,name=previousCID,proto3" json:"previousCID,omitempty"` // last known CID for a Record (used to rollback the Record and undo the newly commented change)
+}
+
+func (x *RecordComment) Reset() {
+	*x = RecordComment{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_stark_proto_msgTypes[17]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RecordComment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecordComment) ProtoMessage() {}
+
+func (x *RecordComment) ProtoReflect() protoreflect.Message {
+	mi := &file_stark_proto_msgTypes[17]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecordComment.ProtoReflect.Descriptor instead.
+func (*RecordComment) Descriptor() ([]byte, []int) {
+	return file_stark_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *RecordComment) GetTimestamp() *timestamp.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *RecordComment) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *RecordComment) GetPreviousCID() string {
+	if x != nil {
+		return x.PreviousCID
+	}
+	return ""
+}
+
+var File_stark_proto protoreflect.FileDescriptor
+
+var file_stark_proto_rawDesc = []byte{
+	0x0a, 0x0b, 0x73, 0x74, 0x61, 0x72, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x05, 0x73,
 	0x74, 0x61, 0x72, 0x6b, 0x1a, 0x1b, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f,
 	0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x65, 0x6d, 0x70, 0x74, 0x79, 0x2e, 0x70, 0x72, 0x6f, 0x74,
 	0x6f, 0x1a, 0x1f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62,
@@ -571,18 +1547,116 @@ var file_stark_proto_rawDesc = []byte{
 	0x28, 0x08, 0x52, 0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x12, 0x25, 0x0a, 0x06, 0x72,
 	0x65, 0x63, 0x6f, 0x72, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0d, 0x2e, 0x73, 0x74,
 	0x61, 0x72, 0x6b, 0x2e, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x52, 0x06, 0x72, 0x65, 0x63, 0x6f,
-	0x72, 0x64, 0x22, 0xb7, 0x05, 0x0a, 0x06, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x12, 0x12, 0x0a,
-	0x04, 0x75, 0x75, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x75, 0x75, 0x69,
-	0x64, 0x12, 0x20, 0x0a, 0x0b, 0x70, 0x72, 0x65, 0x76, 0x69, 0x6f, 0x75, 0x73, 0x43, 0x49, 0x44,
-	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x70, 0x72, 0x65, 0x76, 0x69, 0x6f, 0x75, 0x73,
-	0x43, 0x49, 0x44, 0x12, 0x2e, 0x0a, 0x07, 0x68, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x18, 0x03,
-	0x20, 0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x73, 0x74, 0x61, 0x72, 0x6b, 0x2e, 0x52, 0x65, 0x63,
-	0x6f, 0x72, 0x64, 0x43, 0x6f, 0x6d, 0x6d, 0x65, 0x6e, 0x74, 0x52, 0x07, 0x68, 0x69, 0x73, 0x74,
-	0x6f, 0x72, 0x79, 0x12, 0x1c, 0x0a, 0x09, 0x65, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64,
-	0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x65, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65,
-	0x64, 0x12, 0x25, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28,
-	0x0e, 0x32, 0x0d, 0x2e, 0x73, 0x74, 0x61, 0x72, 0x6b, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73,
-	0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x61, 0x6c, 0x69, 0x61,
+	0x72, 0x64, 0x22, 0x77, 0x0a, 0x0d, 0x42, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x12, 0x2a, 0x0a,
+	0x05, 0x70, 0x61, 0x69, 0x72, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x73,
+	0x74, 0x61, 0x72, 0x6b, 0x2e, 0x4b, 0x65, 0x79, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x50, 0x61,
+	0x69, 0x72, 0x52, 0x05, 0x70, 0x61, 0x69, 0x72, 0x73, 0x12, 0x20, 0x0a, 0x0b, 0x73, 0x6e, 0x61,
+	0x70, 0x73, 0x68, 0x6f, 0x74, 0x43, 0x49, 0x44, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b,
+	0x73, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x43, 0x49, 0x44, 0x22, 0x57, 0x0a, 0x0f, 0x52,
+	0x6f, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x10,
+	0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79,
+	0x12, 0x1c, 0x0a, 0x09, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x43, 0x49, 0x44, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x09, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74, 0x43, 0x49, 0x44, 0x12, 0x14,
+	0x0a, 0x05, 0x73, 0x74, 0x65, 0x70, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x73,
+	0x74, 0x65, 0x70, 0x73, 0x22, 0xb4, 0x01, 0x0a, 0x0d, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x56,
+	0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x10, 0x0a, 0x03, 0x63, 0x69, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x03, 0x63, 0x69, 0x64, 0x12, 0x34, 0x0a, 0x07, 0x63, 0x72, 0x65, 0x61,
+	0x74, 0x65, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67,
+	0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65,
+	0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x07, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x12, 0x34,
+	0x0a, 0x07, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75,
+	0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x07, 0x75, 0x70, 0x64,
+	0x61, 0x74, 0x65, 0x64, 0x12, 0x25, 0x0a, 0x06, 0x72, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x0d, 0x2e, 0x73, 0x74, 0x61, 0x72, 0x6b, 0x2e, 0x52, 0x65, 0x63,
+	0x6f, 0x72, 0x64, 0x52, 0x06, 0x72, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x22, 0x61, 0x0a, 0x0b, 0x44,
+	0x69, 0x66, 0x66, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x6e,
+	0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x41, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x73,
+	0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x41, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x6e, 0x61, 0x70,
+	0x73, 0x68, 0x6f, 0x74, 0x42, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x73, 0x6e, 0x61,
+	0x70, 0x73, 0x68, 0x6f, 0x74, 0x42, 0x12, 0x16, 0x0a, 0x06, 0x66, 0x69, 0x65, 0x6c, 0x64, 0x73,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x66, 0x69, 0x65, 0x6c, 0x64, 0x73, 0x22, 0xa4,
+	0x01, 0x0a, 0x0c, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x44, 0x69, 0x66, 0x66, 0x12,
+	0x1c, 0x0a, 0x09, 0x73, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x41, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x09, 0x73, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x41, 0x12, 0x1c, 0x0a,
+	0x09, 0x73, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x42, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x09, 0x73, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x42, 0x12, 0x14, 0x0a, 0x05, 0x61,
+	0x64, 0x64, 0x65, 0x64, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x05, 0x61, 0x64, 0x64, 0x65,
+	0x64, 0x12, 0x18, 0x0a, 0x07, 0x72, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x64, 0x18, 0x04, 0x20, 0x03,
+	0x28, 0x09, 0x52, 0x07, 0x72, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x64, 0x12, 0x28, 0x0a, 0x07, 0x63,
+	0x68, 0x61, 0x6e, 0x67, 0x65, 0x64, 0x18, 0x05, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x73,
+	0x74, 0x61, 0x72, 0x6b, 0x2e, 0x4b, 0x65, 0x79, 0x44, 0x69, 0x66, 0x66, 0x52, 0x07, 0x63, 0x68,
+	0x61, 0x6e, 0x67, 0x65, 0x64, 0x22, 0x6d, 0x0a, 0x07, 0x4b, 0x65, 0x79, 0x44, 0x69, 0x66, 0x66,
+	0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b,
+	0x65, 0x79, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x69, 0x64, 0x41, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x04, 0x63, 0x69, 0x64, 0x41, 0x12, 0x12, 0x0a, 0x04, 0x63, 0x69, 0x64, 0x42, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x63, 0x69, 0x64, 0x42, 0x12, 0x28, 0x0a, 0x06, 0x66, 0x69,
+	0x65, 0x6c, 0x64, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x73, 0x74, 0x61,
+	0x72, 0x6b, 0x2e, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x44, 0x69, 0x66, 0x66, 0x52, 0x06, 0x66, 0x69,
+	0x65, 0x6c, 0x64, 0x73, 0x22, 0x51, 0x0a, 0x09, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x44, 0x69, 0x66,
+	0x66, 0x12, 0x14, 0x0a, 0x05, 0x66, 0x69, 0x65, 0x6c, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x05, 0x66, 0x69, 0x65, 0x6c, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x41, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x41, 0x12,
+	0x16, 0x0a, 0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x42, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x06, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x42, 0x22, 0x6e, 0x0a, 0x0c, 0x4d, 0x65, 0x72, 0x67, 0x65,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x61, 0x6e, 0x63, 0x65, 0x73,
+	0x74, 0x6f, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x61, 0x6e, 0x63, 0x65, 0x73,
+	0x74, 0x6f, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x68, 0x65, 0x61, 0x64, 0x41, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x05, 0x68, 0x65, 0x61, 0x64, 0x41, 0x12, 0x14, 0x0a, 0x05, 0x68, 0x65, 0x61,
+	0x64, 0x42, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x68, 0x65, 0x61, 0x64, 0x42, 0x12,
+	0x16, 0x0a, 0x06, 0x64, 0x72, 0x79, 0x52, 0x75, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x06, 0x64, 0x72, 0x79, 0x52, 0x75, 0x6e, 0x22, 0x9b, 0x01, 0x0a, 0x0d, 0x4d, 0x65, 0x72, 0x67,
+	0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x75, 0x63,
+	0x63, 0x65, 0x73, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x73, 0x75, 0x63, 0x63,
+	0x65, 0x73, 0x73, 0x12, 0x20, 0x0a, 0x0b, 0x73, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x43,
+	0x49, 0x44, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x73, 0x6e, 0x61, 0x70, 0x73, 0x68,
+	0x6f, 0x74, 0x43, 0x49, 0x44, 0x12, 0x1a, 0x0a, 0x08, 0x61, 0x6e, 0x63, 0x65, 0x73, 0x74, 0x6f,
+	0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x61, 0x6e, 0x63, 0x65, 0x73, 0x74, 0x6f,
+	0x72, 0x12, 0x32, 0x0a, 0x09, 0x63, 0x6f, 0x6e, 0x66, 0x6c, 0x69, 0x63, 0x74, 0x73, 0x18, 0x04,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x73, 0x74, 0x61, 0x72, 0x6b, 0x2e, 0x4d, 0x65, 0x72,
+	0x67, 0x65, 0x43, 0x6f, 0x6e, 0x66, 0x6c, 0x69, 0x63, 0x74, 0x52, 0x09, 0x63, 0x6f, 0x6e, 0x66,
+	0x6c, 0x69, 0x63, 0x74, 0x73, 0x22, 0x83, 0x01, 0x0a, 0x0d, 0x4d, 0x65, 0x72, 0x67, 0x65, 0x43,
+	0x6f, 0x6e, 0x66, 0x6c, 0x69, 0x63, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x20, 0x0a, 0x0b, 0x63, 0x69, 0x64,
+	0x41, 0x6e, 0x63, 0x65, 0x73, 0x74, 0x6f, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b,
+	0x63, 0x69, 0x64, 0x41, 0x6e, 0x63, 0x65, 0x73, 0x74, 0x6f, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x63,
+	0x69, 0x64, 0x41, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x63, 0x69, 0x64, 0x41, 0x12,
+	0x12, 0x0a, 0x04, 0x63, 0x69, 0x64, 0x42, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x63,
+	0x69, 0x64, 0x42, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18, 0x05, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x06, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x22, 0x6c, 0x0a, 0x0e, 0x44,
+	0x65, 0x6c, 0x65, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x18, 0x0a,
+	0x07, 0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07,
+	0x73, 0x75, 0x63, 0x63, 0x65, 0x73, 0x73, 0x12, 0x1e, 0x0a, 0x0a, 0x72, 0x65, 0x6d, 0x6f, 0x76,
+	0x65, 0x64, 0x43, 0x49, 0x44, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x72, 0x65, 0x6d,
+	0x6f, 0x76, 0x65, 0x64, 0x43, 0x49, 0x44, 0x12, 0x20, 0x0a, 0x0b, 0x73, 0x6e, 0x61, 0x70, 0x73,
+	0x68, 0x6f, 0x74, 0x43, 0x49, 0x44, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x73, 0x6e,
+	0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x43, 0x49, 0x44, 0x22, 0xbf, 0x07, 0x0a, 0x06, 0x52, 0x65,
+	0x63, 0x6f, 0x72, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x75, 0x75, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x04, 0x75, 0x75, 0x69, 0x64, 0x12, 0x20, 0x0a, 0x0b, 0x70, 0x72, 0x65, 0x76,
+	0x69, 0x6f, 0x75, 0x73, 0x43, 0x49, 0x44, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x70,
+	0x72, 0x65, 0x76, 0x69, 0x6f, 0x75, 0x73, 0x43, 0x49, 0x44, 0x12, 0x2e, 0x0a, 0x07, 0x68, 0x69,
+	0x73, 0x74, 0x6f, 0x72, 0x79, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x73, 0x74,
+	0x61, 0x72, 0x6b, 0x2e, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x43, 0x6f, 0x6d, 0x6d, 0x65, 0x6e,
+	0x74, 0x52, 0x07, 0x68, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x12, 0x1c, 0x0a, 0x09, 0x65, 0x6e,
+	0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x65,
+	0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x12, 0x25, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74,
+	0x75, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x0d, 0x2e, 0x73, 0x74, 0x61, 0x72, 0x6b,
+	0x2e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12,
+	0x1e, 0x0a, 0x0a, 0x63, 0x69, 0x70, 0x68, 0x65, 0x72, 0x74, 0x65, 0x78, 0x74, 0x18, 0x0d, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x0a, 0x63, 0x69, 0x70, 0x68, 0x65, 0x72, 0x74, 0x65, 0x78, 0x74, 0x12,
+	0x14, 0x0a, 0x05, 0x6b, 0x64, 0x66, 0x49, 0x44, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05,
+	0x6b, 0x64, 0x66, 0x49, 0x44, 0x12, 0x3d, 0x0a, 0x0a, 0x72, 0x65, 0x63, 0x69, 0x70, 0x69, 0x65,
+	0x6e, 0x74, 0x73, 0x18, 0x0f, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x73, 0x74, 0x61, 0x72,
+	0x6b, 0x2e, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x2e, 0x52, 0x65, 0x63, 0x69, 0x70, 0x69, 0x65,
+	0x6e, 0x74, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x0a, 0x72, 0x65, 0x63, 0x69, 0x70, 0x69,
+	0x65, 0x6e, 0x74, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x18, 0x10,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x12, 0x1c, 0x0a, 0x09,
+	0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x4b, 0x65, 0x79, 0x18, 0x11, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x09, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x4b, 0x65, 0x79, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x69,
+	0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x18, 0x12, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x73,
+	0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x61, 0x6c, 0x69, 0x61,
 	0x73, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x61, 0x6c, 0x69, 0x61, 0x73, 0x12, 0x20,
 	0x0a, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x07, 0x20,
 	0x01, 0x28, 0x09, 0x52, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e,
@@ -602,61 +1676,109 @@ var file_stark_proto_rawDesc = []byte{
 	0x12, 0x37, 0x0a, 0x08, 0x62, 0x61, 0x72, 0x63, 0x6f, 0x64, 0x65, 0x73, 0x18, 0x0c, 0x20, 0x03,
 	0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x73, 0x74, 0x61, 0x72, 0x6b, 0x2e, 0x52, 0x65, 0x63, 0x6f, 0x72,
 	0x64, 0x2e, 0x42, 0x61, 0x72, 0x63, 0x6f, 0x64, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52,
-	0x08, 0x62, 0x61, 0x72, 0x63, 0x6f, 0x64, 0x65, 0x73, 0x1a, 0x40, 0x0a, 0x12, 0x4c, 0x69, 0x6e,
-	0x6b, 0x65, 0x64, 0x53, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12,
-	0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65,
-	0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x1a, 0x42, 0x0a, 0x14, 0x4c,
-	0x69, 0x6e, 0x6b, 0x65, 0x64, 0x4c, 0x69, 0x62, 0x72, 0x61, 0x72, 0x69, 0x65, 0x73, 0x45, 0x6e,
-	0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02,
-	0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x1a,
-	0x3b, 0x0a, 0x0d, 0x42, 0x61, 0x72, 0x63, 0x6f, 0x64, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79,
-	0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b,
-	0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28,
-	0x05, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0xbc, 0x02, 0x0a,
-	0x06, 0x44, 0x62, 0x4d, 0x65, 0x74, 0x61, 0x12, 0x18, 0x0a, 0x07, 0x50, 0x72, 0x6f, 0x6a, 0x65,
-	0x63, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x50, 0x72, 0x6f, 0x6a, 0x65, 0x63,
-	0x74, 0x12, 0x1a, 0x0a, 0x08, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x18, 0x02, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x08, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x12, 0x20, 0x0a,
-	0x0b, 0x4e, 0x6f, 0x64, 0x65, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x03, 0x20, 0x01,
-	0x28, 0x09, 0x52, 0x0b, 0x4e, 0x6f, 0x64, 0x65, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x12,
-	0x14, 0x0a, 0x05, 0x50, 0x65, 0x65, 0x72, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x09, 0x52, 0x05,
-	0x50, 0x65, 0x65, 0x72, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x50, 0x69, 0x6e, 0x6e, 0x69, 0x6e, 0x67,
-	0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x50, 0x69, 0x6e, 0x6e, 0x69, 0x6e, 0x67, 0x12,
-	0x1e, 0x0a, 0x0a, 0x41, 0x6e, 0x6e, 0x6f, 0x75, 0x6e, 0x63, 0x69, 0x6e, 0x67, 0x18, 0x06, 0x20,
-	0x01, 0x28, 0x08, 0x52, 0x0a, 0x41, 0x6e, 0x6e, 0x6f, 0x75, 0x6e, 0x63, 0x69, 0x6e, 0x67, 0x12,
-	0x20, 0x0a, 0x0b, 0x43, 0x75, 0x72, 0x72, 0x45, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x18, 0x07,
-	0x20, 0x01, 0x28, 0x05, 0x52, 0x0b, 0x43, 0x75, 0x72, 0x72, 0x45, 0x6e, 0x74, 0x72, 0x69, 0x65,
-	0x73, 0x12, 0x2e, 0x0a, 0x05, 0x50, 0x61, 0x69, 0x72, 0x73, 0x18, 0x08, 0x20, 0x03, 0x28, 0x0b,
-	0x32, 0x18, 0x2e, 0x73, 0x74, 0x61, 0x72, 0x6b, 0x2e, 0x44, 0x62, 0x4d, 0x65, 0x74, 0x61, 0x2e,
-	0x50, 0x61, 0x69, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x05, 0x50, 0x61, 0x69, 0x72,
-	0x73, 0x1a, 0x38, 0x0a, 0x0a, 0x50, 0x61, 0x69, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12,
+	0x08, 0x62, 0x61, 0x72, 0x63, 0x6f, 0x64, 0x65, 0x73, 0x1a, 0x3d, 0x0a, 0x0f, 0x52, 0x65, 0x63,
+	0x69, 0x70, 0x69, 0x65, 0x6e, 0x74, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03,
+	0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14,
+	0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x1a, 0x40, 0x0a, 0x12, 0x4c, 0x69, 0x6e, 0x6b,
+	0x65, 0x64, 0x53, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10,
+	0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79,
+	0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x1a, 0x42, 0x0a, 0x14, 0x4c, 0x69,
+	0x6e, 0x6b, 0x65, 0x64, 0x4c, 0x69, 0x62, 0x72, 0x61, 0x72, 0x69, 0x65, 0x73, 0x45, 0x6e, 0x74,
+	0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x1a, 0x3b,
+	0x0a, 0x0d, 0x42, 0x61, 0x72, 0x63, 0x6f, 0x64, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12,
 	0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65,
-	0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x7f, 0x0a, 0x0d, 0x52,
-	0x65, 0x63, 0x6f, 0x72, 0x64, 0x43, 0x6f, 0x6d, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x38, 0x0a, 0x09,
-	0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32,
-	0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75,
-	0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x09, 0x74, 0x69, 0x6d,
-	0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x65, 0x78, 0x74, 0x18, 0x02,
-	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x65, 0x78, 0x74, 0x12, 0x20, 0x0a, 0x0b, 0x70, 0x72,
-	0x65, 0x76, 0x69, 0x6f, 0x75, 0x73, 0x43, 0x49, 0x44, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52,
-	0x0b, 0x70, 0x72, 0x65, 0x76, 0x69, 0x6f, 0x75, 0x73, 0x43, 0x49, 0x44, 0x2a, 0x36, 0x0a, 0x06,
-	0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x12, 0x0a, 0x0e, 0x55, 0x4e, 0x5f, 0x49, 0x4e, 0x49,
-	0x54, 0x49, 0x41, 0x4c, 0x49, 0x5a, 0x45, 0x44, 0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08, 0x75, 0x6e,
-	0x74, 0x61, 0x67, 0x67, 0x65, 0x64, 0x10, 0x01, 0x12, 0x0a, 0x0a, 0x06, 0x74, 0x61, 0x67, 0x67,
-	0x65, 0x64, 0x10, 0x02, 0x32, 0x90, 0x01, 0x0a, 0x07, 0x53, 0x74, 0x61, 0x72, 0x6b, 0x44, 0x62,
-	0x12, 0x2e, 0x0a, 0x03, 0x53, 0x65, 0x74, 0x12, 0x14, 0x2e, 0x73, 0x74, 0x61, 0x72, 0x6b, 0x2e,
-	0x4b, 0x65, 0x79, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x50, 0x61, 0x69, 0x72, 0x1a, 0x0f, 0x2e,
-	0x73, 0x74, 0x61, 0x72, 0x6b, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00,
-	0x12, 0x24, 0x0a, 0x03, 0x47, 0x65, 0x74, 0x12, 0x0a, 0x2e, 0x73, 0x74, 0x61, 0x72, 0x6b, 0x2e,
-	0x4b, 0x65, 0x79, 0x1a, 0x0f, 0x2e, 0x73, 0x74, 0x61, 0x72, 0x6b, 0x2e, 0x52, 0x65, 0x73, 0x70,
-	0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x2f, 0x0a, 0x04, 0x44, 0x75, 0x6d, 0x70, 0x12, 0x16,
-	0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
-	0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x1a, 0x0d, 0x2e, 0x73, 0x74, 0x61, 0x72, 0x6b, 0x2e, 0x44,
-	0x62, 0x4d, 0x65, 0x74, 0x61, 0x22, 0x00, 0x42, 0x09, 0x5a, 0x07, 0x2e, 0x3b, 0x73, 0x74, 0x61,
-	0x72, 0x6b, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05,
+	0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0xd8, 0x02, 0x0a, 0x06,
+	0x44, 0x62, 0x4d, 0x65, 0x74, 0x61, 0x12, 0x18, 0x0a, 0x07, 0x50, 0x72, 0x6f, 0x6a, 0x65, 0x63,
+	0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x50, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74,
+	0x12, 0x1a, 0x0a, 0x08, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x08, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x12, 0x20, 0x0a, 0x0b,
+	0x4e, 0x6f, 0x64, 0x65, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0b, 0x4e, 0x6f, 0x64, 0x65, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x12, 0x14,
+	0x0a, 0x05, 0x50, 0x65, 0x65, 0x72, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x09, 0x52, 0x05, 0x50,
+	0x65, 0x65, 0x72, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x50, 0x69, 0x6e, 0x6e, 0x69, 0x6e, 0x67, 0x18,
+	0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x50, 0x69, 0x6e, 0x6e, 0x69, 0x6e, 0x67, 0x12, 0x1e,
+	0x0a, 0x0a, 0x41, 0x6e, 0x6e, 0x6f, 0x75, 0x6e, 0x63, 0x69, 0x6e, 0x67, 0x18, 0x06, 0x20, 0x01,
+	0x28, 0x08, 0x52, 0x0a, 0x41, 0x6e, 0x6e, 0x6f, 0x75, 0x6e, 0x63, 0x69, 0x6e, 0x67, 0x12, 0x20,
+	0x0a, 0x0b, 0x43, 0x75, 0x72, 0x72, 0x45, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x18, 0x07, 0x20,
+	0x01, 0x28, 0x05, 0x52, 0x0b, 0x43, 0x75, 0x72, 0x72, 0x45, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73,
+	0x12, 0x2e, 0x0a, 0x05, 0x50, 0x61, 0x69, 0x72, 0x73, 0x18, 0x08, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x18, 0x2e, 0x73, 0x74, 0x61, 0x72, 0x6b, 0x2e, 0x44, 0x62, 0x4d, 0x65, 0x74, 0x61, 0x2e, 0x50,
+	0x61, 0x69, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x05, 0x50, 0x61, 0x69, 0x72, 0x73,
+	0x12, 0x1a, 0x0a, 0x08, 0x49, 0x50, 0x4e, 0x53, 0x4e, 0x61, 0x6d, 0x65, 0x18, 0x09, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x08, 0x49, 0x50, 0x4e, 0x53, 0x4e, 0x61, 0x6d, 0x65, 0x1a, 0x38, 0x0a, 0x0a,
+	0x50, 0x61, 0x69, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65,
+	0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05,
+	0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c,
+	0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0xeb, 0x02, 0x0a, 0x0c, 0x41, 0x6e, 0x6e, 0x6f, 0x75,
+	0x6e, 0x63, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x2c, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x18, 0x2e, 0x73, 0x74, 0x61, 0x72, 0x6b, 0x2e, 0x41, 0x6e,
+	0x6e, 0x6f, 0x75, 0x6e, 0x63, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x54, 0x79, 0x70, 0x65, 0x52,
+	0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x63, 0x69, 0x64, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x63, 0x69, 0x64, 0x12, 0x20, 0x0a, 0x0b, 0x73, 0x6e, 0x61,
+	0x70, 0x73, 0x68, 0x6f, 0x74, 0x43, 0x49, 0x44, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b,
+	0x73, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74, 0x43, 0x49, 0x44, 0x12, 0x16, 0x0a, 0x06, 0x73,
+	0x65, 0x6e, 0x64, 0x65, 0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x65, 0x6e,
+	0x64, 0x65, 0x72, 0x12, 0x24, 0x0a, 0x0d, 0x73, 0x63, 0x68, 0x65, 0x6d, 0x61, 0x56, 0x65, 0x72,
+	0x73, 0x69, 0x6f, 0x6e, 0x18, 0x06, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0d, 0x73, 0x63, 0x68, 0x65,
+	0x6d, 0x61, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x38, 0x0a, 0x09, 0x74, 0x69, 0x6d,
+	0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67,
+	0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54,
+	0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74,
+	0x61, 0x6d, 0x70, 0x12, 0x25, 0x0a, 0x06, 0x72, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x18, 0x08, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x0d, 0x2e, 0x73, 0x74, 0x61, 0x72, 0x6b, 0x2e, 0x52, 0x65, 0x63, 0x6f,
+	0x72, 0x64, 0x52, 0x06, 0x72, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x22, 0x48, 0x0a, 0x04, 0x54, 0x79,
+	0x70, 0x65, 0x12, 0x0b, 0x0a, 0x07, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x10, 0x00, 0x12,
+	0x07, 0x0a, 0x03, 0x53, 0x45, 0x54, 0x10, 0x01, 0x12, 0x0a, 0x0a, 0x06, 0x44, 0x45, 0x4c, 0x45,
+	0x54, 0x45, 0x10, 0x02, 0x12, 0x0c, 0x0a, 0x08, 0x53, 0x4e, 0x41, 0x50, 0x53, 0x48, 0x4f, 0x54,
+	0x10, 0x03, 0x12, 0x10, 0x0a, 0x0c, 0x53, 0x59, 0x4e, 0x43, 0x5f, 0x52, 0x45, 0x51, 0x55, 0x45,
+	0x53, 0x54, 0x10, 0x04, 0x22, 0x7f, 0x0a, 0x0d, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x43, 0x6f,
+	0x6d, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x38, 0x0a, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61,
+	0x6d, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c,
+	0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73,
+	0x74, 0x61, 0x6d, 0x70, 0x52, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x12,
+	0x12, 0x0a, 0x04, 0x74, 0x65, 0x78, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74,
+	0x65, 0x78, 0x74, 0x12, 0x20, 0x0a, 0x0b, 0x70, 0x72, 0x65, 0x76, 0x69, 0x6f, 0x75, 0x73, 0x43,
+	0x49, 0x44, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x70, 0x72, 0x65, 0x76, 0x69, 0x6f,
+	0x75, 0x73, 0x43, 0x49, 0x44, 0x2a, 0x36, 0x0a, 0x06, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12,
+	0x12, 0x0a, 0x0e, 0x55, 0x4e, 0x5f, 0x49, 0x4e, 0x49, 0x54, 0x49, 0x41, 0x4c, 0x49, 0x5a, 0x45,
+	0x44, 0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08, 0x75, 0x6e, 0x74, 0x61, 0x67, 0x67, 0x65, 0x64, 0x10,
+	0x01, 0x12, 0x0a, 0x0a, 0x06, 0x74, 0x61, 0x67, 0x67, 0x65, 0x64, 0x10, 0x02, 0x32, 0xcc, 0x03,
+	0x0a, 0x07, 0x53, 0x74, 0x61, 0x72, 0x6b, 0x44, 0x62, 0x12, 0x2e, 0x0a, 0x03, 0x53, 0x65, 0x74,
+	0x12, 0x14, 0x2e, 0x73, 0x74, 0x61, 0x72, 0x6b, 0x2e, 0x4b, 0x65, 0x79, 0x52, 0x65, 0x63, 0x6f,
+	0x72, 0x64, 0x50, 0x61, 0x69, 0x72, 0x1a, 0x0f, 0x2e, 0x73, 0x74, 0x61, 0x72, 0x6b, 0x2e, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x3a, 0x0a, 0x08, 0x42, 0x61, 0x74,
+	0x63, 0x68, 0x53, 0x65, 0x74, 0x12, 0x14, 0x2e, 0x73, 0x74, 0x61, 0x72, 0x6b, 0x2e, 0x4b, 0x65,
+	0x79, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x50, 0x61, 0x69, 0x72, 0x1a, 0x14, 0x2e, 0x73, 0x74,
+	0x61, 0x72, 0x6b, 0x2e, 0x42, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x22, 0x00, 0x28, 0x01, 0x12, 0x24, 0x0a, 0x03, 0x47, 0x65, 0x74, 0x12, 0x0a, 0x2e, 0x73,
+	0x74, 0x61, 0x72, 0x6b, 0x2e, 0x4b, 0x65, 0x79, 0x1a, 0x0f, 0x2e, 0x73, 0x74, 0x61, 0x72, 0x6b,
+	0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x2f, 0x0a, 0x04, 0x44,
+	0x75, 0x6d, 0x70, 0x12, 0x16, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x1a, 0x0d, 0x2e, 0x73, 0x74,
+	0x61, 0x72, 0x6b, 0x2e, 0x44, 0x62, 0x4d, 0x65, 0x74, 0x61, 0x22, 0x00, 0x12, 0x2d, 0x0a, 0x06,
+	0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x12, 0x0a, 0x2e, 0x73, 0x74, 0x61, 0x72, 0x6b, 0x2e, 0x4b,
+	0x65, 0x79, 0x1a, 0x15, 0x2e, 0x73, 0x74, 0x61, 0x72, 0x6b, 0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74,
+	0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x35, 0x0a, 0x08, 0x52,
+	0x6f, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x12, 0x16, 0x2e, 0x73, 0x74, 0x61, 0x72, 0x6b, 0x2e,
+	0x52, 0x6f, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x0f, 0x2e, 0x73, 0x74, 0x61, 0x72, 0x6b, 0x2e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x22, 0x00, 0x12, 0x2f, 0x0a, 0x07, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x12, 0x0a, 0x2e,
+	0x73, 0x74, 0x61, 0x72, 0x6b, 0x2e, 0x4b, 0x65, 0x79, 0x1a, 0x14, 0x2e, 0x73, 0x74, 0x61, 0x72,
+	0x6b, 0x2e, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22,
+	0x00, 0x30, 0x01, 0x12, 0x31, 0x0a, 0x04, 0x44, 0x69, 0x66, 0x66, 0x12, 0x12, 0x2e, 0x73, 0x74,
+	0x61, 0x72, 0x6b, 0x2e, 0x44, 0x69, 0x66, 0x66, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x13, 0x2e, 0x73, 0x74, 0x61, 0x72, 0x6b, 0x2e, 0x53, 0x6e, 0x61, 0x70, 0x73, 0x68, 0x6f, 0x74,
+	0x44, 0x69, 0x66, 0x66, 0x22, 0x00, 0x12, 0x34, 0x0a, 0x05, 0x4d, 0x65, 0x72, 0x67, 0x65, 0x12,
+	0x13, 0x2e, 0x73, 0x74, 0x61, 0x72, 0x6b, 0x2e, 0x4d, 0x65, 0x72, 0x67, 0x65, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x14, 0x2e, 0x73, 0x74, 0x61, 0x72, 0x6b, 0x2e, 0x4d, 0x65, 0x72,
+	0x67, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x42, 0x09, 0x5a, 0x07,
+	0x2e, 0x3b, 0x73, 0x74, 0x61, 0x72, 0x6b, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -671,44 +1793,81 @@ func file_stark_proto_rawDescGZIP() []byte {
 	return file_stark_proto_rawDescData
 }
 
-var file_stark_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_stark_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_stark_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_stark_proto_msgTypes = make([]protoimpl.MessageInfo, 23)
 var file_stark_proto_goTypes = []interface{}{
 	(Status)(0),                 // 0: stark.Status
-	(*KeyRecordPair)(nil),       // 1: stark.KeyRecordPair
-	(*Key)(nil),                 // 2: stark.Key
-	(*Response)(nil),            // 3: stark.Response
-	(*Record)(nil),              // 4: stark.Record
-	(*DbMeta)(nil),              // 5: stark.DbMeta
-	(*RecordComment)(nil),       // 6: stark.RecordComment
-	nil,                         // 7: stark.Record.LinkedSamplesEntry
-	nil,                         // 8: stark.Record.LinkedLibrariesEntry
-	nil,                         // 9: stark.Record.BarcodesEntry
-	nil,                         // 10: stark.DbMeta.PairsEntry
-	(*timestamp.Timestamp)(nil), // 11: google.protobuf.Timestamp
-	(*empty.Empty)(nil),         // 12: google.protobuf.Empty
+	(Announcement_Type)(0),      // 1: stark.Announcement.Type
+	(*KeyRecordPair)(nil),       // 2: stark.KeyRecordPair
+	(*Key)(nil),                 // 3: stark.Key
+	(*Response)(nil),            // 4: stark.Response
+	(*BatchResponse)(nil),       // 5: stark.BatchResponse
+	(*RollbackRequest)(nil),     // 6: stark.RollbackRequest
+	(*RecordVersion)(nil),       // 7: stark.RecordVersion
+	(*DiffRequest)(nil),         // 8: stark.DiffRequest
+	(*SnapshotDiff)(nil),        // 9: stark.SnapshotDiff
+	(*KeyDiff)(nil),             // 10: stark.KeyDiff
+	(*FieldDiff)(nil),           // 11: stark.FieldDiff
+	(*MergeRequest)(nil),        // 12: stark.MergeRequest
+	(*MergeResponse)(nil),       // 13: stark.MergeResponse
+	(*MergeConflict)(nil),       // 14: stark.MergeConflict
+	(*DeleteResponse)(nil),      // 15: stark.DeleteResponse
+	(*Record)(nil),              // 16: stark.Record
+	(*DbMeta)(nil),              // 17: stark.DbMeta
+	(*Announcement)(nil),        // 18: stark.Announcement
+	(*RecordComment)(nil),       // 19: stark.RecordComment
+	nil,                         // 20: stark.Record.RecipientsEntry
+	nil,                         // 21: stark.Record.LinkedSamplesEntry
+	nil,                         // 22: stark.Record.LinkedLibrariesEntry
+	nil,                         // 23: stark.Record.BarcodesEntry
+	nil,                         // 24: stark.DbMeta.PairsEntry
+	(*timestamp.Timestamp)(nil), // 25: google.protobuf.Timestamp
+	(*empty.Empty)(nil),         // 26: google.protobuf.Empty
 }
 var file_stark_proto_depIdxs = []int32{
-	4,  // 0: stark.KeyRecordPair.record:type_name -> stark.Record
-	4,  // 1: stark.Response.record:type_name -> stark.Record
-	6,  // 2: stark.Record.history:type_name -> stark.RecordComment
-	0,  // 3: stark.Record.status:type_name -> stark.Status
-	7,  // 4: stark.Record.linkedSamples:type_name -> stark.Record.LinkedSamplesEntry
-	8,  // 5: stark.Record.linkedLibraries:type_name -> stark.Record.LinkedLibrariesEntry
-	9,  // 6: stark.Record.barcodes:type_name -> stark.Record.BarcodesEntry
-	10, // 7: stark.DbMeta.Pairs:type_name -> stark.DbMeta.PairsEntry
-	11, // 8: stark.RecordComment.timestamp:type_name -> google.protobuf.Timestamp
-	1,  // 9: stark.StarkDb.Set:input_type -> stark.KeyRecordPair
-	2,  // 10: stark.StarkDb.Get:input_type -> stark.Key
-	12, // 11: stark.StarkDb.Dump:input_type -> google.protobuf.Empty
-	3,  // 12: stark.StarkDb.Set:output_type -> stark.Response
-	3,  // 13: stark.StarkDb.Get:output_type -> stark.Response
-	5,  // 14: stark.StarkDb.Dump:output_type -> stark.DbMeta
-	12, // [12:15] is the sub-list for method output_type
-	9,  // [9:12] is the sub-list for method input_type
-	9,  // [9:9] is the sub-list for extension type_name
-	9,  // [9:9] is the sub-list for extension extendee
-	0,  // [0:9] is the sub-list for field type_name
+	16, // 0: stark.KeyRecordPair.record:type_name -> stark.Record
+	16, // 1: stark.Response.record:type_name -> stark.Record
+	2,  // 2: stark.BatchResponse.pairs:type_name -> stark.KeyRecordPair
+	25, // 3: stark.RecordVersion.created:type_name -> google.protobuf.Timestamp
+	25, // 4: stark.RecordVersion.updated:type_name -> google.protobuf.Timestamp
+	16, // 5: stark.RecordVersion.record:type_name -> stark.Record
+	10, // 6: stark.SnapshotDiff.changed:type_name -> stark.KeyDiff
+	11, // 7: stark.KeyDiff.fields:type_name -> stark.FieldDiff
+	14, // 8: stark.MergeResponse.conflicts:type_name -> stark.MergeConflict
+	19, // 9: stark.Record.history:type_name -> stark.RecordComment
+	0,  // 10: stark.Record.status:type_name -> stark.Status
+	20, // 11: stark.Record.recipients:type_name -> stark.Record.RecipientsEntry
+	21, // 12: stark.Record.linkedSamples:type_name -> stark.Record.LinkedSamplesEntry
+	22, // 13: stark.Record.linkedLibraries:type_name -> stark.Record.LinkedLibrariesEntry
+	23, // 14: stark.Record.barcodes:type_name -> stark.Record.BarcodesEntry
+	24, // 15: stark.DbMeta.Pairs:type_name -> stark.DbMeta.PairsEntry
+	1,  // 16: stark.Announcement.type:type_name -> stark.Announcement.Type
+	25, // 17: stark.Announcement.timestamp:type_name -> google.protobuf.Timestamp
+	16, // 18: stark.Announcement.record:type_name -> stark.Record
+	25, // 19: stark.RecordComment.timestamp:type_name -> google.protobuf.Timestamp
+	2,  // 20: stark.StarkDb.Set:input_type -> stark.KeyRecordPair
+	2,  // 21: stark.StarkDb.BatchSet:input_type -> stark.KeyRecordPair
+	3,  // 22: stark.StarkDb.Get:input_type -> stark.Key
+	26, // 23: stark.StarkDb.Dump:input_type -> google.protobuf.Empty
+	3,  // 24: stark.StarkDb.Delete:input_type -> stark.Key
+	6,  // 25: stark.StarkDb.Rollback:input_type -> stark.RollbackRequest
+	3,  // 26: stark.StarkDb.History:input_type -> stark.Key
+	8,  // 27: stark.StarkDb.Diff:input_type -> stark.DiffRequest
+	12, // 28: stark.StarkDb.Merge:input_type -> stark.MergeRequest
+	4,  // 29: stark.StarkDb.Set:output_type -> stark.Response
+	5,  // 30: stark.StarkDb.BatchSet:output_type -> stark.BatchResponse
+	4,  // 31: stark.StarkDb.Get:output_type -> stark.Response
+	17, // 32: stark.StarkDb.Dump:output_type -> stark.DbMeta
+	15, // 33: stark.StarkDb.Delete:output_type -> stark.DeleteResponse
+	4,  // 34: stark.StarkDb.Rollback:output_type -> stark.Response
+	7,  // 35: stark.StarkDb.History:output_type -> stark.RecordVersion
+	9,  // 36: stark.StarkDb.Diff:output_type -> stark.SnapshotDiff
+	13, // 37: stark.StarkDb.Merge:output_type -> stark.MergeResponse
+	29, // [29:38] is the sub-list for method output_type
+	20, // [20:29] is the sub-list for method input_type
+	20, // [20:20] is the sub-list for extension type_name
+	20, // [20:20] is the sub-list for extension extendee
+	0,  // [0:20] is the sub-list for field type_name
 }
 
 func init() { file_stark_proto_init() }
@@ -729,8 +1888,44 @@ func file_stark_proto_init() {
 				return nil
 			}
 		}
-		file_stark_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Key); i {
+		file_stark_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Key); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_stark_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Response); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_stark_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BatchResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_stark_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RollbackRequest); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -741,8 +1936,8 @@ func file_stark_proto_init() {
 				return nil
 			}
 		}
-		file_stark_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Response); i {
+		file_stark_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RecordVersion); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -753,7 +1948,103 @@ func file_stark_proto_init() {
 				return nil
 			}
 		}
-		file_stark_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+		file_stark_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DiffRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_stark_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SnapshotDiff); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_stark_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*KeyDiff); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_stark_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*FieldDiff); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_stark_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MergeRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_stark_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MergeResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_stark_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MergeConflict); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_stark_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeleteResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_stark_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*Record); i {
 			case 0:
 				return &v.state
@@ -765,7 +2056,7 @@ func file_stark_proto_init() {
 				return nil
 			}
 		}
-		file_stark_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+		file_stark_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*DbMeta); i {
 			case 0:
 				return &v.state
@@ -777,7 +2068,19 @@ func file_stark_proto_init() {
 				return nil
 			}
 		}
-		file_stark_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+		file_stark_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Announcement); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_stark_proto_msgTypes[17].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*RecordComment); i {
 			case 0:
 				return &v.state
@@ -795,8 +2098,8 @@ func file_stark_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_stark_proto_rawDesc,
-			NumEnums:      1,
-			NumMessages:   10,
+			NumEnums:      2,
+			NumMessages:   23,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -824,8 +2127,14 @@ const _ = grpc.SupportPackageIsVersion6
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
 type StarkDbClient interface {
 	Set(ctx context.Context, in *KeyRecordPair, opts ...grpc.CallOption) (*Response, error)
+	BatchSet(ctx context.Context, opts ...grpc.CallOption) (StarkDb_BatchSetClient, error)
 	Get(ctx context.Context, in *Key, opts ...grpc.CallOption) (*Response, error)
 	Dump(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*DbMeta, error)
+	Delete(ctx context.Context, in *Key, opts ...grpc.CallOption) (*DeleteResponse, error)
+	Rollback(ctx context.Context, in *RollbackRequest, opts ...grpc.CallOption) (*Response, error)
+	History(ctx context.Context, in *Key, opts ...grpc.CallOption) (StarkDb_HistoryClient, error)
+	Diff(ctx context.Context, in *DiffRequest, opts ...grpc.CallOption) (*SnapshotDiff, error)
+	Merge(ctx context.Context, in *MergeRequest, opts ...grpc.CallOption) (*MergeResponse, error)
 }
 
 type starkDbClient struct {
@@ -845,6 +2154,40 @@ func (c *starkDbClient) Set(ctx context.Context, in *KeyRecordPair, opts ...grpc
 	return out, nil
 }
 
+func (c *starkDbClient) BatchSet(ctx context.Context, opts ...grpc.CallOption) (StarkDb_BatchSetClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_StarkDb_serviceDesc.Streams[0], "/stark.StarkDb/BatchSet", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &starkDbBatchSetClient{stream}
+	return x, nil
+}
+
+type StarkDb_BatchSetClient interface {
+	Send(*KeyRecordPair) error
+	CloseAndRecv() (*BatchResponse, error)
+	grpc.ClientStream
+}
+
+type starkDbBatchSetClient struct {
+	grpc.ClientStream
+}
+
+func (x *starkDbBatchSetClient) Send(m *KeyRecordPair) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *starkDbBatchSetClient) CloseAndRecv() (*BatchResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(BatchResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *starkDbClient) Get(ctx context.Context, in *Key, opts ...grpc.CallOption) (*Response, error) {
 	out := new(Response)
 	err := c.cc.Invoke(ctx, "/stark.StarkDb/Get", in, out, opts...)
@@ -863,11 +2206,85 @@ func (c *starkDbClient) Dump(ctx context.Context, in *empty.Empty, opts ...grpc.
 	return out, nil
 }
 
+func (c *starkDbClient) Delete(ctx context.Context, in *Key, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, "/stark.StarkDb/Delete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *starkDbClient) Rollback(ctx context.Context, in *RollbackRequest, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := c.cc.Invoke(ctx, "/stark.StarkDb/Rollback", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *starkDbClient) History(ctx context.Context, in *Key, opts ...grpc.CallOption) (StarkDb_HistoryClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_StarkDb_serviceDesc.Streams[1], "/stark.StarkDb/History", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &starkDbHistoryClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type StarkDb_HistoryClient interface {
+	Recv() (*RecordVersion, error)
+	grpc.ClientStream
+}
+
+type starkDbHistoryClient struct {
+	grpc.ClientStream
+}
+
+func (x *starkDbHistoryClient) Recv() (*RecordVersion, error) {
+	m := new(RecordVersion)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *starkDbClient) Diff(ctx context.Context, in *DiffRequest, opts ...grpc.CallOption) (*SnapshotDiff, error) {
+	out := new(SnapshotDiff)
+	err := c.cc.Invoke(ctx, "/stark.StarkDb/Diff", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *starkDbClient) Merge(ctx context.Context, in *MergeRequest, opts ...grpc.CallOption) (*MergeResponse, error) {
+	out := new(MergeResponse)
+	err := c.cc.Invoke(ctx, "/stark.StarkDb/Merge", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // StarkDbServer is the server API for StarkDb service.
 type StarkDbServer interface {
 	Set(context.Context, *KeyRecordPair) (*Response, error)
+	BatchSet(StarkDb_BatchSetServer) error
 	Get(context.Context, *Key) (*Response, error)
 	Dump(context.Context, *empty.Empty) (*DbMeta, error)
+	Delete(context.Context, *Key) (*DeleteResponse, error)
+	Rollback(context.Context, *RollbackRequest) (*Response, error)
+	History(*Key, StarkDb_HistoryServer) error
+	Diff(context.Context, *DiffRequest) (*SnapshotDiff, error)
+	Merge(context.Context, *MergeRequest) (*MergeResponse, error)
 }
 
 // UnimplementedStarkDbServer can be embedded to have forward compatible implementations.
@@ -877,12 +2294,30 @@ type UnimplementedStarkDbServer struct {
 func (*UnimplementedStarkDbServer) Set(context.Context, *KeyRecordPair) (*Response, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Set not implemented")
 }
+func (*UnimplementedStarkDbServer) BatchSet(StarkDb_BatchSetServer) error {
+	return status.Errorf(codes.Unimplemented, "method BatchSet not implemented")
+}
 func (*UnimplementedStarkDbServer) Get(context.Context, *Key) (*Response, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
 }
 func (*UnimplementedStarkDbServer) Dump(context.Context, *empty.Empty) (*DbMeta, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Dump not implemented")
 }
+func (*UnimplementedStarkDbServer) Delete(context.Context, *Key) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (*UnimplementedStarkDbServer) Rollback(context.Context, *RollbackRequest) (*Response, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Rollback not implemented")
+}
+func (*UnimplementedStarkDbServer) History(*Key, StarkDb_HistoryServer) error {
+	return status.Errorf(codes.Unimplemented, "method History not implemented")
+}
+func (*UnimplementedStarkDbServer) Diff(context.Context, *DiffRequest) (*SnapshotDiff, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Diff not implemented")
+}
+func (*UnimplementedStarkDbServer) Merge(context.Context, *MergeRequest) (*MergeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Merge not implemented")
+}
 
 func RegisterStarkDbServer(s *grpc.Server, srv StarkDbServer) {
 	s.RegisterService(&_StarkDb_serviceDesc, srv)
@@ -906,6 +2341,32 @@ func _StarkDb_Set_Handler(srv interface{}, ctx context.Context, dec func(interfa
 	return interceptor(ctx, in, info, handler)
 }
 
+func _StarkDb_BatchSet_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(StarkDbServer).BatchSet(&starkDbBatchSetServer{stream})
+}
+
+type StarkDb_BatchSetServer interface {
+	SendAndClose(*BatchResponse) error
+	Recv() (*KeyRecordPair, error)
+	grpc.ServerStream
+}
+
+type starkDbBatchSetServer struct {
+	grpc.ServerStream
+}
+
+func (x *starkDbBatchSetServer) SendAndClose(m *BatchResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *starkDbBatchSetServer) Recv() (*KeyRecordPair, error) {
+	m := new(KeyRecordPair)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func _StarkDb_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(Key)
 	if err := dec(in); err != nil {
@@ -942,6 +2403,99 @@ func _StarkDb_Dump_Handler(srv interface{}, ctx context.Context, dec func(interf
 	return interceptor(ctx, in, info, handler)
 }
 
+func _StarkDb_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Key)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StarkDbServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/stark.StarkDb/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StarkDbServer).Delete(ctx, req.(*Key))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StarkDb_Rollback_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RollbackRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StarkDbServer).Rollback(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/stark.StarkDb/Rollback",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StarkDbServer).Rollback(ctx, req.(*RollbackRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StarkDb_History_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Key)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StarkDbServer).History(m, &starkDbHistoryServer{stream})
+}
+
+type StarkDb_HistoryServer interface {
+	Send(*RecordVersion) error
+	grpc.ServerStream
+}
+
+type starkDbHistoryServer struct {
+	grpc.ServerStream
+}
+
+func (x *starkDbHistoryServer) Send(m *RecordVersion) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _StarkDb_Diff_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DiffRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StarkDbServer).Diff(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/stark.StarkDb/Diff",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StarkDbServer).Diff(ctx, req.(*DiffRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StarkDb_Merge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MergeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StarkDbServer).Merge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/stark.StarkDb/Merge",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StarkDbServer).Merge(ctx, req.(*MergeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _StarkDb_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "stark.StarkDb",
 	HandlerType: (*StarkDbServer)(nil),
@@ -958,7 +2512,34 @@ var _StarkDb_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Dump",
 			Handler:    _StarkDb_Dump_Handler,
 		},
+		{
+			MethodName: "Delete",
+			Handler:    _StarkDb_Delete_Handler,
+		},
+		{
+			MethodName: "Rollback",
+			Handler:    _StarkDb_Rollback_Handler,
+		},
+		{
+			MethodName: "Diff",
+			Handler:    _StarkDb_Diff_Handler,
+		},
+		{
+			MethodName: "Merge",
+			Handler:    _StarkDb_Merge_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "BatchSet",
+			Handler:       _StarkDb_BatchSet_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "History",
+			Handler:       _StarkDb_History_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "stark.proto",
 }
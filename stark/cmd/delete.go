@@ -0,0 +1,101 @@
+/*
+Copyright © 2020 Will Rowe <w.p.m.rowe@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/will-rowe/stark"
+	"github.com/will-rowe/stark/stark/config"
+	"google.golang.org/grpc"
+)
+
+var (
+	skipConfirm *bool
+)
+
+// deleteCmd represents the delete command
+var deleteCmd = &cobra.Command{
+	Use:   "delete <key>",
+	Short: "Delete a record from an open database",
+	Long: `Delete a record from an open database.
+	
+	This command will remove the key from the
+	database, unpin the record from the IPFS and
+	report the new database snapshot. You will be
+	asked to confirm the delete unless the --yes
+	flag is used.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runDelete(args[0])
+	},
+}
+
+func init() {
+	skipConfirm = deleteCmd.Flags().BoolP("yes", "y", false, "Skip the confirmation prompt")
+	rootCmd.AddCommand(deleteCmd)
+}
+
+func runDelete(key string) {
+
+	// confirm the delete
+	if !*skipConfirm {
+		log.Infof("delete Record for key %v? [y/N]:", key)
+		answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			log.Fatal(err)
+		}
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer != "y" && answer != "yes" {
+			log.Info("delete cancelled")
+			return
+		}
+	}
+
+	// get context
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// connect to the server
+	conn, err := grpc.DialContext(ctx, viper.GetString("Address"), grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		log.Fatalf("could not connect to a database: %v", err)
+	}
+	defer conn.Close()
+	c := stark.NewStarkDbClient(conn)
+
+	// make a Delete request
+	response, err := c.Delete(ctx, &stark.Key{Key: key})
+	config.CheckResponseErr(err)
+
+	// print the removed cid and the new snapshot
+	log.Info("deleted Record from database:")
+	log.Infof("\t%v -> %v", key, response.GetRemovedCID())
+	log.Infof("\tsnapshot: %v", response.GetSnapshotCID())
+}
@@ -0,0 +1,105 @@
+/*
+Copyright © 2020 Will Rowe <w.p.m.rowe@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/will-rowe/stark"
+	"github.com/will-rowe/stark/stark/config"
+	"google.golang.org/grpc"
+)
+
+var (
+	diffFields *bool
+	diffJSON   *bool
+)
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff <cidA> <cidB>",
+	Short: "Compare two database snapshots",
+	Long: `Compare two database snapshots using an open
+	database.
+	
+	Lists the keys which were added, removed or changed
+	(linked to a different record CID) between snapshot
+	A and snapshot B. Use --fields to also compare the
+	fields of each changed record.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		runDiff(args[0], args[1])
+	},
+}
+
+func init() {
+	diffFields = diffCmd.Flags().BoolP("fields", "f", false, "If true, include a field-level diff for changed records")
+	diffJSON = diffCmd.Flags().BoolP("json", "j", false, "If true, output will be JSON")
+	rootCmd.AddCommand(diffCmd)
+}
+
+func runDiff(snapshotA, snapshotB string) {
+
+	// get context
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// connect to the server
+	conn, err := grpc.DialContext(ctx, viper.GetString("Address"), grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		log.Fatalf("could not connect to a database: %v", err)
+	}
+	defer conn.Close()
+	c := stark.NewStarkDbClient(conn)
+
+	// make a Diff request
+	diff, err := c.Diff(ctx, &stark.DiffRequest{SnapshotA: snapshotA, SnapshotB: snapshotB, Fields: *diffFields})
+	config.CheckResponseErr(err)
+
+	// print the diff
+	if *diffJSON {
+		data, err := json.MarshalIndent(diff, "", "\t")
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(string(data))
+		return
+	}
+	for _, key := range diff.GetAdded() {
+		fmt.Printf("+ %v\n", key)
+	}
+	for _, key := range diff.GetRemoved() {
+		fmt.Printf("- %v\n", key)
+	}
+	for _, change := range diff.GetChanged() {
+		fmt.Printf("~ %v (%v -> %v)\n", change.GetKey(), change.GetCidA(), change.GetCidB())
+		for _, field := range change.GetFields() {
+			fmt.Printf("\t%v: %v -> %v\n", field.GetField(), field.GetValueA(), field.GetValueB())
+		}
+	}
+}
@@ -0,0 +1,104 @@
+/*
+Copyright © 2020 Will Rowe <w.p.m.rowe@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/will-rowe/stark"
+	"github.com/will-rowe/stark/stark/config"
+	"google.golang.org/grpc"
+)
+
+var (
+	historyFull *bool
+)
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history <key>",
+	Short: "Get the version history of a record from an open database",
+	Long: `Get the version history of a record from an open
+	database.
+	
+	Every stored version of the record is listed, starting
+	with the current version. Use --full to print each
+	version of the record as JSON.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runHistory(args[0])
+	},
+}
+
+func init() {
+	historyFull = historyCmd.Flags().BoolP("full", "f", false, "If true, print the full record for each version")
+	rootCmd.AddCommand(historyCmd)
+}
+
+func runHistory(key string) {
+
+	// get context
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// connect to the server
+	conn, err := grpc.DialContext(ctx, viper.GetString("Address"), grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		log.Fatalf("could not connect to a database: %v", err)
+	}
+	defer conn.Close()
+	c := stark.NewStarkDbClient(conn)
+
+	// make a History request
+	stream, err := c.History(ctx, &stark.Key{Key: key})
+	config.CheckResponseErr(err)
+
+	// print each version as it arrives
+	for i := 0; ; i++ {
+		version, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		config.CheckResponseErr(err)
+		if *historyFull {
+			data, err := json.MarshalIndent(version, "", "\t")
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(string(data))
+			continue
+		}
+		updated, err := ptypes.Timestamp(version.GetUpdated())
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%d\t%v\t%v\n", i, version.GetCid(), updated.Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright © 2020 Will Rowe <w.p.m.rowe@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/will-rowe/stark"
+	"github.com/will-rowe/stark/stark/config"
+	"google.golang.org/grpc"
+)
+
+var (
+	mergeAncestor *string
+	mergeDryRun   *bool
+	mergeJSON     *bool
+)
+
+// mergeCmd represents the merge command
+var mergeCmd = &cobra.Command{
+	Use:   "merge <cid>",
+	Short: "Merge a snapshot into an open database",
+	Long: `Merge a snapshot into an open database.
+	
+	A three-way merge is performed between the current
+	database snapshot, the provided snapshot and their
+	common ancestor (found using the snapshot lineage,
+	unless --ancestor is given).
+	
+	Keys changed in both snapshots are resolved using
+	the record UUIDs and histories. If any conflicts
+	can't be resolved they are reported and the open
+	database is left unchanged.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runMerge(args[0])
+	},
+}
+
+func init() {
+	mergeAncestor = mergeCmd.Flags().StringP("ancestor", "c", "", "CID of the common ancestor snapshot (found using the snapshot lineage if not provided)")
+	mergeDryRun = mergeCmd.Flags().BoolP("dryRun", "d", false, "If true, report the merge without updating the open database")
+	mergeJSON = mergeCmd.Flags().BoolP("json", "j", false, "If true, output will be JSON")
+	rootCmd.AddCommand(mergeCmd)
+}
+
+func runMerge(head string) {
+
+	// get context
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// connect to the server
+	conn, err := grpc.DialContext(ctx, viper.GetString("Address"), grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		log.Fatalf("could not connect to a database: %v", err)
+	}
+	defer conn.Close()
+	c := stark.NewStarkDbClient(conn)
+
+	// make a Merge request
+	response, err := c.Merge(ctx, &stark.MergeRequest{Ancestor: *mergeAncestor, HeadB: head, DryRun: *mergeDryRun})
+	config.CheckResponseErr(err)
+
+	// print the result
+	if *mergeJSON {
+		data, err := json.MarshalIndent(response, "", "\t")
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(string(data))
+		return
+	}
+	log.Infof("common ancestor: %v", response.GetAncestor())
+	log.Infof("merged snapshot: %v", response.GetSnapshotCID())
+	for _, conflict := range response.GetConflicts() {
+		log.Warnf("\tconflict: %v (%v)", conflict.GetKey(), conflict.GetReason())
+		log.Warnf("\t\tours: %v", conflict.GetCidA())
+		log.Warnf("\t\ttheirs: %v", conflict.GetCidB())
+	}
+	switch {
+	case !response.GetSuccess():
+		log.Warnf("merge has %d unresolved conflicts, the open database was not updated", len(response.GetConflicts()))
+	case *mergeDryRun:
+		log.Info("dry run, the open database was not updated")
+	default:
+		log.Info("open database updated to the merged snapshot")
+	}
+}
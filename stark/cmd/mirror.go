@@ -0,0 +1,194 @@
+/*
+Copyright © 2020 Will Rowe <w.p.m.rowe@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	starkdb "github.com/will-rowe/stark"
+	"github.com/will-rowe/stark/stark/config"
+	"google.golang.org/grpc"
+)
+
+var (
+	mirrorProject  *string
+	mirrorPeers    *[]string
+	mirrorRepoPath *string
+	mirrorAPIAddr  *string
+)
+
+// mirrorCmd represents the mirror command
+var mirrorCmd = &cobra.Command{
+	Use:   "mirror <ipns name or snapshot>",
+	Short: "Mirror a remote stark database",
+	Long: `Mirror a remote stark database.
+	
+	The mirror follows the head of a remote project, using
+	either an IPNS name or a snapshot CID. Every record,
+	along with previous versions of the records and any
+	linked content, is pinned in the local IPFS repo.
+	
+	The mirrored database is served read-only via a gRPC
+	server, so get and dump can be used as normal.
+	
+	The mirrored snapshot is saved in the config under the
+	project name given with --project, which must not be
+	used by any other database.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runMirror(args[0])
+	},
+}
+
+func init() {
+	mirrorProject = mirrorCmd.Flags().StringP("project", "p", "", "Project name to save the mirrored snapshot under in the config (required)")
+	mirrorCmd.MarkFlagRequired("project")
+	mirrorRepoPath = mirrorCmd.Flags().StringP("withRepo", "r", "", "IPFS repo to use for the mirror, will be initialised if needed (overrides the repoPath in the config)")
+	mirrorAPIAddr = mirrorCmd.Flags().String("withAPI", "", "HTTP API multiaddr of a running IPFS daemon to attach to (e.g. /ip4/127.0.0.1/tcp/5001)")
+	mirrorPeers = mirrorCmd.Flags().StringSliceP("withPeers", "x", nil, "List of peer addresses to connect the mirror with (in addition to default bootstrappers)")
+	rootCmd.AddCommand(mirrorCmd)
+}
+
+func runMirror(source string) {
+	log.Info("--------------------STARK--------------------")
+	log.Info("starting mirror...")
+	log.Infof("\tmirror source: %v", source)
+	log.Infof("\tproject name: %v", *mirrorProject)
+	if len(*mirrorProject) == 0 {
+		log.Fatal(starkdb.ErrNoProject)
+	}
+	projs := viper.GetStringMapString("Databases")
+	projectSnapshot := projs[*mirrorProject]
+	if len(projectSnapshot) != 0 {
+		log.Infof("\tlast mirrored snapshot: %v", projectSnapshot)
+	}
+
+	// create a message channel for internal logging
+	msgChan := make(chan interface{})
+	defer close(msgChan)
+	go func() {
+		for msg := range msgChan {
+			log.Infof("\t%v", msg)
+		}
+	}()
+
+	// setup the db opts
+	log.Info("opening mirror...")
+	dbOpts := []starkdb.DbOption{
+		starkdb.SetProject(*mirrorProject),
+		starkdb.WithLogging(msgChan),
+		starkdb.WithMirror(source),
+	}
+	if len(projectSnapshot) != 0 {
+		dbOpts = append(dbOpts, starkdb.SetSnapshotCID(projectSnapshot))
+	}
+	if len(*mirrorRepoPath) == 0 {
+		*mirrorRepoPath = viper.GetString("RepoPath")
+	}
+	if len(*mirrorRepoPath) != 0 {
+		log.Infof("\tusing IPFS repo: %v", *mirrorRepoPath)
+		dbOpts = append(dbOpts, starkdb.WithRepoPath(*mirrorRepoPath))
+	}
+	if len(*mirrorAPIAddr) != 0 {
+		log.Infof("\tusing IPFS daemon: %v", *mirrorAPIAddr)
+		dbOpts = append(dbOpts, starkdb.WithIPFSAPI(*mirrorAPIAddr))
+	}
+	if len(*mirrorPeers) != 0 {
+		log.Info("\tusing extra peers")
+		dbOpts = append(dbOpts, starkdb.WithPeers(*mirrorPeers))
+	}
+
+	// open the db
+	db, dbCloser, err := starkdb.OpenDB(dbOpts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// set up a control channel
+	errChan := make(chan error)
+
+	// set up the gRPC server
+	address := viper.GetString("Address")
+	log.Info("starting gRPC server...")
+	log.Infof("\taddress: %s", address)
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+	grpcServer := grpc.NewServer()
+	starkdb.RegisterStarkDbServer(grpcServer, db)
+
+	// defer the mirror close down
+	defer func() {
+		log.Info("shutting down...")
+		log.Infof("\tcurrent number of entries in mirror: %d", db.GetNumEntries())
+
+		// save the mirrored snapshot so the mirror can be resumed
+		newSnapshot := db.GetSnapshot()
+		conf, err := config.DumpConfig2Mem()
+		if err != nil {
+			log.Fatal(err)
+		}
+		conf.Databases[*mirrorProject] = newSnapshot
+		if err := conf.WriteConfig(); err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("\tsnapshot: %v", newSnapshot)
+
+		// close the mirror and IPFS
+		if err := dbCloser(); err != nil {
+			log.Fatal(err)
+		}
+		log.Info("\tclosed mirror")
+
+		// close down the server
+		grpcServer.GracefulStop()
+		log.Info("\tstopped the gRPC server")
+		log.Info("finished.")
+	}()
+
+	// start serving
+	go func() {
+		if err := grpcServer.Serve(lis); err != nil {
+			errChan <- err
+		}
+	}()
+
+	// setup the interupt
+	interupt := make(chan os.Signal, 1)
+	signal.Notify(interupt, os.Interrupt, syscall.SIGTERM)
+
+	// block until interupt or server error
+	log.Info("ready...")
+	select {
+	case err := <-errChan:
+		log.Errorf("\t%v", err)
+	case <-interupt:
+	}
+	log.Info("interupt received")
+}
@@ -22,7 +22,6 @@ THE SOFTWARE.
 package cmd
 
 import (
-	"context"
 	"fmt"
 	"net"
 	"os"
@@ -43,8 +42,19 @@ var (
 	peers          *[]string
 	announce       *bool
 	encrypt        *bool
+	clearFields    *[]string
 	listen         *bool
+	offline        *bool
 	pinataInterval *int
+	ipnsInterval   *int
+	fromIPNS       *string
+	repoPath       *string
+	apiAddr        *string
+	atSnapshot     *string
+	atTime         *string
+	conflictPolicy *string
+	requireSigned  *bool
+	trustedAuthors *[]string
 )
 
 // openCmd represents the open command
@@ -64,8 +74,19 @@ var openCmd = &cobra.Command{
 func init() {
 	announce = openCmd.Flags().BoolP("withAnnounce", "a", false, "Announce all records over PubSub as they are added to the open database")
 	encrypt = openCmd.Flags().BoolP("withEncrypt", "e", false, fmt.Sprintf("Encrypt record fields using the password stored in the %v env variable", starkdb.DefaultStarkEnvVariable))
+	clearFields = openCmd.Flags().StringSlice("withClearFields", nil, "List of record fields to leave unencrypted when using --withEncrypt (default alias)")
 	listen = openCmd.Flags().BoolP("withListen", "l", false, "Listen for records being announced over PubSub and make a copy in the open database")
+	offline = openCmd.Flags().BoolP("withOffline", "o", false, "Open the database with networking disabled (no bootstrapping, PubSub or Pinata)")
 	pinataInterval = openCmd.Flags().IntP("withPinata", "p", 0, fmt.Sprintf("Sets Pinata interval for pinning db contents - requires %v and %v to be set (<1 == Pinata disabled)", starkdb.DefaultPinataAPIkey, starkdb.DefaultPinataSecretKey))
+	ipnsInterval = openCmd.Flags().Int("withIPNS", 0, "Sets IPNS interval for publishing the database snapshot under a project key in the IPFS keystore (<1 == IPNS disabled)")
+	fromIPNS = openCmd.Flags().String("fromIPNS", "", "Open the database at the snapshot published under an IPNS name (e.g. /ipns/<name>), instead of the snapshot in the config")
+	repoPath = openCmd.Flags().StringP("withRepo", "r", "", "IPFS repo to use for the database, will be initialised if needed (overrides the repoPath in the config)")
+	apiAddr = openCmd.Flags().String("withAPI", "", "HTTP API multiaddr of a running IPFS daemon to attach to (e.g. /ip4/127.0.0.1/tcp/5001)")
+	atSnapshot = openCmd.Flags().String("atSnapshot", "", "Open the database read-only at a historic snapshot CID")
+	atTime = openCmd.Flags().String("atTime", "", "Open the database read-only as it was at a time (RFC3339, e.g. 2020-06-01T12:00:00Z)")
+	conflictPolicy = openCmd.Flags().String("withConflictPolicy", "reject", "Policy for handling conflicting records received via PubSub (reject, lastWriterWins or fork)")
+	requireSigned = openCmd.Flags().Bool("withRequireSigned", false, "Reject records which have not been signed by their author")
+	trustedAuthors = openCmd.Flags().StringSlice("withTrustedAuthors", nil, "List of author peer IDs to accept records from when using --withRequireSigned (default any author)")
 	peers = openCmd.Flags().StringSliceP("withPeers", "x", nil, "List of peer addresses to connect the database with (in addition to default bootstrappers)")
 	rootCmd.AddCommand(openCmd)
 }
@@ -99,9 +120,24 @@ func runOpen(projectName string) {
 		starkdb.SetProject(projectName),
 		starkdb.WithLogging(msgChan),
 	}
+	if len(*fromIPNS) != 0 {
+		log.Infof("\tusing IPNS name: %v", *fromIPNS)
+		projectSnapshot = *fromIPNS
+	}
 	if len(projectSnapshot) != 0 {
 		dbOpts = append(dbOpts, starkdb.SetSnapshotCID(projectSnapshot))
 	}
+	if len(*repoPath) == 0 {
+		*repoPath = viper.GetString("RepoPath")
+	}
+	if len(*repoPath) != 0 {
+		log.Infof("\tusing IPFS repo: %v", *repoPath)
+		dbOpts = append(dbOpts, starkdb.WithRepoPath(*repoPath))
+	}
+	if len(*apiAddr) != 0 {
+		log.Infof("\tusing IPFS daemon: %v", *apiAddr)
+		dbOpts = append(dbOpts, starkdb.WithIPFSAPI(*apiAddr))
+	}
 	if *announce {
 		log.Info("\tusing announce")
 		dbOpts = append(dbOpts, starkdb.WithAnnouncing())
@@ -109,13 +145,47 @@ func runOpen(projectName string) {
 	if *encrypt {
 		log.Info("\tusing encryption")
 		dbOpts = append(dbOpts, starkdb.WithEncryption())
+		if *clearFields != nil {
+			log.Infof("\tleaving fields unencrypted: %v", *clearFields)
+			dbOpts = append(dbOpts, starkdb.WithClearFields(*clearFields...))
+		}
+	}
+	if *offline {
+		log.Info("\tusing offline mode")
+		dbOpts = append(dbOpts, starkdb.WithOffline())
 	}
 	if *pinataInterval > 0 {
 		log.Infof("\tusing Pinata every %d records", *pinataInterval)
 		dbOpts = append(dbOpts, starkdb.WithPinata(*pinataInterval))
 	}
+	if *ipnsInterval > 0 {
+		log.Infof("\tusing IPNS every %d records", *ipnsInterval)
+		dbOpts = append(dbOpts, starkdb.WithIPNS(*ipnsInterval))
+	}
 	if *listen {
 		log.Info("\tusing listen")
+		dbOpts = append(dbOpts, starkdb.WithAutoSync())
+		log.Infof("\tusing conflict policy: %v", *conflictPolicy)
+		dbOpts = append(dbOpts, starkdb.WithConflictPolicy(getConflictPolicy(*conflictPolicy)))
+	}
+	if *requireSigned {
+		log.Info("\tusing signed records only")
+		if *trustedAuthors != nil {
+			log.Infof("\ttrusting authors: %v", *trustedAuthors)
+		}
+		dbOpts = append(dbOpts, starkdb.WithRequireSigned(*trustedAuthors...))
+	}
+	if len(*atSnapshot) != 0 {
+		log.Infof("\tusing historic snapshot: %v (read-only)", *atSnapshot)
+		dbOpts = append(dbOpts, starkdb.WithHistoricSnapshot(*atSnapshot))
+	}
+	if len(*atTime) != 0 {
+		asOf, err := time.Parse(time.RFC3339, *atTime)
+		if err != nil {
+			log.Fatalf("could not parse time: %v", err)
+		}
+		log.Infof("\tusing historic time: %v (read-only)", asOf)
+		dbOpts = append(dbOpts, starkdb.WithHistoricTime(asOf))
 	}
 	if len(*peers) != 0 {
 		log.Info("\tusing extra peers")
@@ -128,34 +198,15 @@ func runOpen(projectName string) {
 		log.Fatal(err)
 	}
 	log.Infof("\tcurrent number of entries in database: %d", db.GetNumEntries())
+	if name := db.GetIPNSName(); len(name) != 0 {
+		log.Infof("\tIPNS name: %v", name)
+	}
+	if recipientKey, err := db.RecipientKey(); err == nil {
+		log.Infof("\trecipient key: %v", recipientKey)
+	}
 
-	// set up some control channels
+	// set up a control channel
 	errChan := make(chan error)
-	terminator := make(chan struct{})
-
-	// start the listener if requested
-	if *listen {
-		log.Info("starting the PubSub listener...")
-		recs, errs, err := db.Listen(terminator)
-		if err != nil {
-			log.Fatal(err)
-		}
-		go func() {
-			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-			defer cancel()
-			select {
-			case rec := <-recs:
-				log.Infof("\tPubSub: found record (%v)", rec.GetUuid())
-				_, err := db.Set(ctx, &starkdb.KeyRecordPair{Key: rec.GetAlias(), Record: rec})
-				if err != nil {
-					errChan <- err
-				}
-				log.Infof("\tPubSub: added new record (%v->%v)", rec.GetAlias(), rec.GetPreviousCID())
-			case err := <-errs:
-				errChan <- err
-			}
-		}()
-	}
 
 	// set up the gRPC server
 	address := viper.GetString("Address")
@@ -173,18 +224,17 @@ func runOpen(projectName string) {
 		log.Info("shutting down...")
 		log.Infof("\tcurrent number of entries in database: %d", db.GetNumEntries())
 
-		// close the listener
-		close(terminator)
-
-		// update the snapshot
+		// update the snapshot (unless opened at a historic snapshot)
 		newSnapshot := db.GetSnapshot()
-		conf, err := config.DumpConfig2Mem()
-		if err != nil {
-			log.Fatal(err)
-		}
-		conf.Databases[projectName] = newSnapshot
-		if err := conf.WriteConfig(); err != nil {
-			log.Fatal(err)
+		if !db.IsReadOnly() {
+			conf, err := config.DumpConfig2Mem()
+			if err != nil {
+				log.Fatal(err)
+			}
+			conf.Databases[projectName] = newSnapshot
+			if err := conf.WriteConfig(); err != nil {
+				log.Fatal(err)
+			}
 		}
 		if len(newSnapshot) != 0 {
 			log.Infof("\tsnapshot: %v", newSnapshot)
@@ -222,3 +272,19 @@ func runOpen(projectName string) {
 	}
 	log.Info("interupt received")
 }
+
+// getConflictPolicy returns the conflict policy for
+// the provided flag value.
+func getConflictPolicy(policy string) starkdb.ConflictPolicy {
+	switch policy {
+	case "reject":
+		return starkdb.RejectAndReport
+	case "lastWriterWins":
+		return starkdb.LastWriterWins
+	case "fork":
+		return starkdb.KeepBothAsFork
+	default:
+		log.Fatalf("unknown conflict policy: %v", policy)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+/*
+Copyright © 2020 Will Rowe <w.p.m.rowe@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	starkdb "github.com/will-rowe/stark"
+	"github.com/will-rowe/stark/stark/config"
+)
+
+var (
+	rekeyPeers    *[]string
+	rekeyRepoPath *string
+	rekeyAPIAddr  *string
+)
+
+// rekeyCmd represents the rekey command
+var rekeyCmd = &cobra.Command{
+	Use:   "rekey <project>",
+	Short: "Change the password used to encrypt a database",
+	Long: `Change the password used to encrypt a database.
+	
+	Every encrypted record in the current snapshot is
+	decrypted using the password in the ` + starkdb.DefaultStarkEnvVariable + ` env
+	variable and re-encrypted using the password in the
+	` + starkdb.DefaultStarkNewEnvVariable + ` env variable. The new record
+	versions have a comment added to their history.
+	
+	If the rekey is interrupted, the progress is saved to
+	the config and running rekey again with the same
+	passwords will resume it.
+	
+	The database must not be open while rekeying, the new
+	snapshot is saved to the config once the rekey is done.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runRekey(args[0])
+	},
+}
+
+func init() {
+	rekeyRepoPath = rekeyCmd.Flags().StringP("withRepo", "r", "", "IPFS repo to use for the database, will be initialised if needed (overrides the repoPath in the config)")
+	rekeyAPIAddr = rekeyCmd.Flags().String("withAPI", "", "HTTP API multiaddr of a running IPFS daemon to attach to (e.g. /ip4/127.0.0.1/tcp/5001)")
+	rekeyPeers = rekeyCmd.Flags().StringSliceP("withPeers", "x", nil, "List of peer addresses to connect the database with (in addition to default bootstrappers)")
+	rootCmd.AddCommand(rekeyCmd)
+}
+
+func runRekey(projectName string) {
+	log.Info("--------------------STARK--------------------")
+	log.Info("starting rekey...")
+	log.Infof("\tproject name: %v", projectName)
+	projs := viper.GetStringMapString("Databases")
+	projectSnapshot, ok := projs[projectName]
+	if !ok || len(projectSnapshot) == 0 {
+		log.Fatalf("no snapshot found for project: %v", projectName)
+	}
+	log.Infof("\tsnapshot: %v", projectSnapshot)
+
+	// get the passwords
+	oldPassword, ok := os.LookupEnv(starkdb.DefaultStarkEnvVariable)
+	if !ok {
+		log.Fatal(starkdb.ErrNoEnvSet)
+	}
+	newPassword, ok := os.LookupEnv(starkdb.DefaultStarkNewEnvVariable)
+	if !ok {
+		log.Fatalf("no %s environment variable found", starkdb.DefaultStarkNewEnvVariable)
+	}
+
+	// create a message channel for internal logging
+	msgChan := make(chan interface{})
+	defer close(msgChan)
+	go func() {
+		for msg := range msgChan {
+			log.Infof("\t%v", msg)
+		}
+	}()
+
+	// setup the db opts
+	log.Info("opening database...")
+	dbOpts := []starkdb.DbOption{
+		starkdb.SetProject(projectName),
+		starkdb.SetSnapshotCID(projectSnapshot),
+		starkdb.WithLogging(msgChan),
+		starkdb.WithEncryption(),
+	}
+	if len(*rekeyRepoPath) == 0 {
+		*rekeyRepoPath = viper.GetString("RepoPath")
+	}
+	if len(*rekeyRepoPath) != 0 {
+		log.Infof("\tusing IPFS repo: %v", *rekeyRepoPath)
+		dbOpts = append(dbOpts, starkdb.WithRepoPath(*rekeyRepoPath))
+	}
+	if len(*rekeyAPIAddr) != 0 {
+		log.Infof("\tusing IPFS daemon: %v", *rekeyAPIAddr)
+		dbOpts = append(dbOpts, starkdb.WithIPFSAPI(*rekeyAPIAddr))
+	}
+	if len(*rekeyPeers) != 0 {
+		log.Info("\tusing extra peers")
+		dbOpts = append(dbOpts, starkdb.WithPeers(*rekeyPeers))
+	}
+
+	// open the db
+	db, dbCloser, err := starkdb.OpenDB(dbOpts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := dbCloser(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+	log.Infof("\tcurrent number of entries in database: %d", db.GetNumEntries())
+
+	// stop the rekey on interupt
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interupt := make(chan os.Signal, 1)
+	signal.Notify(interupt, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		if _, ok := <-interupt; ok {
+			log.Info("interupt received")
+			cancel()
+		}
+	}()
+	defer signal.Stop(interupt)
+
+	// run the rekey
+	log.Info("re-encrypting records...")
+	report, rekeyErr := db.RotateKey(ctx, oldPassword, newPassword)
+
+	// save the new snapshot, even if the rekey was interupted
+	conf, err := config.DumpConfig2Mem()
+	if err != nil {
+		log.Fatal(err)
+	}
+	conf.Databases[projectName] = db.GetSnapshot()
+	if err := conf.WriteConfig(); err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("\tsnapshot: %v", db.GetSnapshot())
+	if report != nil {
+		log.Infof("\trecords re-encrypted: %d", report.Rekeyed)
+		log.Infof("\trecords already re-encrypted: %d", report.Resumed)
+		log.Infof("\trecords skipped (unencrypted or shared with recipients): %d", report.Skipped)
+	}
+	if rekeyErr != nil {
+		log.Warn("rekey did not finish, run rekey again with the same passwords to resume it")
+		log.Fatal(rekeyErr)
+	}
+	log.Infof("finished, use the %v password from now on.", starkdb.DefaultStarkNewEnvVariable)
+}
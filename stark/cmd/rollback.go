@@ -0,0 +1,86 @@
+/*
+Copyright © 2020 Will Rowe <w.p.m.rowe@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/will-rowe/stark"
+	"github.com/will-rowe/stark/stark/config"
+	"google.golang.org/grpc"
+)
+
+var (
+	targetCID     *string
+	rollbackSteps *int32
+)
+
+// rollbackCmd represents the rollback command
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback <key>",
+	Short: "Rollback a record in an open database",
+	Long: `Rollback a record in an open database to
+	a previous version.
+	
+	The version to restore can be given as a CID
+	from the record history, or as a number of
+	steps back through the history (default is
+	one step). The restored record is added as
+	a new version, so rollbacks can be undone.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runRollback(args[0])
+	},
+}
+
+func init() {
+	targetCID = rollbackCmd.Flags().StringP("toCID", "c", "", "CID of the record version to restore (overrides --steps)")
+	rollbackSteps = rollbackCmd.Flags().Int32P("steps", "s", 1, "Number of versions to step back through the record history")
+	rootCmd.AddCommand(rollbackCmd)
+}
+
+func runRollback(key string) {
+
+	// get context
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// connect to the server
+	conn, err := grpc.DialContext(ctx, viper.GetString("Address"), grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		log.Fatalf("could not connect to a database: %v", err)
+	}
+	defer conn.Close()
+	c := stark.NewStarkDbClient(conn)
+
+	// make a Rollback request
+	response, err := c.Rollback(ctx, &stark.RollbackRequest{Key: key, TargetCID: *targetCID, Steps: *rollbackSteps})
+	config.CheckResponseErr(err)
+
+	// print the key and new cid
+	log.Info("rolled back Record in database:")
+	log.Infof("\t%v -> %v", key, response.GetRecord().GetPreviousCID())
+}
@@ -0,0 +1,186 @@
+/*
+Copyright © 2020 Will Rowe <w.p.m.rowe@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	starkdb "github.com/will-rowe/stark"
+	"github.com/will-rowe/stark/stark/config"
+)
+
+var (
+	syncPeers          *[]string
+	syncRepoPath       *string
+	syncAPIAddr        *string
+	syncEncrypt        *bool
+	syncConflictPolicy *string
+	syncSnapshot       *string
+	syncWait           *time.Duration
+	syncRequireSigned  *bool
+	syncTrustedAuthors *[]string
+)
+
+// syncCmd represents the sync command
+var syncCmd = &cobra.Command{
+	Use:   "sync <project>",
+	Short: "Catch up a database with the other databases on its project",
+	Long: `Catch up a database with the other databases on its project.
+	
+	A sync request is sent over PubSub and, for the wait
+	duration, the head snapshots announced by project peers
+	are collected. Any records missing from the database are
+	then imported, using the same checks as adding a record.
+	
+	Use --fromSnapshot to sync from a known snapshot CID
+	instead of asking project peers.
+	
+	The database must not be open while syncing, the new
+	snapshot is saved to the config once the sync is done.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runSync(args[0])
+	},
+}
+
+func init() {
+	syncEncrypt = syncCmd.Flags().BoolP("withEncrypt", "e", false, fmt.Sprintf("Encrypt record fields using the password stored in the %v env variable", starkdb.DefaultStarkEnvVariable))
+	syncRepoPath = syncCmd.Flags().StringP("withRepo", "r", "", "IPFS repo to use for the database, will be initialised if needed (overrides the repoPath in the config)")
+	syncAPIAddr = syncCmd.Flags().String("withAPI", "", "HTTP API multiaddr of a running IPFS daemon to attach to (e.g. /ip4/127.0.0.1/tcp/5001)")
+	syncConflictPolicy = syncCmd.Flags().String("withConflictPolicy", "reject", "Policy for handling conflicting records (reject, lastWriterWins or fork)")
+	syncSnapshot = syncCmd.Flags().StringP("fromSnapshot", "s", "", "Sync from a snapshot CID instead of asking project peers")
+	syncWait = syncCmd.Flags().DurationP("wait", "w", starkdb.DefaultSyncWait, "Time to wait for project peers to announce their snapshots")
+	syncRequireSigned = syncCmd.Flags().Bool("withRequireSigned", false, "Reject records which have not been signed by their author")
+	syncTrustedAuthors = syncCmd.Flags().StringSlice("withTrustedAuthors", nil, "List of author peer IDs to accept records from when using --withRequireSigned (default any author)")
+	syncPeers = syncCmd.Flags().StringSliceP("withPeers", "x", nil, "List of peer addresses to connect the database with (in addition to default bootstrappers)")
+	rootCmd.AddCommand(syncCmd)
+}
+
+func runSync(projectName string) {
+	log.Info("--------------------STARK--------------------")
+	log.Info("starting sync...")
+	log.Infof("\tproject name: %v", projectName)
+	projs := viper.GetStringMapString("Databases")
+	projectSnapshot, ok := projs[projectName]
+	log.Infof("\texisting project: %v", ok)
+	if len(projectSnapshot) != 0 {
+		log.Infof("\tsnapshot: %v", projectSnapshot)
+	}
+
+	// create a message channel for internal logging
+	msgChan := make(chan interface{})
+	defer close(msgChan)
+	go func() {
+		for msg := range msgChan {
+			log.Infof("\t%v", msg)
+		}
+	}()
+
+	// setup the db opts
+	log.Info("opening database...")
+	dbOpts := []starkdb.DbOption{
+		starkdb.SetProject(projectName),
+		starkdb.WithLogging(msgChan),
+		starkdb.WithConflictPolicy(getConflictPolicy(*syncConflictPolicy)),
+	}
+	if len(projectSnapshot) != 0 {
+		dbOpts = append(dbOpts, starkdb.SetSnapshotCID(projectSnapshot))
+	}
+	if len(*syncRepoPath) == 0 {
+		*syncRepoPath = viper.GetString("RepoPath")
+	}
+	if len(*syncRepoPath) != 0 {
+		log.Infof("\tusing IPFS repo: %v", *syncRepoPath)
+		dbOpts = append(dbOpts, starkdb.WithRepoPath(*syncRepoPath))
+	}
+	if len(*syncAPIAddr) != 0 {
+		log.Infof("\tusing IPFS daemon: %v", *syncAPIAddr)
+		dbOpts = append(dbOpts, starkdb.WithIPFSAPI(*syncAPIAddr))
+	}
+	if *syncEncrypt {
+		log.Info("\tusing encryption")
+		dbOpts = append(dbOpts, starkdb.WithEncryption())
+	}
+	if *syncRequireSigned {
+		log.Info("\tusing signed records only")
+		if *syncTrustedAuthors != nil {
+			log.Infof("\ttrusting authors: %v", *syncTrustedAuthors)
+		}
+		dbOpts = append(dbOpts, starkdb.WithRequireSigned(*syncTrustedAuthors...))
+	}
+	if len(*syncPeers) != 0 {
+		log.Info("\tusing extra peers")
+		dbOpts = append(dbOpts, starkdb.WithPeers(*syncPeers))
+	}
+
+	// open the db
+	db, dbCloser, err := starkdb.OpenDB(dbOpts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := dbCloser(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+	log.Infof("\tcurrent number of entries in database: %d", db.GetNumEntries())
+
+	// run the sync
+	var report *starkdb.SyncReport
+	if len(*syncSnapshot) != 0 {
+		log.Infof("syncing from snapshot: %v", *syncSnapshot)
+		report, err = db.SyncFromSnapshot(context.Background(), *syncSnapshot)
+	} else {
+		log.Infof("syncing from project peers (waiting %v)...", *syncWait)
+		report, err = db.SyncFromPeers(context.Background(), *syncWait)
+	}
+	if err != nil && err != starkdb.ErrNoSyncResponse {
+		log.Fatal(err)
+	}
+	if err == starkdb.ErrNoSyncResponse {
+		log.Warn(err)
+	}
+	log.Infof("\tsnapshots synced: %d", len(report.Snapshots))
+	log.Infof("\trecords imported: %d", report.Imported)
+	log.Infof("\trecords skipped: %d", report.Skipped)
+	for _, conflict := range report.Conflicts {
+		log.Warnf("\t%v", conflict)
+	}
+
+	// save the new snapshot
+	conf, err := config.DumpConfig2Mem()
+	if err != nil {
+		log.Fatal(err)
+	}
+	conf.Databases[projectName] = db.GetSnapshot()
+	if err := conf.WriteConfig(); err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("\tcurrent number of entries in database: %d", db.GetNumEntries())
+	log.Infof("\tsnapshot: %v", db.GetSnapshot())
+	log.Info("finished.")
+}
@@ -32,6 +32,9 @@ var (
 	// DefaultAddress is the network address for the gRPC server.
 	DefaultAddress = "localhost:50051"
 
+	// DefaultRepoPath is the IPFS repo to use (empty = system default IPFS repo).
+	DefaultRepoPath = ""
+
 	// ErrInvalidPath is used when the config file path is bad or doesn't exist.
 	ErrInvalidPath = fmt.Errorf("invalid config filepath")
 )
@@ -43,6 +46,7 @@ type StarkConfig struct {
 	FileType   string            `json:"fileType"`
 	License    string            `json:"license"`
 	Address    string            `json:"address"`
+	RepoPath   string            `json:"repoPath"`
 	Databases  map[string]string `json:"databases"`
 }
 
@@ -85,6 +89,7 @@ func GenerateDefault(filePath string) error {
 		FileType:   DefaultType,
 		License:    DefaultLicense,
 		Address:    DefaultAddress,
+		RepoPath:   DefaultRepoPath,
 		Databases:  make(map[string]string),
 	}
 	return defaultConfig.WriteConfig()
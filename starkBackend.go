@@ -0,0 +1,57 @@
+package stark
+
+import (
+	"context"
+
+	ipld "github.com/ipfs/go-ipld-format"
+	icore "github.com/ipfs/interface-go-ipfs-core"
+	libp2pcrypto "github.com/libp2p/go-libp2p-core/crypto"
+)
+
+// Backend is the interface used by starkDB for storing
+// Records, managing project snapshots and sending
+// PubSub messages.
+//
+// The default Backend is an IPFS client (see the
+// src/ipfs package) but any type which satisfies this
+// interface can be passed to OpenDB using the
+// WithBackend option.
+//
+// Unpin should return ErrNotPinned (or an error which
+// wraps it) for a CID which is not pinned.
+type Backend interface {
+
+	// DAG:
+	DagPut(ctx context.Context, data []byte, pinning bool) (string, error)
+	DagPutBatch(ctx context.Context, data [][]byte, pinning bool) ([]string, error)
+	DagGet(ctx context.Context, queryCID string) (interface{}, error)
+	NewDagNode(ctx context.Context) (string, error)
+	AddLink(ctx context.Context, baseCID, childCID, linkLabel string) (string, error)
+	AddLinks(ctx context.Context, baseCID string, links map[string]string) (string, error)
+	RmLink(ctx context.Context, baseCID, linkLabel string) (string, error)
+	GetNodeLinks(ctx context.Context, nodeCID string) ([]*ipld.Link, error)
+	ForEachLink(ctx context.Context, nodeCID string, f func(*ipld.Link) error) error
+	Pin(ctx context.Context, cidStr string, recursive bool) error
+	Unpin(ctx context.Context, cidStr string) error
+
+	// Node:
+	Online() bool
+	Connect(ctx context.Context, peers []string, logger chan interface{})
+	PrintNodeID() string
+	PrivateKey() (libp2pcrypto.PrivKey, error)
+	GetPublicIPv4Addr() (string, error)
+	GetPeers(ctx context.Context) ([]string, error)
+	EndSession() error
+
+	// IPNS:
+	NameKey(ctx context.Context, keyName string) (string, error)
+	PublishName(ctx context.Context, keyName, cid string) (string, error)
+	ResolveName(ctx context.Context, name string) (string, error)
+
+	// PubSub:
+	Subscribe(ctx context.Context, topic string) error
+	Unsubscribe() error
+	SendMessage(ctx context.Context, topic string, message []byte) error
+	GetPSMchan() chan icore.PubSubMessage
+	GetPSEchan() chan error
+}
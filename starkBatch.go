@@ -0,0 +1,152 @@
+package stark
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// BatchError reports the KeyRecordPair which caused
+// a batch to be rejected by SetBatch.
+type BatchError struct {
+	Index int    // the position of the KeyRecordPair in the batch
+	Key   string // the key of the KeyRecordPair
+	Err   error  // the check which failed
+}
+
+// Error returns a description of the batch error.
+func (batchErr *BatchError) Error() string {
+	return fmt.Sprintf("batch rejected at entry %d (%v): %v", batchErr.Index, batchErr.Key, batchErr.Err)
+}
+
+// Unwrap returns the check which failed.
+func (batchErr *BatchError) Unwrap() error {
+	return batchErr.Err
+}
+
+// SetBatch will add several KeyRecordPairs to the
+// starkDB in a single operation.
+//
+// Every KeyRecordPair is checked in the same way as Set
+// before anything is written. If any of them fail, the
+// batch is rejected with a *BatchError and the database
+// is left unchanged (the provided Records are also left
+// unchanged, as copies are added to the database).
+//
+// The Records are added to the IPFS in one batch and
+// then linked to the project directory in a single
+// snapshot update, so importing many Records only
+// produces one new snapshot.
+//
+// It will return a response, which contains copies of
+// the Records that were added (in the order they were
+// given, each with its CID) and the updated snapshot.
+func (starkdb *Db) SetBatch(ctx context.Context, pairs []*KeyRecordPair) (*BatchResponse, error) {
+	starkdb.Lock()
+	defer starkdb.Unlock()
+
+	// check the batch and database
+	if len(pairs) == 0 {
+		return nil, ErrEmptyBatch
+	}
+	if starkdb.readOnly {
+		return nil, ErrReadOnly
+	}
+
+	// check every KeyRecordPair before anything is written
+	records := make([]*Record, len(pairs))
+	seen := make(map[string]struct{}, len(pairs))
+	newEntries := 0
+	for i, krp := range pairs {
+		key, record := krp.GetKey(), krp.GetRecord()
+		update, err := starkdb.checkBatchEntry(key, record, seen)
+		if err != nil {
+			return nil, &BatchError{Index: i, Key: key, Err: err}
+		}
+		seen[key] = struct{}{}
+		record = proto.Clone(record).(*Record)
+		if update {
+			record.AddComment("SetBatch: updating record.")
+		} else {
+			newEntries++
+		}
+		record.AddComment("SetBatch: adding record to IPFS.")
+		records[i] = record
+	}
+
+	// encrypt, sign and marshal the Records
+	data := make([][]byte, len(records))
+	for i, record := range records {
+		if err := starkdb.encryptRecord(record); err != nil {
+			return nil, err
+		}
+		if err := starkdb.signRecord(record); err != nil {
+			return nil, err
+		}
+		jsonData, err := json.Marshal(record)
+		if err != nil {
+			return nil, err
+		}
+		data[i] = jsonData
+	}
+
+	// add the Records to the IPFS and update the snapshot
+	cids, err := starkdb.backend.DagPutBatch(ctx, data, starkdb.pinning)
+	if err != nil {
+		return nil, err
+	}
+	links := make(map[string]string, len(pairs))
+	for i, krp := range pairs {
+		links[krp.GetKey()] = cids[i]
+	}
+	if err := starkdb.linkRecords(ctx, links); err != nil {
+		return nil, err
+	}
+	starkdb.currentNumEntries += newEntries
+	starkdb.send2log(fmt.Sprintf("record batch added: %d records->%v", len(pairs), starkdb.snapshotCID))
+
+	// if announcing, do it now (the batch is already committed so failures are only logged)
+	if starkdb.announcing {
+		for i, krp := range pairs {
+			if err := starkdb.publishAnnouncement(starkdb.newAnnouncement(Announcement_SET, krp.GetKey(), cids[i], records[i])); err != nil {
+				starkdb.send2log(fmt.Sprintf("could not announce %v: %v", krp.GetKey(), err))
+			}
+		}
+	}
+
+	// run any Pinata or IPNS uploads which are due
+	if err := starkdb.runSessionIntervals(len(pairs)); err != nil {
+		return nil, err
+	}
+
+	// add the CIDs to the records and return
+	added := make([]*KeyRecordPair, len(pairs))
+	for i, krp := range pairs {
+		records[i].PreviousCID = cids[i]
+		added[i] = &KeyRecordPair{Key: krp.GetKey(), Record: records[i]}
+	}
+	return &BatchResponse{Success: true, Pairs: added, SnapshotCID: starkdb.snapshotCID}, nil
+}
+
+// checkBatchEntry is a helper method that runs the Set
+// checks on a KeyRecordPair from a batch. Keys already
+// seen in the batch are rejected.
+//
+// It returns true if the Record is an update.
+func (starkdb *Db) checkBatchEntry(key string, record *Record, seen map[string]struct{}) (bool, error) {
+	if len(key) == 0 {
+		return false, ErrNoKey
+	}
+	if isReservedKey(key) {
+		return false, ErrReservedKey
+	}
+	if _, ok := seen[key]; ok {
+		return false, ErrBatchDuplicate
+	}
+	if record == nil {
+		return false, ErrNoRecord
+	}
+	return starkdb.checkRecord(key, record)
+}
@@ -0,0 +1,249 @@
+package stark
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	starkhelpers "github.com/will-rowe/stark/src/helpers"
+)
+
+// ConflictResolution is the action taken by a
+// ConflictPolicy for a conflicting Record.
+type ConflictResolution int
+
+const (
+
+	// ConflictReject drops the incoming Record and keeps the existing Record.
+	ConflictReject ConflictResolution = iota
+
+	// ConflictAccept replaces the existing Record with the incoming Record.
+	ConflictAccept
+
+	// ConflictFork keeps the existing Record and stores the incoming Record under a fork key.
+	ConflictFork
+)
+
+// String returns the name of the resolution.
+func (resolution ConflictResolution) String() string {
+	switch resolution {
+	case ConflictReject:
+		return "reject"
+	case ConflictAccept:
+		return "accept"
+	case ConflictFork:
+		return "fork"
+	default:
+		return "unknown"
+	}
+}
+
+// Conflict describes a Record (or the deletion of a
+// Record) received from another database which could
+// not be applied because it failed the Set checks
+// against the existing Record.
+type Conflict struct {
+	Key         string               // the key the Record was received for
+	Reason      error                // the check which failed (ErrAttemptedOverwrite, ErrRecordHistory, ErrAttemptedUpdate or ErrDeleteConflict)
+	ExistingCID string               // the CID of the Record held in the database
+	IncomingCID string               // the CID of the received Record (or the deleted Record)
+	Existing    *Record              // the Record held in the database
+	Incoming    *Record              // the received Record (nil if the incoming change is a delete)
+	Delete      bool                 // true if the incoming change is a delete
+	Timestamp   *timestamp.Timestamp // when the incoming change was made
+}
+
+// ConflictEvent reports how a Conflict was handled.
+//
+// It satisfies the error interface, so rejected
+// Records are returned as a *ConflictEvent by
+// ApplyRecord.
+type ConflictEvent struct {
+	*Conflict
+	Resolution ConflictResolution // the action taken by the ConflictPolicy
+	StoredKey  string             // the key the incoming Record was stored under (empty if rejected)
+	StoredCID  string             // the CID the incoming Record was stored as (empty if rejected)
+}
+
+// Error returns a description of the conflict event.
+func (event *ConflictEvent) Error() string {
+	return event.String()
+}
+
+// String returns a description of the conflict event.
+func (event *ConflictEvent) String() string {
+	msg := fmt.Sprintf("conflict for %v (%v): %v->%v %v", event.Key, event.Reason, event.ExistingCID, event.IncomingCID, event.Resolution)
+	if len(event.StoredKey) != 0 {
+		msg = fmt.Sprintf("%v (stored as %v->%v)", msg, event.StoredKey, event.StoredCID)
+	}
+	return msg
+}
+
+// ConflictPolicy is used to decide how to handle a
+// Record received from another database which conflicts
+// with the Record held under the same key.
+//
+// The built-in policies are RejectAndReport (default),
+// LastWriterWins and KeepBothAsFork, but any function
+// with this signature can be passed to OpenDB using the
+// WithConflictPolicy option.
+type ConflictPolicy func(conflict *Conflict) ConflictResolution
+
+// RejectAndReport is a ConflictPolicy that rejects
+// every conflicting Record.
+func RejectAndReport(conflict *Conflict) ConflictResolution {
+	return ConflictReject
+}
+
+// LastWriterWins is a ConflictPolicy that accepts the
+// incoming change if it was made more recently than
+// the last update to the existing Record.
+func LastWriterWins(conflict *Conflict) ConflictResolution {
+	if starkhelpers.CheckTimeStamp(conflict.Existing.GetLastUpdatedTimestamp(), conflict.Timestamp) {
+		return ConflictAccept
+	}
+	return ConflictReject
+}
+
+// KeepBothAsFork is a ConflictPolicy that keeps the
+// existing Record and stores the incoming Record under
+// a fork key (see DefaultForkSeparator). For incoming
+// deletes, the existing Record is kept.
+func KeepBothAsFork(conflict *Conflict) ConflictResolution {
+	return ConflictFork
+}
+
+// ApplyRecord will add a Record received from another
+// database (e.g. via Listen) to the starkDB. The Record
+// must be as returned by Listen, so its PreviousCID
+// field holds the CID it was collected from.
+//
+// The Record is linked into the database by its CID,
+// under the key given by its Alias. It is checked in
+// the same way as Set, and if the checks fail the
+// ConflictPolicy of the database is used to resolve
+// the conflict. Every conflict is sent to the logger
+// as a *ConflictEvent, and rejected Records return
+// the *ConflictEvent as the error.
+//
+// It will return a response, which contains a copy of
+// the Record held under the key once the incoming
+// Record has been applied.
+func (starkdb *Db) ApplyRecord(ctx context.Context, record *Record) (*Response, error) {
+	return starkdb.applyRecord(ctx, record.GetAlias(), record)
+}
+
+// applyRecord is a helper method that applies a Record
+// received from another database under the provided key
+// (see ApplyRecord).
+func (starkdb *Db) applyRecord(ctx context.Context, key string, record *Record) (*Response, error) {
+	starkdb.Lock()
+	defer starkdb.Unlock()
+
+	// check the key and database
+	if len(key) == 0 {
+		return nil, ErrNoKey
+	}
+	if isReservedKey(key) {
+		return nil, ErrReservedKey
+	}
+	if starkdb.readOnly {
+		return nil, ErrReadOnly
+	}
+
+	// skip Records which are already held
+	incomingCID := record.GetPreviousCID()
+	existingCID, exists := starkdb.cidLookup[key]
+	if exists && existingCID == incomingCID {
+		return &Response{Success: true, Record: record}, nil
+	}
+
+	// skip Records which another database deleted before they arrived here
+	if deletedCID, ok := starkdb.pendingDeletes[key]; ok && deletedCID == incomingCID {
+		delete(starkdb.pendingDeletes, key)
+		return nil, ErrRecordDeleted
+	}
+
+	// get the Record as stored so the previousCID points to its parent, checking its author
+	incoming, err := starkdb.getIncomingRecord(incomingCID)
+	if err != nil {
+		return nil, err
+	}
+
+	// run the Set checks and link the Record if they pass
+	_, reason := starkdb.checkRecord(key, incoming)
+	switch reason {
+	case nil:
+		if err := starkdb.linkIncomingRecord(ctx, key, incomingCID); err != nil {
+			return nil, err
+		}
+		if !exists {
+			starkdb.currentNumEntries++
+		}
+		starkdb.send2log(fmt.Sprintf("record applied: %v->%v", key, incomingCID))
+		if err := starkdb.runSessionIntervals(1); err != nil {
+			return nil, err
+		}
+		return &Response{Success: true, Record: record}, nil
+	case ErrAttemptedOverwrite, ErrRecordHistory, ErrAttemptedUpdate:
+	default:
+		return nil, reason
+	}
+
+	// otherwise use the conflict policy
+	existing, err := starkdb.getRecordFromCID(existingCID)
+	if err != nil {
+		return nil, err
+	}
+	event := &ConflictEvent{
+		Conflict: &Conflict{
+			Key:         key,
+			Reason:      reason,
+			ExistingCID: existingCID,
+			IncomingCID: incomingCID,
+			Existing:    existing,
+			Incoming:    record,
+			Timestamp:   incoming.GetLastUpdatedTimestamp(),
+		},
+	}
+	event.Resolution = starkdb.conflictPolicy(event.Conflict)
+	switch event.Resolution {
+
+	// replace the existing Record, keeping it in the history
+	case ConflictAccept:
+		incoming.PreviousCID = existingCID
+		incoming.AddComment(fmt.Sprintf("Conflict: replaced version %v with %v (%v).", existingCID, incomingCID, event.Reason))
+		cid, err := starkdb.commitRecord(ctx, key, incoming)
+		if err != nil {
+			return nil, err
+		}
+		event.StoredKey, event.StoredCID = key, cid
+		starkdb.send2log(event)
+		if err := starkdb.runSessionIntervals(1); err != nil {
+			return nil, err
+		}
+		incoming.PreviousCID = cid
+		return &Response{Success: true, Record: incoming}, nil
+
+	// keep both Records
+	case ConflictFork:
+		forkKey := fmt.Sprintf("%v%v%v", key, DefaultForkSeparator, incomingCID)
+		if _, exists := starkdb.cidLookup[forkKey]; !exists {
+			if err := starkdb.linkIncomingRecord(ctx, forkKey, incomingCID); err != nil {
+				return nil, err
+			}
+			starkdb.currentNumEntries++
+			if err := starkdb.runSessionIntervals(1); err != nil {
+				return nil, err
+			}
+		}
+		event.StoredKey, event.StoredCID = forkKey, incomingCID
+		starkdb.send2log(event)
+		return &Response{Success: true, Record: record}, nil
+
+	// otherwise reject and report
+	default:
+		starkdb.send2log(event)
+		return nil, event
+	}
+}
@@ -0,0 +1,177 @@
+package stark
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gogo/protobuf/jsonpb"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// DiffSnapshots will compare two project snapshots and
+// return the keys which were added, removed or changed
+// (linked to a different Record CID) between snapshot A
+// and snapshot B.
+//
+// If fields is true, the Records for each changed key
+// are collected from the IPFS and a field-level diff is
+// added to the report.
+func (starkdb *Db) DiffSnapshots(snapshotA, snapshotB string, fields bool) (*SnapshotDiff, error) {
+	if len(snapshotA) == 0 || len(snapshotB) == 0 {
+		return nil, ErrNoCID
+	}
+
+	// get the key->CID pairs for each snapshot
+	pairsA, err := starkdb.getSnapshotPairs(snapshotA)
+	if err != nil {
+		return nil, err
+	}
+	pairsB, err := starkdb.getSnapshotPairs(snapshotB)
+	if err != nil {
+		return nil, err
+	}
+
+	// compare the keys
+	diff := &SnapshotDiff{
+		SnapshotA: snapshotA,
+		SnapshotB: snapshotB,
+	}
+	for key, cidA := range pairsA {
+		cidB, ok := pairsB[key]
+		if !ok {
+			diff.Removed = append(diff.Removed, key)
+			continue
+		}
+		if cidA == cidB {
+			continue
+		}
+		keyDiff := &KeyDiff{
+			Key:  key,
+			CidA: cidA,
+			CidB: cidB,
+		}
+		if fields {
+			keyDiff.Fields, err = starkdb.diffRecords(cidA, cidB)
+			if err != nil {
+				return nil, err
+			}
+		}
+		diff.Changed = append(diff.Changed, keyDiff)
+	}
+	for key := range pairsB {
+		if _, ok := pairsA[key]; !ok {
+			diff.Added = append(diff.Added, key)
+		}
+	}
+
+	// sort the report so it is stable
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Slice(diff.Changed, func(i, j int) bool {
+		return diff.Changed[i].GetKey() < diff.Changed[j].GetKey()
+	})
+	starkdb.send2log(fmt.Sprintf("snapshots compared: %v->%v (%d added, %d removed, %d changed)", snapshotA, snapshotB, len(diff.Added), len(diff.Removed), len(diff.Changed)))
+	return diff, nil
+}
+
+// getSnapshotPairs is a helper method that returns
+// the key->CID pairs for the Records linked to a
+// snapshot.
+func (starkdb *Db) getSnapshotPairs(snapshotCID string) (map[string]string, error) {
+	pairs := make(map[string]string)
+	if err := starkdb.forEachSnapshotLink(starkdb.ctx, snapshotCID, func(link *ipld.Link) error {
+		if !isReservedKey(link.Name) {
+			pairs[link.Name] = link.Cid.String()
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("%v: %w", ErrInvalidSnapshot, err)
+	}
+	return pairs, nil
+}
+
+// diffRecords is a helper method that collects two
+// Records from the IPFS and returns the fields which
+// differ between them.
+func (starkdb *Db) diffRecords(cidA, cidB string) ([]*FieldDiff, error) {
+	fieldsA, err := starkdb.flattenRecord(cidA)
+	if err != nil {
+		return nil, err
+	}
+	fieldsB, err := starkdb.flattenRecord(cidB)
+	if err != nil {
+		return nil, err
+	}
+	diffs := []*FieldDiff{}
+	for field, valueA := range fieldsA {
+		if valueB := fieldsB[field]; valueA != valueB {
+			diffs = append(diffs, &FieldDiff{Field: field, ValueA: valueA, ValueB: valueB})
+		}
+	}
+	for field, valueB := range fieldsB {
+		if _, ok := fieldsA[field]; !ok {
+			diffs = append(diffs, &FieldDiff{Field: field, ValueB: valueB})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].GetField() < diffs[j].GetField()
+	})
+	return diffs, nil
+}
+
+// flattenRecord is a helper method that collects a
+// Record from the IPFS and flattens it to a map of
+// field paths to JSON encoded values.
+func (starkdb *Db) flattenRecord(cid string) (map[string]string, error) {
+	record, err := starkdb.getStoredRecord(cid)
+	if err != nil {
+		return nil, err
+	}
+	m := &jsonpb.Marshaler{}
+	data, err := m.MarshalToString(record)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(data), &decoded); err != nil {
+		return nil, err
+	}
+	fields := make(map[string]string)
+	if err := flattenJSON("", decoded, fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// flattenJSON will recursively add the leaf values
+// of decoded JSON to the fields map, using the path
+// to each value as the key.
+func flattenJSON(path string, value interface{}, fields map[string]string) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			childPath := key
+			if len(path) != 0 {
+				childPath = strings.Join([]string{path, key}, ".")
+			}
+			if err := flattenJSON(childPath, child, fields); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			if err := flattenJSON(fmt.Sprintf("%s[%d]", path, i), child, fields); err != nil {
+				return err
+			}
+		}
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		fields[path] = string(encoded)
+	}
+	return nil
+}
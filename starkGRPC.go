@@ -2,12 +2,11 @@ package stark
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 
-	"github.com/pkg/errors"
-	starkhelpers "github.com/will-rowe/stark/src/helpers"
 	codes "google.golang.org/grpc/codes"
 	status "google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
@@ -16,6 +15,10 @@ import (
 // Get will retrieve a copy of a Record
 // from the starkDB using the provided
 // lookup key.
+//
+// The signature of the Record is checked
+// and the Author field of the returned
+// Record is the verified author.
 func (starkdb *Db) Get(ctx context.Context, key *Key) (*Response, error) {
 	starkdb.Lock()
 	defer starkdb.Unlock()
@@ -26,8 +29,8 @@ func (starkdb *Db) Get(ctx context.Context, key *Key) (*Response, error) {
 		return nil, status.Error(codes.NotFound, fmt.Sprintf("no Record in database for key: %v", key.GetKey()))
 	}
 
-	// use the helper method to retrieve the Record
-	record, err := starkdb.getRecordFromCID(cid)
+	// use the helper method to retrieve and verify the Record
+	record, err := starkdb.getVerifiedRecordFromCID(cid)
 	if err != nil {
 		return nil, err
 	}
@@ -60,104 +63,357 @@ func (starkdb *Db) Set(ctx context.Context, krp *KeyRecordPair) (*Response, erro
 	if len(key) == 0 {
 		return nil, ErrNoKey
 	}
+	if isReservedKey(key) {
+		return nil, ErrReservedKey
+	}
+
+	// check the database can be written to
+	if starkdb.readOnly {
+		return nil, ErrReadOnly
+	}
 
 	// check the local keystore to see if this key has been used before
-	if existingCID, exists := starkdb.cidLookup[key]; exists {
+	update, err := starkdb.checkRecord(key, record)
+	if err != nil {
+		return nil, err
+	}
+	if update {
+		record.AddComment("Set: updating record.")
+	}
+	record.AddComment("Set: adding record to IPFS.")
 
-		// retrieve the record for this key
-		existingRecord, err := starkdb.getRecordFromCID(existingCID)
+	// add the Record to the IPFS and update the snapshot
+	cid, err := starkdb.commitRecord(ctx, key, record)
+	if err != nil {
+		return nil, err
+	}
+	starkdb.currentNumEntries++
+
+	// job done
+	starkdb.send2log(fmt.Sprintf("record added: %v->%v", key, cid))
+
+	// run any Pinata or IPNS uploads which are due
+	if err := starkdb.runSessionIntervals(1); err != nil {
+		return nil, err
+	}
+
+	// add the CID to the record and return
+	record.PreviousCID = cid
+	return &Response{Success: true, Record: record}, nil
+}
+
+// BatchSet will receive a stream of KeyRecordPairs and,
+// once the client has finished sending, add them to the
+// starkDB in a single operation using SetBatch.
+func (starkdb *Db) BatchSet(stream StarkDb_BatchSetServer) error {
+	pairs := []*KeyRecordPair{}
+	for {
+		krp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			return nil, err
+			return err
 		}
-
-		// check UUIDs
-		if existingRecord.GetUuid() != record.GetUuid() {
-			return nil, ErrAttemptedOverwrite
+		pairs = append(pairs, krp)
+	}
+	response, err := starkdb.SetBatch(stream.Context(), pairs)
+	if err != nil {
+		var batchErr *BatchError
+		switch {
+		case errors.As(err, &batchErr), err == ErrEmptyBatch:
+			return status.Error(codes.InvalidArgument, err.Error())
+		case err == ErrReadOnly:
+			return status.Error(codes.FailedPrecondition, err.Error())
+		default:
+			return status.Error(codes.Internal, err.Error())
 		}
+	}
+	return stream.SendAndClose(response)
+}
 
-		// if the existingCID in the local keystore does not match the previousCID of the incoming Record it is an attempted overwrite
-		if existingCID != record.GetPreviousCID() {
-			return nil, ErrRecordHistory
-		}
+// Delete will delete an entry from starkdb. This involves
+// removing the key and Record CID from the local store,
+// as well as unpinning the Record from the IPFS.
+//
+// It will return a response, which contains the CID of
+// the removed Record and the updated database snapshot.
+//
+// Note: Records which were never pinned (e.g. when the
+// database was opened WithNoPinning) are still removed
+// from the database, the missing pin is ignored.
+func (starkdb *Db) Delete(ctx context.Context, key *Key) (*DeleteResponse, error) {
+	starkdb.Lock()
+	defer starkdb.Unlock()
 
-		// otherwise this is an attempted update, check that the incoming Record is more recent
-		if !starkhelpers.CheckTimeStamp(existingRecord.GetLastUpdatedTimestamp(), record.GetLastUpdatedTimestamp()) {
-			return nil, ErrAttemptedUpdate
-		}
-		record.AddComment("Set: updating record.")
+	// check the key
+	if len(key.GetKey()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, ErrNoKey.Error())
+	}
 
+	// check the database can be written to
+	if starkdb.readOnly {
+		return nil, status.Error(codes.FailedPrecondition, ErrReadOnly.Error())
 	}
-	record.AddComment("Set: adding record to IPFS.")
 
-	// if encrypting requested and Record isn't already, do it now
-	if len(starkdb.cipherKey) != 0 && !record.GetEncrypted() {
-		if err := record.Encrypt(starkdb.cipherKey); err != nil {
-			return nil, err
+	// check the local keystore for the provided key
+	cid, ok := starkdb.cidLookup[key.GetKey()]
+	if !ok {
+		return nil, status.Error(codes.NotFound, fmt.Sprintf("no Record in database for key: %v", key.GetKey()))
+	}
+
+	// unlink the record CID from the project directory, update the snapshot and unpin
+	if err := starkdb.removeRecord(ctx, key.GetKey(), cid); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	// TODO: if using a pinning service - will need to request an unpin there
+
+	// if announcing, do it now (the Record is already removed so failures are only logged)
+	if starkdb.announcing {
+		if err := starkdb.publishAnnouncement(starkdb.newAnnouncement(Announcement_DELETE, key.GetKey(), cid, nil)); err != nil {
+			starkdb.send2log(fmt.Sprintf("could not announce the delete of %v: %v", key.GetKey(), err))
 		}
 	}
+	starkdb.send2log(fmt.Sprintf("record deleted: %v->%v", key.GetKey(), cid))
 
-	// marshal Record data to JSON
-	jsonData, err := json.Marshal(record)
-	if err != nil {
-		return nil, err
+	// run any Pinata or IPNS uploads which are due
+	if err := starkdb.runSessionIntervals(1); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
 	}
+	return &DeleteResponse{Success: true, RemovedCID: cid, SnapshotCID: starkdb.snapshotCID}, nil
+}
 
-	// create DAG node in IPFS for Record data
-	cid, err := starkdb.ipfsClient.DagPut(ctx, jsonData, starkdb.pinning)
-	if err != nil {
-		return nil, err
+// Rollback will restore a previous version of a Record
+// as the current entry for the provided key.
+//
+// The version to restore is found by following the
+// previousCID links back through the Record's history,
+// either until the target CID is reached or until the
+// requested number of steps have been taken.
+//
+// The restored Record is added to the IPFS as a new
+// version, so the rollback itself is recorded in the
+// Record history and can be undone.
+//
+// Note: restoring a version encrypted before the
+// password was changed (see RotateKey) needs the old
+// password, unless the key was rotated during this
+// session.
+//
+// It will return a response, which contains a copy of
+// the restored Record (with its new CID).
+func (starkdb *Db) Rollback(ctx context.Context, req *RollbackRequest) (*Response, error) {
+	starkdb.Lock()
+	defer starkdb.Unlock()
+
+	// check the request
+	key := req.GetKey()
+	if len(key) == 0 {
+		return nil, status.Error(codes.InvalidArgument, ErrNoKey.Error())
+	}
+	if len(req.GetTargetCID()) == 0 && req.GetSteps() < 1 {
+		return nil, status.Error(codes.InvalidArgument, "rollback requires a target CID or a number of steps")
+	}
+	if starkdb.readOnly {
+		return nil, status.Error(codes.FailedPrecondition, ErrReadOnly.Error())
+	}
+
+	// check the local keystore for the provided key
+	currentCID, ok := starkdb.cidLookup[key]
+	if !ok {
+		return nil, status.Error(codes.NotFound, fmt.Sprintf("no Record in database for key: %v", key))
 	}
 
-	// link the record CID to the project directory and take a snapshot
-	snapshotUpdate, err := starkdb.ipfsClient.AddLink(ctx, starkdb.snapshotCID, cid, key)
+	// walk back through the Record versions until the target is reached
+	targetCID := currentCID
+	for steps := int32(0); ; steps++ {
+		if len(req.GetTargetCID()) != 0 && targetCID == req.GetTargetCID() {
+			break
+		}
+		if len(req.GetTargetCID()) == 0 && steps == req.GetSteps() {
+			break
+		}
+		version, err := starkdb.getEncryptedRecord(targetCID)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		if len(version.GetPreviousCID()) == 0 {
+			return nil, status.Error(codes.OutOfRange, ErrRollbackTarget.Error())
+		}
+		targetCID = version.GetPreviousCID()
+	}
+	if targetCID == currentCID {
+		return nil, status.Error(codes.InvalidArgument, "rollback target is the current Record version")
+	}
+
+	// collect the target version and make it the child of the current version
+	record, err := starkdb.getStoredRecord(targetCID)
 	if err != nil {
-		return nil, errors.Wrap(err, ErrSnapshotUpdate.Error())
+		return nil, status.Error(codes.Internal, err.Error())
 	}
-	starkdb.snapshotCID = snapshotUpdate
+	record.PreviousCID = currentCID
+	record.AddComment(fmt.Sprintf("Rollback: restored version %v.", targetCID))
 
-	// if announcing, do it now
-	if starkdb.announcing {
+	// add the restored Record to the IPFS and update the snapshot
+	cid, err := starkdb.commitRecord(ctx, key, record)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	starkdb.send2log(fmt.Sprintf("record rolled back: %v->%v (restored %v)", key, cid, targetCID))
 
-		// TODO: send proto data instead of CID
-		if err := starkdb.publishAnnouncement([]byte(cid)); err != nil {
-			return nil, err
-		}
+	// run any Pinata or IPNS uploads which are due
+	if err := starkdb.runSessionIntervals(1); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
 	}
 
-	// add the returned CID to the local keystore
-	starkdb.cidLookup[key] = cid
-	starkdb.currentNumEntries++
-	starkdb.sessionEntries++
+	// add the CID to the record and return
+	record.PreviousCID = cid
+	return &Response{Success: true, Record: record}, nil
+}
 
-	// job done
-	starkdb.send2log(fmt.Sprintf("record added: %v->%v", key, cid))
+// runSessionIntervals is a helper method that adds the
+// last numAdded Record writes (sets, deletes, rollbacks,
+// applied and merged Records and re-encryptions) to the
+// session and runs the Pinata and IPNS uploads if they
+// took the session past one of their intervals.
+//
+// Note: the caller must hold the database lock.
+func (starkdb *Db) runSessionIntervals(numAdded int) error {
+	starkdb.sessionEntries += numAdded
+	intervalReached := func(interval int) bool {
+		return interval > 0 && starkdb.sessionEntries/interval > (starkdb.sessionEntries-numAdded)/interval
+	}
 
 	// use pinata if session interval reached
-	if starkdb.pinataInterval > 0 {
-		if starkdb.sessionEntries%starkdb.pinataInterval == 0 {
-			starkdb.send2log("pinning interval reached, uploading database to Pinata")
-			var k, s string
-			var ok1, ok2 bool
-			if k, ok1 = os.LookupEnv(DefaultPinataAPIkey); !ok1 {
-				return nil, ErrPinataKey
+	if intervalReached(starkdb.pinataInterval) {
+		starkdb.send2log("pinning interval reached, uploading database to Pinata")
+		var k, s string
+		var ok1, ok2 bool
+		if k, ok1 = os.LookupEnv(DefaultPinataAPIkey); !ok1 {
+			return ErrPinataKey
+		}
+		if s, ok2 = os.LookupEnv(DefaultPinataSecretKey); !ok2 {
+			return ErrPinataSecret
+		}
+		go func() {
+			pinataResp, err := starkdb.PinataPublish(k, s)
+			if err != nil {
+				starkdb.send2log(fmt.Sprintf("pinata error: %v", err))
+				return
 			}
-			if s, ok2 = os.LookupEnv(DefaultPinataSecretKey); !ok2 {
-				return nil, ErrPinataSecret
+			starkdb.send2log(fmt.Sprintf("pinata API response: %v", pinataResp.Status))
+		}()
+	}
+
+	// publish the snapshot to IPNS if session interval reached
+	if intervalReached(starkdb.ipnsInterval) {
+		starkdb.send2log("IPNS interval reached, publishing database snapshot")
+		starkdb.ipnsPublishing.Add(1)
+		go func() {
+			defer starkdb.ipnsPublishing.Done()
+			ctx, cancel := context.WithTimeout(starkdb.ctx, DefaultIPNSTimeout)
+			defer cancel()
+			if _, err := starkdb.PublishIPNS(ctx); err != nil {
+				starkdb.send2log(fmt.Sprintf("IPNS error: %v", err))
 			}
-			go func() {
-				pinataResp, err := starkdb.PinataPublish(k, s)
-				if err != nil {
-					starkdb.send2log(fmt.Sprintf("pinata error: %v", err))
-					return
-				}
-				starkdb.send2log(fmt.Sprintf("pinata API response: %v", pinataResp.Status))
-			}()
+		}()
+	}
+	return nil
+}
+
+// History will stream every stored version of a Record
+// from the starkDB using the provided lookup key. The
+// current version is sent first, followed by each of
+// the previous versions in turn.
+func (starkdb *Db) History(key *Key, stream StarkDb_HistoryServer) error {
+	if len(key.GetKey()) == 0 {
+		return status.Error(codes.InvalidArgument, ErrNoKey.Error())
+	}
+	starkdb.Lock()
+	_, ok := starkdb.cidLookup[key.GetKey()]
+	starkdb.Unlock()
+	if !ok {
+		return status.Error(codes.NotFound, fmt.Sprintf("no Record in database for key: %v", key.GetKey()))
+	}
+
+	// use the helper method to walk the Record versions
+	versions, err := starkdb.GetHistory(key.GetKey())
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	for _, version := range versions {
+		if err := stream.Send(version); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	// add the CID to the record and return
-	record.PreviousCID = cid
-	return &Response{Success: true, Record: record}, nil
+// Diff will compare two project snapshots and return
+// the keys which were added, removed or changed between
+// them, along with an optional field-level diff of the
+// changed Records.
+func (starkdb *Db) Diff(ctx context.Context, req *DiffRequest) (*SnapshotDiff, error) {
+	if len(req.GetSnapshotA()) == 0 || len(req.GetSnapshotB()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, ErrNoCID.Error())
+	}
+	diff, err := starkdb.DiffSnapshots(req.GetSnapshotA(), req.GetSnapshotB(), req.GetFields())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return diff, nil
+}
+
+// Merge will perform a three-way merge of a snapshot
+// head into the database (see MergeSnapshots). If no
+// head A is given, the current database snapshot is
+// used.
+//
+// If all conflicting keys were resolved, the merged
+// snapshot becomes the current database snapshot,
+// unless a dry run was requested. It will return a
+// response, which contains the merged snapshot CID
+// and any unresolved conflicts.
+func (starkdb *Db) Merge(ctx context.Context, req *MergeRequest) (*MergeResponse, error) {
+	starkdb.Lock()
+	defer starkdb.Unlock()
+
+	// check the request
+	if len(req.GetHeadB()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, ErrNoCID.Error())
+	}
+	headA := req.GetHeadA()
+	if len(headA) == 0 {
+		headA = starkdb.snapshotCID
+	}
+	if !req.GetDryRun() {
+		if starkdb.readOnly {
+			return nil, status.Error(codes.FailedPrecondition, ErrReadOnly.Error())
+		}
+		if headA != starkdb.snapshotCID {
+			return nil, status.Error(codes.InvalidArgument, "head A must be the current database snapshot unless a dry run is requested")
+		}
+	}
+
+	// merge the snapshots
+	resp, err := starkdb.MergeSnapshots(req.GetAncestor(), headA, req.GetHeadB())
+	if err != nil {
+		if err == ErrNoCommonAncestor {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if req.GetDryRun() || !resp.GetSuccess() {
+		return resp, nil
+	}
+
+	// update the database to the merged snapshot
+	if err := starkdb.applyMerge(resp.GetSnapshotCID(), req.GetHeadB()); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	starkdb.send2log(fmt.Sprintf("database updated to merged snapshot: %v", starkdb.snapshotCID))
+	return resp, nil
 }
 
 // Dump returns the metadata from a starkDB instance.
@@ -166,13 +422,20 @@ func (starkdb *Db) Set(ctx context.Context, krp *KeyRecordPair) (*Response, erro
 func (starkdb *Db) Dump(ctx context.Context, empty *emptypb.Empty) (*DbMeta, error) {
 	starkdb.Lock()
 	defer starkdb.Unlock()
-	nodeAdd, err := starkdb.GetNodeAddr()
-	if err != nil {
-		return nil, err
-	}
-	peerAdds, err := starkdb.GetConnectedPeers()
-	if err != nil {
-		return nil, err
+
+	// only collect network info if the node is online
+	var nodeAdd string
+	var peerAdds []string
+	if starkdb.isOnline() {
+		var err error
+		nodeAdd, err = starkdb.GetNodeAddr()
+		if err != nil {
+			return nil, err
+		}
+		peerAdds, err = starkdb.GetConnectedPeers()
+		if err != nil {
+			return nil, err
+		}
 	}
 	starkdb.send2log("database dumped")
 	return &DbMeta{
@@ -184,5 +447,6 @@ func (starkdb *Db) Dump(ctx context.Context, empty *emptypb.Empty) (*DbMeta, err
 		Announcing:  starkdb.announcing,
 		CurrEntries: int32(starkdb.currentNumEntries),
 		Pairs:       starkdb.cidLookup,
+		IPNSName:    starkdb.ipnsName,
 	}, nil
 }
@@ -2,12 +2,18 @@ package stark
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 
 	"github.com/gogo/protobuf/jsonpb"
+	"github.com/golang/protobuf/ptypes"
 	cbor "github.com/ipfs/go-ipld-cbor"
 	"github.com/pkg/errors"
+	starkhelpers "github.com/will-rowe/stark/src/helpers"
+	starkipfs "github.com/will-rowe/stark/src/ipfs"
 	starkpinata "github.com/will-rowe/stark/src/pinata"
+	"google.golang.org/protobuf/proto"
 )
 
 // GetSnapshot returns the current database snapshot
@@ -41,6 +47,61 @@ func (starkdb *Db) GetCIDs() map[string]string {
 	return starkdb.cidLookup
 }
 
+// GetHistory will return every stored version of the
+// Record for the provided key, starting with the current
+// version and following the previousCID links back to
+// the first version.
+//
+// Each Record is returned as it was stored, so the
+// PreviousCID field of each Record points to the
+// version which follows it in the returned slice.
+//
+// Versions which can't be decrypted (e.g. versions
+// encrypted before RotateKey changed the password) are
+// returned encrypted, with the Encrypted field set.
+func (starkdb *Db) GetHistory(key string) ([]*RecordVersion, error) {
+	if len(key) == 0 {
+		return nil, ErrNoKey
+	}
+
+	// check the local keystore for the provided key
+	starkdb.Lock()
+	cid, ok := starkdb.cidLookup[key]
+	starkdb.Unlock()
+	if !ok {
+		return nil, ErrNotFound(key)
+	}
+
+	// walk back through the Record versions
+	versions := []*RecordVersion{}
+	for len(cid) != 0 {
+		if err := starkdb.ctx.Err(); err != nil {
+			return nil, err
+		}
+		record, err := starkdb.getEncryptedRecord(cid)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not retrieve version %v", cid)
+		}
+		if record.GetEncrypted() {
+			decrypted := proto.Clone(record).(*Record)
+			if err := starkdb.decryptRecord(decrypted); err != nil {
+				starkdb.send2log(fmt.Sprintf("could not decrypt version %v of %v: %v", cid, key, err))
+			} else {
+				record = decrypted
+			}
+		}
+		versions = append(versions, &RecordVersion{
+			Cid:     cid,
+			Created: record.GetCreatedTimestamp(),
+			Updated: record.GetLastUpdatedTimestamp(),
+			Record:  record,
+		})
+		cid = record.GetPreviousCID()
+	}
+	starkdb.send2log(fmt.Sprintf("record history retrieved: %v (%d versions)", key, len(versions)))
+	return versions, nil
+}
+
 // GetNodeAddr returns the public address of the
 // underlying IPFS node for the starkDB
 // instance.
@@ -48,8 +109,8 @@ func (starkdb *Db) GetNodeAddr() (string, error) {
 	if !starkdb.isOnline() {
 		return "", ErrNodeOffline
 	}
-	nodeID := starkdb.ipfsClient.PrintNodeID()
-	add, err := starkdb.ipfsClient.GetPublicIPv4Addr()
+	nodeID := starkdb.backend.PrintNodeID()
+	add, err := starkdb.backend.GetPublicIPv4Addr()
 	if err != nil {
 		return "", err
 	}
@@ -62,7 +123,7 @@ func (starkdb *Db) GetConnectedPeers() ([]string, error) {
 	if !starkdb.isOnline() {
 		return nil, ErrNodeOffline
 	}
-	connectPeers, err := starkdb.ipfsClient.GetPeers(starkdb.ctx)
+	connectPeers, err := starkdb.backend.GetPeers(starkdb.ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -91,19 +152,19 @@ func (starkdb *Db) PinataPublish(apiKey, apiSecret string) (*starkpinata.APIResp
 
 // Listen will start a subscription to the IPFS PubSub network
 // for messages matching the current database's project. It
-// tries pulling Records from the IPFS via the announced
-// CIDs, then returns them via a channel to the caller.
+// collects the Records from the announcements of Records being
+// set (from the Announcement if included, otherwise from the
+// IPFS via the announced CID) and returns them via a channel
+// to the caller. The signature and author of each Record are
+// checked (see verifyAuthor) and Records which fail are reported
+// via the Error channel instead.
 //
 // It returns the Record channel, an Error channel which reports
 // errors during message processing and Record retrieval, as well
 // as any error during the PubSub setup.
 func (starkdb *Db) Listen(terminator chan struct{}) (chan *Record, chan error, error) {
-	if !starkdb.isOnline() {
-		return nil, nil, ErrNodeOffline
-	}
-
-	// subscribe the node to the starkDB project
-	if err := starkdb.ipfsClient.Subscribe(starkdb.ctx, starkdb.project); err != nil {
+	anns, annErrs, err := starkdb.ListenAnnouncements(terminator)
+	if err != nil {
 		return nil, nil, err
 	}
 
@@ -114,85 +175,107 @@ func (starkdb *Db) Listen(terminator chan struct{}) (chan *Record, chan error, e
 	recChan := make(chan *Record, DefaultBufferSize)
 	errChan := make(chan error)
 
-	// process the incoming messages
+	// process the announcements until the terminator closes the channels
 	go func() {
-		for {
+		defer close(recChan)
+		defer close(errChan)
+		for anns != nil || annErrs != nil {
 			select {
-			case msg := <-starkdb.ipfsClient.GetPSMchan():
+			case ann, ok := <-anns:
+				if !ok {
+					anns = nil
+					continue
+				}
 
-				// TODO: check sender peerID
-				//msg.From()
+				// only Record announcements are returned
+				if ann.GetType() != Announcement_SET {
+					continue
+				}
 
 				// get the CID
-				cid := string(msg.Data())
+				cid := ann.GetCid()
 				if _, ok := cidTracker[cid]; ok {
 					continue
 				}
 				cidTracker[cid] = struct{}{}
 
-				// collect the Record from IPFS
-				collectedRecord, err := starkdb.getRecordFromCID(cid)
+				// collect the Record
+				collectedRecord, err := starkdb.getAnnouncedRecord(ann)
 				if err != nil {
 					errChan <- err
-				} else {
-
-					// add a comment to say this Record was from PubSub
-					collectedRecord.AddComment(fmt.Sprintf("collected from %s via pubsub.", msg.From()))
-
-					// send the record on to the caller
-					recChan <- collectedRecord
+					continue
 				}
 
-			case err := <-starkdb.ipfsClient.GetPSEchan():
-				errChan <- err
+				// add a comment to say this Record was from PubSub
+				collectedRecord.AddComment(fmt.Sprintf("collected from %s via pubsub.", ann.GetSender()))
 
-			case <-terminator:
-				if err := starkdb.ipfsClient.Unsubscribe(); err != nil {
-					errChan <- err
+				// send the record on to the caller
+				recChan <- collectedRecord
+
+			case err, ok := <-annErrs:
+				if !ok {
+					annErrs = nil
+					continue
 				}
-				close(recChan)
-				close(errChan)
-				return
+				errChan <- err
 			}
 		}
 	}()
 	return recChan, errChan, nil
 }
 
-// Delete will delete an entry from starkdb. This involves
-// removing the key and Record CID from the local store,
-// as well as unpinning the Record from the IPFS.
+// ListenAnnouncements will start a subscription to the IPFS
+// PubSub network for messages matching the current database's
+// project. It decodes each Announcement, checks the schema
+// version and sender, then returns them via a channel to the
+// caller.
 //
-// Note: I'm not sure how this behaves if the Record
-// wasn't pinned in the IPFS in the first place.
-func (starkdb *Db) Delete(key string) error {
-	starkdb.Lock()
-	defer starkdb.Unlock()
-
-	// check the local keystore for the provided key
-	cid, ok := starkdb.cidLookup[key]
-	if !ok {
-		return ErrNotFound(key)
+// It returns the Announcement channel, an Error channel which
+// reports errors during message processing, as well as any
+// error during the PubSub setup. Both channels are closed
+// once the terminator is closed.
+func (starkdb *Db) ListenAnnouncements(terminator chan struct{}) (chan *Announcement, chan error, error) {
+	if !starkdb.isOnline() {
+		return nil, nil, ErrNodeOffline
 	}
 
-	// unlink the record CID from the project directory and update a snapshot
-	snapshotUpdate, err := starkdb.ipfsClient.RmLink(starkdb.ctx, starkdb.snapshotCID, key)
-	if err != nil {
-		return errors.Wrap(err, ErrSnapshotUpdate.Error())
+	// subscribe the node to the starkDB project
+	if err := starkdb.backend.Subscribe(starkdb.ctx, starkdb.project); err != nil {
+		return nil, nil, err
 	}
-	starkdb.snapshotCID = snapshotUpdate
 
-	// unpin the file
-	if err := starkdb.ipfsClient.Unpin(starkdb.ctx, cid); err != nil {
-		return err
-	}
+	// create channels used to send Announcements and errors back to the caller
+	annChan := make(chan *Announcement, DefaultBufferSize)
+	errChan := make(chan error)
 
-	// TODO: if using a pinning service - will need to request an unpin there
+	// process the incoming messages
+	go func() {
+		for {
+			select {
+			case msg := <-starkdb.backend.GetPSMchan():
 
-	// remove from the keystore
-	delete(starkdb.cidLookup, key)
-	starkdb.currentNumEntries--
-	return nil
+				// decode the announcement
+				ann, err := readAnnouncement(msg.Data(), msg.From().String())
+				if err != nil {
+					errChan <- err
+					continue
+				}
+				annChan <- ann
+
+			case err := <-starkdb.backend.GetPSEchan():
+				errChan <- err
+
+			case <-terminator:
+				if err := starkdb.backend.Unsubscribe(); err != nil {
+					errChan <- err
+				}
+				close(annChan)
+				close(errChan)
+				return
+			}
+		}
+	}()
+	return annChan, errChan, nil
 }
 
 // GetVersion returns the full version string
@@ -209,14 +292,119 @@ func GetBaseVersion() string {
 }
 
 // getRecordFromCID is a helper method that collects a Record from
-// the IPFS using its CID string.
+// the IPFS using its CID string. The PreviousCID field of the
+// returned Record is set to the provided CID.
 func (starkdb *Db) getRecordFromCID(cid string) (*Record, error) {
+	record, err := starkdb.getStoredRecord(cid)
+	if err != nil {
+		return nil, err
+	}
+
+	// add the pulled CID to this record
+	record.PreviousCID = cid
+	return record, nil
+}
+
+// getVerifiedRecordFromCID is a helper method that collects a
+// Record in the same way as getRecordFromCID, but checks the
+// signature and author of the Record first (see verifyAuthor).
+func (starkdb *Db) getVerifiedRecordFromCID(cid string) (*Record, error) {
+	record, err := starkdb.getIncomingRecord(cid)
+	if err != nil {
+		return nil, err
+	}
+
+	// add the pulled CID to this record
+	record.PreviousCID = cid
+	return record, nil
+}
+
+// checkRecord is a helper method that checks an incoming Record
+// can be added to the database under the provided key. The
+// PreviousCID field of the incoming Record must be the CID of
+// the version it was updated from.
+//
+// It returns true if the incoming Record is an update of the
+// Record currently held under the key.
+func (starkdb *Db) checkRecord(key string, record *Record) (bool, error) {
+	existingCID, exists := starkdb.cidLookup[key]
+	if !exists {
+		return false, nil
+	}
+
+	// retrieve the record for this key
+	existingRecord, err := starkdb.getRecordFromCID(existingCID)
+	if err != nil {
+		return false, err
+	}
+
+	// check UUIDs
+	if existingRecord.GetUuid() != record.GetUuid() {
+		return false, ErrAttemptedOverwrite
+	}
+
+	// if the existingCID in the local keystore does not match the previousCID of the incoming Record it is an attempted overwrite
+	if existingCID != record.GetPreviousCID() {
+		return false, ErrRecordHistory
+	}
+
+	// otherwise this is an attempted update, check that the incoming Record is more recent
+	if !starkhelpers.CheckTimeStamp(existingRecord.GetLastUpdatedTimestamp(), record.GetLastUpdatedTimestamp()) {
+		return false, ErrAttemptedUpdate
+	}
+	return true, nil
+}
+
+// getStoredRecord is a helper method that collects a Record from
+// the IPFS using its CID string. The Record is returned as it was
+// stored, so the PreviousCID field points to the parent version
+// of the Record (or is empty if this is the first version).
+func (starkdb *Db) getStoredRecord(cid string) (*Record, error) {
+	record, err := starkdb.getEncryptedRecord(cid)
+	if err != nil {
+		return nil, err
+	}
+
+	// if it's an encrypted Record, see if we can decrypt
+	if record.GetEncrypted() {
+		if err := starkdb.decryptRecord(record); err != nil {
+			return nil, errors.Wrap(err, ErrEncrypted.Error())
+		}
+	}
+	return record, nil
+}
+
+// getIncomingRecord is a helper method that collects a
+// Record from another database in the same way as
+// getStoredRecord, but checks the signature and author
+// of the Record (see verifyAuthor) before decrypting it,
+// so the Author field holds the verified author.
+func (starkdb *Db) getIncomingRecord(cid string) (*Record, error) {
+	record, err := starkdb.getEncryptedRecord(cid)
+	if err != nil {
+		return nil, err
+	}
+	if err := starkdb.verifyAuthor(record); err != nil {
+		return nil, err
+	}
+	if record.GetEncrypted() {
+		if err := starkdb.decryptRecord(record); err != nil {
+			return nil, errors.Wrap(err, ErrEncrypted.Error())
+		}
+	}
+	return record, nil
+}
+
+// getEncryptedRecord is a helper method that collects a
+// Record from the IPFS in the same way as getStoredRecord,
+// but does not decrypt it.
+func (starkdb *Db) getEncryptedRecord(cid string) (*Record, error) {
 	if len(cid) == 0 {
 		return nil, ErrNoCID
 	}
 
 	// retrieve the record data from the IPFS
-	retrievedNode, err := starkdb.ipfsClient.DagGet(starkdb.ctx, cid)
+	retrievedNode, err := starkdb.backend.DagGet(starkdb.ctx, cid)
 	if err != nil {
 		return nil, err
 	}
@@ -239,36 +427,217 @@ func (starkdb *Db) getRecordFromCID(cid string) (*Record, error) {
 	if err := um.Unmarshal(bytes.NewReader(data), record); err != nil {
 		return nil, err
 	}
+	return record, nil
+}
 
-	// if it's an encrypted Record, see if we can decrypt
-	if record.GetEncrypted() {
-		if err := record.Decrypt(starkdb.cipherKey); err != nil {
-			return nil, errors.Wrap(err, ErrEncrypted.Error())
+// commitRecord is a helper method that adds a Record to the IPFS,
+// links it to the project snapshot under the provided key and
+// announces it if required. The Record is encrypted first if the
+// database is using encryption or the Record has recipients.
+//
+// The Record is committed once the snapshot is updated, so a
+// failed announcement is only logged.
+//
+// It returns the CID of the committed Record.
+//
+// Note: the caller must hold the database lock.
+func (starkdb *Db) commitRecord(ctx context.Context, key string, record *Record) (string, error) {
+
+	// if encrypting requested and Record isn't already, do it now
+	if err := starkdb.encryptRecord(record); err != nil {
+		return "", err
+	}
+
+	// sign the Record as it will be stored
+	if err := starkdb.signRecord(record); err != nil {
+		return "", err
+	}
+
+	// marshal Record data to JSON
+	jsonData, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+
+	// create DAG node in IPFS for Record data
+	cid, err := starkdb.backend.DagPut(ctx, jsonData, starkdb.pinning)
+	if err != nil {
+		return "", err
+	}
+
+	// link the record CID to the project directory
+	if err := starkdb.linkRecord(ctx, key, cid); err != nil {
+		return "", err
+	}
+
+	// if announcing, do it now (the Record is already committed so failures are only logged)
+	if starkdb.announcing {
+		if err := starkdb.publishAnnouncement(starkdb.newAnnouncement(Announcement_SET, key, cid, record)); err != nil {
+			starkdb.send2log(fmt.Sprintf("could not announce %v: %v", key, err))
 		}
 	}
+	return cid, nil
+}
 
-	// add the pulled CID to this record
-	record.PreviousCID = cid
-	return record, nil
+// linkRecord is a helper method that links a Record CID to the
+// project snapshot under the provided key, takes a new snapshot
+// and updates the local keystore.
+//
+// Note: the caller must hold the database lock.
+func (starkdb *Db) linkRecord(ctx context.Context, key, cid string) error {
+	return starkdb.linkRecords(ctx, map[string]string{key: cid})
 }
 
-// publishAnnouncement will send a PubSub message on the topic
-// of the database project.
-func (starkdb *Db) publishAnnouncement(message []byte) error {
+// linkRecords is a helper method that links several Record
+// CIDs to the project snapshot (using the map keys as the
+// database keys) in a single update, takes a new snapshot
+// and updates the local keystore.
+//
+// Note: the caller must hold the database lock.
+func (starkdb *Db) linkRecords(ctx context.Context, links map[string]string) error {
+	snapshotUpdate, err := starkdb.linkSnapshot(ctx, starkdb.snapshotCID, starkdb.snapshotCID, links, "")
+	if err != nil {
+		return errors.Wrap(err, ErrSnapshotUpdate.Error())
+	}
+	starkdb.snapshotCID = snapshotUpdate
+	for key, cid := range links {
+		starkdb.cidLookup[key] = cid
+	}
+	return nil
+}
+
+// newAnnouncement is a helper method that creates an Announcement
+// for a change to the database. The Record should be as it was
+// stored and is only included if it is small enough.
+//
+// Note: the caller must hold the database lock.
+func (starkdb *Db) newAnnouncement(annType Announcement_Type, key, cid string, record *Record) *Announcement {
+	ann := &Announcement{
+		Type:          annType,
+		Key:           key,
+		Cid:           cid,
+		SnapshotCID:   starkdb.snapshotCID,
+		Sender:        starkdb.backend.PrintNodeID(),
+		SchemaVersion: AnnouncementSchemaVersion,
+		Timestamp:     ptypes.TimestampNow(),
+	}
+	if record != nil && proto.Size(record) <= DefaultInlineRecordSize {
+		ann.Record = record
+	}
+	return ann
+}
+
+// removeRecord is a helper method that unlinks a Record from the
+// project snapshot, takes a new snapshot, unpins the Record and
+// updates the local keystore.
+//
+// The database is only updated once the Record is unpinned,
+// Records which were never pinned (e.g. when pinning is off)
+// are still removed.
+//
+// Note: the caller must hold the database lock.
+func (starkdb *Db) removeRecord(ctx context.Context, key, cid string) error {
+	snapshotUpdate, err := starkdb.backend.RmLink(ctx, starkdb.snapshotCID, key)
+	if err != nil {
+		return errors.Wrap(err, ErrSnapshotUpdate.Error())
+	}
+	snapshotUpdate, err = starkdb.linkSnapshot(ctx, starkdb.snapshotCID, snapshotUpdate, nil, "")
+	if err != nil {
+		return errors.Wrap(err, ErrSnapshotUpdate.Error())
+	}
+
+	// unpin the file
+	if err := starkdb.backend.Unpin(ctx, cid); err != nil && !errors.Is(err, ErrNotPinned) {
+		return err
+	}
+
+	// update the snapshot and remove from the keystore
+	starkdb.snapshotCID = snapshotUpdate
+	delete(starkdb.cidLookup, key)
+	starkdb.currentNumEntries--
+	return nil
+}
+
+// linkIncomingRecord is a helper method that links a Record
+// received from another database to the project snapshot,
+// pinning it first if the database is pinning.
+//
+// Note: the caller must hold the database lock.
+func (starkdb *Db) linkIncomingRecord(ctx context.Context, key, cid string) error {
+	if starkdb.pinning {
+		if err := starkdb.backend.Pin(ctx, cid, false); err != nil {
+			return err
+		}
+	}
+	return starkdb.linkRecord(ctx, key, cid)
+}
+
+// publishAnnouncement will send an Announcement as a
+// PubSub message on the topic of the database project.
+func (starkdb *Db) publishAnnouncement(ann *Announcement) error {
 	if !starkdb.isOnline() {
 		return ErrNodeOffline
 	}
 	if len(starkdb.project) == 0 {
 		return ErrNoProject
 	}
-	return starkdb.ipfsClient.SendMessage(starkdb.ctx, starkdb.project, message)
+	message, err := proto.Marshal(ann)
+	if err != nil {
+		return err
+	}
+	return starkdb.backend.SendMessage(starkdb.ctx, starkdb.project, message)
+}
+
+// readAnnouncement is a helper method that decodes an Announcement
+// from a PubSub message and checks it can be handled.
+func readAnnouncement(data []byte, from string) (*Announcement, error) {
+	ann := &Announcement{}
+	if err := proto.Unmarshal(data, ann); err != nil {
+		return nil, errors.Wrap(err, ErrAnnouncement.Error())
+	}
+	if ann.GetSchemaVersion() != AnnouncementSchemaVersion {
+		return nil, fmt.Errorf("%v: %d", ErrAnnouncementVersion, ann.GetSchemaVersion())
+	}
+	if ann.GetSender() != from {
+		return nil, fmt.Errorf("%v: sent by %v but claims %v", ErrAnnouncement, from, ann.GetSender())
+	}
+	return ann, nil
+}
+
+// getAnnouncedRecord is a helper method that collects the Record
+// for a SET Announcement. If the Record was included in the
+// Announcement, its CID and signature are checked before it is
+// added to the IPFS directly, otherwise it is fetched via the
+// CID. The signature and author of the returned Record are
+// always checked (see verifyAuthor).
+func (starkdb *Db) getAnnouncedRecord(ann *Announcement) (*Record, error) {
+	if inline := ann.GetRecord(); inline != nil {
+		jsonData, err := json.Marshal(inline)
+		if err != nil {
+			return nil, err
+		}
+		node, err := cbor.FromJSON(bytes.NewReader(jsonData), starkipfs.DefaultMhType, -1)
+		if err != nil {
+			return nil, err
+		}
+		if cid := node.Cid().String(); cid != ann.GetCid() {
+			return nil, fmt.Errorf("%v: announced %v but Record is %v", ErrAnnouncement, ann.GetCid(), cid)
+		}
+		if err := starkdb.verifyAuthor(inline); err != nil {
+			return nil, err
+		}
+		if _, err := starkdb.backend.DagPut(starkdb.ctx, jsonData, starkdb.pinning); err != nil {
+			return nil, err
+		}
+	}
+	return starkdb.getVerifiedRecordFromCID(ann.GetCid())
 }
 
 // isOnline returns true if the starkDB is in online mode
 // and the IPFS daemon is reachable.
 // TODO: this needs some more work.
 func (starkdb *Db) isOnline() bool {
-	return starkdb.ipfsClient.Online()
+	return starkdb.backend.Online()
 }
 
 // send2log will send a message to the log if one
@@ -0,0 +1,48 @@
+package stark
+
+import (
+	"context"
+	"fmt"
+)
+
+// GetIPNSName returns the IPNS name the database
+// snapshot is published under. It is empty unless the
+// database was opened using the WithIPNS option.
+func (starkdb *Db) GetIPNSName() string {
+	starkdb.Lock()
+	defer starkdb.Unlock()
+	return starkdb.ipnsName
+}
+
+// PublishIPNS will publish the current database snapshot
+// to the IPNS name for the database project, creating the
+// keystore key for the project if needed.
+//
+// This is done automatically by Set when the WithIPNS
+// option is used, but can also be called directly (e.g.
+// before closing the database).
+//
+// It returns the IPNS name.
+func (starkdb *Db) PublishIPNS(ctx context.Context) (string, error) {
+	// publish one snapshot at a time, so the latest snapshot is always published last
+	starkdb.ipnsLock.Lock()
+	defer starkdb.ipnsLock.Unlock()
+	starkdb.Lock()
+	snapshotCID := starkdb.snapshotCID
+	starkdb.Unlock()
+	name, err := starkdb.backend.PublishName(ctx, starkdb.getIPNSKey(), snapshotCID)
+	if err != nil {
+		return "", err
+	}
+	starkdb.Lock()
+	starkdb.ipnsName = name
+	starkdb.Unlock()
+	starkdb.send2log(fmt.Sprintf("published snapshot to IPNS: %v->%v", name, snapshotCID))
+	return name, nil
+}
+
+// getIPNSKey returns the name of the keystore key
+// used to publish the database project to IPNS.
+func (starkdb *Db) getIPNSKey() string {
+	return DefaultIPNSKeyPrefix + starkdb.project
+}
@@ -2,14 +2,19 @@ package stark
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"strings"
 	"time"
 
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/libp2p/go-libp2p-core/peer"
+	ma "github.com/multiformats/go-multiaddr"
 	"github.com/pkg/errors"
 
 	starkcrypto "github.com/will-rowe/stark/src/crypto"
 	starkipfs "github.com/will-rowe/stark/src/ipfs"
+	starkmemory "github.com/will-rowe/stark/src/memory"
 	starkpinata "github.com/will-rowe/stark/src/pinata"
 )
 
@@ -32,12 +37,78 @@ func SetProject(project string) DbOption {
 // otherwise it will check the provided CID and
 // populate the starkDB from the existing records
 // contained in the snapshot.
+// An IPNS name (/ipns/...) can also be provided, in
+// which case it is resolved to a snapshot CID when
+// the database is opened.
 func SetSnapshotCID(path string) DbOption {
 	return func(starkdb *Db) error {
 		return starkdb.setSnapshotCID(path)
 	}
 }
 
+// WithHistoricSnapshot is an option setter for the OpenDB
+// constructor that opens the database read-only at the
+// provided snapshot CID. Records can be retrieved as they
+// were when the snapshot was taken, but Set, Delete and
+// Rollback will return ErrReadOnly.
+//
+// Note: This option replaces any CID provided by
+// SetSnapshotCID.
+func WithHistoricSnapshot(cid string) DbOption {
+	return func(starkdb *Db) error {
+		if err := starkdb.setSnapshotCID(cid); err != nil {
+			return err
+		}
+		return starkdb.setReadOnly(true)
+	}
+}
+
+// WithHistoricTime is an option setter for the OpenDB
+// constructor that opens the database read-only as it
+// was at the provided time. The snapshot lineage is
+// searched, starting from the snapshot provided by
+// SetSnapshotCID (or WithHistoricSnapshot), for the
+// most recent snapshot taken at or before this time.
+func WithHistoricTime(asOf time.Time) DbOption {
+	return func(starkdb *Db) error {
+		if err := starkdb.setHistoricTime(asOf); err != nil {
+			return err
+		}
+		return starkdb.setReadOnly(true)
+	}
+}
+
+// WithRepoPath is an option setter for the OpenDB
+// constructor that sets the IPFS repo to use for the
+// database instance.
+// If the repo does not exist, a new one will be
+// created and initialised with a new identity.
+//
+// Note: If not provided to the constructor, the
+// system default IPFS repo will be used.
+func WithRepoPath(repoPath string) DbOption {
+	return func(starkdb *Db) error {
+		return starkdb.setRepoPath(repoPath)
+	}
+}
+
+// WithIPFSAPI is an option setter for the OpenDB
+// constructor that tells starkDB to attach to an
+// already running IPFS daemon via its HTTP API,
+// instead of running an IPFS node in-process.
+//
+// Note: If the IPFS repo is locked by a running
+// daemon, starkDB will attach to it automatically
+// (unless WithOffline is used, as the daemon's
+// networking can't be disabled). The private key of
+// the daemon isn't available, so Records are stored
+// unsigned.
+func WithIPFSAPI(apiAddr string) DbOption {
+	return func(starkdb *Db) error {
+		return starkdb.setAPIAddr(apiAddr)
+	}
+}
+
 // WithPeers is an option setter for the OpenDB
 // constructor that adds a list of nodes to the
 // default IPFS bootstrappers.
@@ -71,6 +142,48 @@ func WithAnnouncing() DbOption {
 	}
 }
 
+// WithAutoSync is an option setter for the OpenDB constructor
+// that tells starkDB to listen for Records announced on the
+// database project and apply them to the database as they
+// arrive (see Listen and ApplyRecord). Conflicting Records
+// are handled using the database ConflictPolicy.
+//
+// Note: Progress is reported via the logger (see WithLogging)
+// and the listener is stopped by the teardown function.
+func WithAutoSync() DbOption {
+	return func(starkdb *Db) error {
+		return starkdb.setAutoSync(true)
+	}
+}
+
+// WithSnapshotInterval is an option setter for the OpenDB
+// constructor that sets how often an announcing database
+// broadcasts its head snapshot on the database project, so
+// that listening databases can detect when they have fallen
+// out of step. An interval of zero or less disables the
+// snapshot announcements.
+//
+// Note: If not provided to the constructor, announcing
+// databases will use DefaultSnapshotInterval.
+func WithSnapshotInterval(interval time.Duration) DbOption {
+	return func(starkdb *Db) error {
+		return starkdb.setSnapshotInterval(interval)
+	}
+}
+
+// WithOffline is an option setter for the OpenDB constructor
+// that builds the IPFS node with networking disabled. Records
+// can still be added and retrieved locally, then synced later
+// by re-opening the database without this option.
+//
+// Note: The node will not bootstrap, and Listen, announcing
+// and Pinata are not available in offline mode.
+func WithOffline() DbOption {
+	return func(starkdb *Db) error {
+		return starkdb.setOffline(true)
+	}
+}
+
 // WithEncryption is an option setter for the OpenDB constructor
 // that tells starkDB to make encrypted writes to IPFS using the
 // password in STARK_DB_PASSWORD env variable.
@@ -83,10 +196,26 @@ func WithEncryption() DbOption {
 	}
 }
 
+// WithClearFields is an option setter for the OpenDB
+// constructor that sets the encryption policy, which
+// lists the Record fields (see the Field constants) to
+// leave unencrypted when WithEncryption is used. All
+// other user updatable fields are encrypted.
+//
+// Note: By default only the Record alias is left
+// unencrypted, as it is used as the database key and is
+// already visible in the project snapshot.
+func WithClearFields(fields ...string) DbOption {
+	return func(starkdb *Db) error {
+		return starkdb.setClearFields(fields)
+	}
+}
+
 // WithPinata is an option setter for the OpenDB constructor
 // that tells starkDB to pin it's contents with pinata every
-// time the interval is passed during set operations. A value
-// of < 1 tells starkDB NOT to use pinata (default).
+// time the interval is passed during write operations (any
+// operation which takes a new snapshot, counted per Record).
+// A value of < 1 tells starkDB NOT to use pinata (default).
 //
 // Note: This option requires the PINATA_API_KEY and the
 // PINATA_SECRET_KEY environment variables to be set.
@@ -96,6 +225,81 @@ func WithPinata(interval int) DbOption {
 	}
 }
 
+// WithIPNS is an option setter for the OpenDB constructor
+// that tells starkDB to publish the database snapshot to
+// IPNS every time the interval is passed during write
+// operations (see WithPinata). A value of < 1 tells
+// starkDB NOT to use IPNS (default).
+//
+// The snapshot is published under a key in the node's
+// keystore named after the project (see
+// DefaultIPNSKeyPrefix), so collaborators can open the
+// database using the IPNS name (see GetIPNSName) instead
+// of a snapshot CID.
+func WithIPNS(interval int) DbOption {
+	return func(starkdb *Db) error {
+		return starkdb.setIPNSInterval(interval)
+	}
+}
+
+// WithMirror is an option setter for the OpenDB constructor
+// that opens the database as a read-only mirror of another
+// database. The source is either a snapshot CID or an IPNS
+// name (see WithIPNS), which is followed so that the mirror
+// keeps up with the head of the source project.
+//
+// Every Record (including previous versions) and any
+// content linked to the Records is pinned in the local
+// IPFS repo. Records can be retrieved from the mirror as
+// normal, but Set, Delete and Rollback will return
+// ErrReadOnly.
+//
+// Note: The mirror checks the source every
+// DefaultMirrorInterval and is stopped by the teardown
+// function. Mirror can be used to check on demand.
+func WithMirror(source string) DbOption {
+	return func(starkdb *Db) error {
+		if err := starkdb.setMirrorSource(source); err != nil {
+			return err
+		}
+		return starkdb.setReadOnly(true)
+	}
+}
+
+// WithConflictPolicy is an option setter for the OpenDB
+// constructor that sets how starkDB handles Records from
+// other databases which conflict with the Record held
+// under the same key (see ApplyRecord).
+//
+// Note: If not provided to the constructor, conflicting
+// Records are rejected and reported (RejectAndReport).
+func WithConflictPolicy(policy ConflictPolicy) DbOption {
+	return func(starkdb *Db) error {
+		return starkdb.setConflictPolicy(policy)
+	}
+}
+
+// WithRequireSigned is an option setter for the OpenDB
+// constructor that tells starkDB to reject incoming
+// Records (applied from announcements, syncs, merges and
+// mirrors) which have not been signed by their author.
+// If any trusted authors (IPFS peer IDs) are provided,
+// Records signed by other authors are also rejected
+// (Records signed by this node are always accepted).
+// The database must be able to sign its own Records,
+// so this option can't be used to write via an IPFS
+// daemon (see WithIPFSAPI).
+//
+// Note: incoming Records with an invalid signature are
+// always rejected, this option only affects unsigned
+// Records and their authors. Records already held by
+// the database are not checked when they are read.
+func WithRequireSigned(trustedAuthors ...string) DbOption {
+	return func(starkdb *Db) error {
+		return starkdb.setRequireSigned(trustedAuthors)
+	}
+}
+
 // WithLogging is an option setter for the OpenDB constructor
 // that provides starkDB with a logging channel to send
 // internal state messages during the lifetime of the
@@ -108,6 +312,28 @@ func WithLogging(loggingChan chan interface{}) DbOption {
 	}
 }
 
+// WithBackend is an option setter for the OpenDB constructor
+// that tells starkDB to use the provided Backend for storage
+// and messaging, instead of an IPFS client.
+func WithBackend(backend Backend) DbOption {
+	return func(starkdb *Db) error {
+		return starkdb.setBackend(backend)
+	}
+}
+
+// WithInMemory is an option setter for the OpenDB constructor
+// that tells starkDB to use an in-memory blockstore instead
+// of an IPFS client. No IPFS repo is needed but Records will
+// not persist beyond the lifetime of the database instance.
+//
+// Note: The in-memory store is offline, so PubSub, Pinata
+// and peer information are not available.
+func WithInMemory() DbOption {
+	return func(starkdb *Db) error {
+		return starkdb.setBackend(starkmemory.NewStore())
+	}
+}
+
 // OpenDB opens a new instance of a starkdb.
 //
 // If there is an existing database in the specified local
@@ -115,30 +341,63 @@ func WithLogging(loggingChan chan interface{}) DbOption {
 // the DB will open that.
 //
 // It returns the initialised database, a teardown function
-// and any error encountered.
-func OpenDB(options ...DbOption) (*Db, func() error, error) {
+// and any error encountered. If the database can't be
+// opened, the context is cancelled and any IPFS client
+// started by OpenDB is closed, so the IPFS repo is
+// released before the error is returned.
+func OpenDB(options ...DbOption) (_ *Db, _ func() error, err error) {
 
 	// context for the lifetime of the DB
 	ctx, cancel := context.WithCancel(context.Background())
 
 	// create the uninitialised DB
 	starkdb := &Db{
-		ctx:         ctx,
-		ctxCancel:   cancel,
-		cidLookup:   make(map[string]string),
-		loggingChan: nil,
+		ctx:              ctx,
+		ctxCancel:        cancel,
+		cidLookup:        make(map[string]string),
+		pendingDeletes:   make(map[string]string),
+		pendingSnapshots: make(map[string]string),
+		mirrored:         make(map[string]struct{}),
+		cipherKeys:       make(map[string][]byte),
+		projectMetas:     make(map[string]struct{}),
+		metaLineage:      make(map[string]struct{}),
+		loggingChan:      nil,
 
 		// defaults
-		project:        DefaultProject,
-		snapshotCID:    "",
-		pinning:        true,
-		announcing:     false,
-		cipherKey:      nil,
-		peers:          starkipfs.DefaultBootstrappers,
-		pinataInterval: 0,
-		sessionEntries: 0,
+		project:          DefaultProject,
+		snapshotCID:      "",
+		repoPath:         "",
+		apiAddr:          "",
+		pinning:          true,
+		announcing:       false,
+		autoSync:         false,
+		snapshotInterval: DefaultSnapshotInterval,
+		offline:          false,
+		readOnly:         false,
+		password:         "",
+		cipherKey:        nil,
+		kdfID:            "",
+		clearFields:      []string{FieldAlias},
+		conflictPolicy:   RejectAndReport,
+		peers:            starkipfs.DefaultBootstrappers,
+		pinataInterval:   0,
+		sessionEntries:   0,
 	}
 
+	// clean up if the database can't be opened
+	var client Backend
+	defer func() {
+		if err == nil {
+			return
+		}
+		cancel()
+		if client != nil {
+			if endErr := client.EndSession(); endErr != nil {
+				starkdb.send2log(fmt.Sprintf("could not close the IPFS client: %v", endErr))
+			}
+		}
+	}()
+
 	// add the provided options
 	for _, option := range options {
 		err := option(starkdb)
@@ -151,58 +410,173 @@ func OpenDB(options ...DbOption) (*Db, func() error, error) {
 	if !starkdb.pinning && (starkdb.pinataInterval > 0) {
 		return nil, nil, ErrPinataOpt
 	}
-	if len(starkdb.peers) < DefaultMinBootstrappers {
+	if !starkdb.pinning && len(starkdb.mirrorSource) != 0 {
+		return nil, nil, ErrMirrorOpt
+	}
+	if starkdb.offline && (starkdb.announcing || starkdb.autoSync || starkdb.ipnsInterval > 0 || len(starkdb.mirrorSource) != 0 || starkdb.pinataInterval > 0 || len(starkdb.apiAddr) != 0) {
+		return nil, nil, ErrOfflineOpt
+	}
+	if starkdb.readOnly && starkdb.autoSync {
+		return nil, nil, ErrReadOnly
+	}
+	if !starkdb.historicTime.IsZero() && len(starkdb.snapshotCID) == 0 {
+		return nil, nil, ErrHistoricOpt
+	}
+	if !starkdb.offline && len(starkdb.peers) < DefaultMinBootstrappers {
 		return nil, nil, ErrBootstrappers
 	}
 
-	// init the IPFS client
-	client, err := starkipfs.NewIPFSclient(starkdb.ctx)
-	if err != nil {
-		return nil, nil, err
+	// init the IPFS client if no other backend was provided
+	if starkdb.backend == nil {
+		ipfsClient, err := starkdb.newIPFSclient()
+		if err != nil {
+			return nil, nil, err
+		}
+		client = ipfsClient
+		starkdb.backend = client
+	}
+
+	// bootstrap the node
+	if !starkdb.offline {
+		go starkdb.backend.Connect(starkdb.ctx, starkdb.peers, starkdb.loggingChan)
 	}
-	starkdb.ipfsClient = client
 
-	// bootstrap the IPFS node
-	go starkdb.ipfsClient.Connect(starkdb.ctx, starkdb.peers, starkdb.loggingChan)
+	// resolve the base CID if an IPNS name was provided
+	if strings.HasPrefix(starkdb.snapshotCID, "/ipns/") {
+		ctx2, cancel2 := context.WithTimeout(starkdb.ctx, DefaultIPNSTimeout)
+		cid, err := starkdb.backend.ResolveName(ctx2, starkdb.snapshotCID)
+		cancel2()
+		if err != nil {
+			return nil, nil, errors.Wrap(err, ErrInvalidSnapshot.Error())
+		}
+		starkdb.send2log(fmt.Sprintf("resolved %v to snapshot: %v", starkdb.snapshotCID, cid))
+		starkdb.snapshotCID = cid
+	}
+
+	// get the IPNS name for the project if publishing
+	if starkdb.ipnsInterval > 0 {
+		name, err := starkdb.backend.NameKey(starkdb.ctx, starkdb.getIPNSKey())
+		if err != nil {
+			return nil, nil, err
+		}
+		starkdb.ipnsName = name
+	}
 
 	// if no base CID was provided, initialise a snapshot
-	if len(starkdb.snapshotCID) == 0 {
-		cid, err := starkdb.ipfsClient.NewDagNode(starkdb.ctx)
+	newSnapshot := len(starkdb.snapshotCID) == 0
+	var projectMetaCID string
+	if newSnapshot {
+		cid, err := starkdb.backend.NewDagNode(starkdb.ctx)
 		if err != nil {
 			return nil, nil, errors.Wrap(err, ErrSnapshotUpdate.Error())
 		}
 		starkdb.snapshotCID = cid
 	} else {
 
-		// populate the lookup map with the existing snapshot
-		ctx2, cancel2 := context.WithTimeout(starkdb.ctx, 2*time.Second)
-		defer cancel2()
-		links, err := starkdb.ipfsClient.GetNodeLinks(ctx2, starkdb.snapshotCID)
-		if err != nil {
-			return nil, nil, errors.Wrap(err, ErrInvalidSnapshot.Error())
+		// find the historic snapshot if a time was requested
+		if !starkdb.historicTime.IsZero() {
+			cid, err := starkdb.findSnapshot(starkdb.historicTime)
+			if err != nil {
+				return nil, nil, errors.Wrap(err, ErrInvalidSnapshot.Error())
+			}
+			starkdb.snapshotCID = cid
 		}
-		for _, link := range links {
-			starkdb.cidLookup[link.Name] = link.Cid.String()
+
+		// populate the lookup map with the existing snapshot,
+		// paging through the project directory shards
+		if err := starkdb.loadSnapshotLinks(starkdb.snapshotCID, func(link *ipld.Link) error {
+			if link.Name == ProjectMetaLink {
+				projectMetaCID = link.Cid.String()
+			}
+			if !isReservedKey(link.Name) {
+				starkdb.cidLookup[link.Name] = link.Cid.String()
+			}
+			return nil
+		}); err != nil {
+			return nil, nil, errors.Wrap(err, ErrInvalidSnapshot.Error())
 		}
 	}
 
 	// set the stats
 	starkdb.currentNumEntries = len(starkdb.cidLookup)
 
+	// derive the cipher key if encrypting
+	if len(starkdb.password) != 0 {
+		if err := starkdb.setupCipherKey(starkdb.ctx, projectMetaCID, newSnapshot); err != nil {
+			return nil, nil, errors.Wrap(err, ErrCipherKey.Error())
+		}
+	}
+
+	// start syncing if requested
+	if starkdb.autoSync {
+		if err := starkdb.startAutoSync(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// start mirroring if requested
+	if len(starkdb.mirrorSource) != 0 {
+		starkdb.startMirror()
+	}
+
+	// start announcing the head snapshot if requested
+	if starkdb.announcing && starkdb.snapshotInterval > 0 {
+		starkdb.startSnapshotAnnouncer()
+	}
+
 	// return the teardown so we can ensure it happens
 	return starkdb, starkdb.teardown, nil
 }
 
+// newIPFSclient will return an IPFS client for the
+// database. If an API address was provided, or the
+// IPFS repo is locked by a running daemon, the client
+// will attach to the daemon via its HTTP API.
+func (starkdb *Db) newIPFSclient() (Backend, error) {
+	if len(starkdb.apiAddr) != 0 {
+		return starkipfs.NewHTTPClient(starkdb.apiAddr)
+	}
+
+	// check for a running daemon
+	locked, err := starkipfs.RepoLocked(starkdb.repoPath)
+	if err != nil {
+		return nil, err
+	}
+	if locked {
+		if starkdb.offline {
+			return nil, ErrOfflineOpt
+		}
+		starkdb.send2log("IPFS repo is locked, attaching to the running daemon")
+		return starkipfs.NewHTTPClientFromRepo(starkdb.repoPath)
+	}
+
+	// otherwise run a node in-process
+	var clientOpts []starkipfs.ClientOption
+	if len(starkdb.repoPath) != 0 {
+		clientOpts = append(clientOpts, starkipfs.SetRepoPath(starkdb.repoPath))
+	}
+	if starkdb.offline {
+		clientOpts = append(clientOpts, starkipfs.WithOffline())
+	}
+	return starkipfs.NewIPFSclient(starkdb.ctx, clientOpts...)
+}
+
 // teardown will close down all the open guff
 // nicely.
 func (starkdb *Db) teardown() error {
+
+	// stop syncing, announcing, mirroring and publishing before taking the lock, so any Record being applied can finish
+	starkdb.stopAutoSync()
+	starkdb.stopSnapshotAnnouncer()
+	starkdb.stopMirror()
+	starkdb.ipnsPublishing.Wait()
 	starkdb.Lock()
 
 	// cancel the db context
 	starkdb.ctxCancel()
 
-	// close IPFS
-	if err := starkdb.ipfsClient.EndSession(); err != nil {
+	// close the backend
+	if err := starkdb.backend.EndSession(); err != nil {
 		return err
 	}
 	starkdb.Unlock()
@@ -235,6 +609,41 @@ func (starkdb *Db) setSnapshotCID(cid string) error {
 	return nil
 }
 
+// setReadOnly sets the database to read-only mode.
+func (starkdb *Db) setReadOnly(readOnly bool) error {
+	starkdb.readOnly = readOnly
+	return nil
+}
+
+// setHistoricTime will set the time to open
+// the database at.
+func (starkdb *Db) setHistoricTime(asOf time.Time) error {
+	if asOf.IsZero() {
+		return ErrNoHistoricTime
+	}
+	starkdb.historicTime = asOf
+	return nil
+}
+
+// setRepoPath will set the IPFS repo path.
+func (starkdb *Db) setRepoPath(repoPath string) error {
+	if len(repoPath) == 0 {
+		return ErrNoRepoPath
+	}
+	starkdb.repoPath = repoPath
+	return nil
+}
+
+// setAPIAddr will set the HTTP API address of
+// the IPFS daemon to attach to.
+func (starkdb *Db) setAPIAddr(apiAddr string) error {
+	if _, err := ma.NewMultiaddr(apiAddr); err != nil {
+		return err
+	}
+	starkdb.apiAddr = apiAddr
+	return nil
+}
+
 // setNodes will add nodes to the list of bootstrapper
 // nodes to use for IPFS peer discovery.
 func (starkdb *Db) setNodes(nodeList []string) error {
@@ -257,11 +666,50 @@ func (starkdb *Db) setAnnouncing(announcing bool) error {
 	return nil
 }
 
+// setAutoSync sets the database to apply records
+// announced via PubSub.
+func (starkdb *Db) setAutoSync(autoSync bool) error {
+	starkdb.autoSync = autoSync
+	return nil
+}
+
+// setSnapshotInterval sets the time between head
+// snapshot announcements.
+func (starkdb *Db) setSnapshotInterval(interval time.Duration) error {
+	starkdb.snapshotInterval = interval
+	return nil
+}
+
+// setMirrorSource will set the snapshot CID or
+// IPNS name to mirror.
+func (starkdb *Db) setMirrorSource(source string) error {
+	if len(source) == 0 {
+		return ErrNoCID
+	}
+	starkdb.mirrorSource = source
+	return nil
+}
+
+// setIPNSInterval will set the number of write
+// operations between IPNS publishing.
+func (starkdb *Db) setIPNSInterval(interval int) error {
+	starkdb.ipnsInterval = interval
+	return nil
+}
+
+// setOffline sets the database to offline mode.
+func (starkdb *Db) setOffline(offline bool) error {
+	starkdb.offline = offline
+	return nil
+}
+
 // setEncryption tells starkDB to make encrypted
 // writes.
 func (starkdb *Db) setEncryption(val bool) error {
 	if val == false {
+		starkdb.password = ""
 		starkdb.cipherKey = nil
+		delete(starkdb.cipherKeys, "")
 		return nil
 	}
 
@@ -271,14 +719,26 @@ func (starkdb *Db) setEncryption(val bool) error {
 		return ErrNoEnvSet
 	}
 
-	// convert password to cipher key
-	cipherKey, err := starkcrypto.Password2cipherkey(password)
+	// keep the MD5 derived key so that Records from
+	// earlier versions can be decrypted, the cipher key
+	// for new Records is derived once the project
+	// snapshot is loaded
+	legacyKey, err := starkcrypto.Password2cipherkey(password)
 	if err != nil {
 		return err
 	}
+	starkdb.password = password
+	starkdb.cipherKeys[""] = legacyKey
+	return nil
+}
 
-	// set the key
-	starkdb.cipherKey = cipherKey
+// setClearFields sets the Record fields which
+// are left unencrypted.
+func (starkdb *Db) setClearFields(fields []string) error {
+	if _, err := getClearFields(fields); err != nil {
+		return err
+	}
+	starkdb.clearFields = fields
 	return nil
 }
 
@@ -313,6 +773,39 @@ func (starkdb *Db) setPinataPinInterval(interval int) error {
 	return nil
 }
 
+// setBackend will set the storage backend.
+func (starkdb *Db) setBackend(backend Backend) error {
+	if backend == nil {
+		return ErrNoBackend
+	}
+	starkdb.backend = backend
+	return nil
+}
+
+// setConflictPolicy will set the policy used to
+// handle conflicting Records.
+func (starkdb *Db) setConflictPolicy(policy ConflictPolicy) error {
+	if policy == nil {
+		return ErrNoConflictPolicy
+	}
+	starkdb.conflictPolicy = policy
+	return nil
+}
+
+// setRequireSigned tells starkDB to reject unsigned
+// Records and sets the trusted authors.
+func (starkdb *Db) setRequireSigned(trustedAuthors []string) error {
+	starkdb.requireSigned = true
+	starkdb.trustedAuthors = make(map[string]bool, len(trustedAuthors))
+	for _, author := range trustedAuthors {
+		if _, err := peer.Decode(author); err != nil {
+			return errors.Wrapf(err, "%v: %v", ErrUntrustedAuthor, author)
+		}
+		starkdb.trustedAuthors[author] = true
+	}
+	return nil
+}
+
 // setLogger will attach a logging channel to
 // the starkDB instance.
 func (starkdb *Db) setLogger(loggingChan chan interface{}) error {
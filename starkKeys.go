@@ -0,0 +1,316 @@
+package stark
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	cbor "github.com/ipfs/go-ipld-cbor"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/pkg/errors"
+	starkcrypto "github.com/will-rowe/stark/src/crypto"
+)
+
+// projectMeta is the project metadata which is
+// linked to each project snapshot. It holds the
+// key derivation parameters for encrypted DB
+// instances, which are not secret.
+type projectMeta struct {
+	Project  string                 `json:"project"`
+	KDF      *starkcrypto.KDFParams `json:"kdf"`
+	Rotating bool                   `json:"rotating,omitempty"` // set while RotateKey is re-encrypting Records with these parameters
+}
+
+// setupCipherKey is a helper method that derives the
+// cipher key for an encrypted DB instance.
+//
+// If the project snapshot already links to project
+// metadata, the key derivation parameters are read
+// from it. Otherwise new parameters (with a random
+// salt) are generated and linked to the snapshot,
+// unless the database is read-only, in which case
+// the database can only decrypt existing Records.
+//
+// If a cipher key rotation is unfinished, it isn't
+// known whether the password is the old or the new one,
+// so no Records can be encrypted with the database
+// password until RotateKey is resumed.
+func (starkdb *Db) setupCipherKey(ctx context.Context, metaCID string, newSnapshot bool) error {
+	if len(metaCID) != 0 {
+		meta, err := starkdb.getProjectMeta(metaCID)
+		if err != nil {
+			return err
+		}
+		if meta.Rotating {
+			starkdb.rotating = true
+			starkdb.send2log("cipher key rotation is unfinished, some Records can't be decrypted and encrypted Records can't be written until RotateKey is resumed")
+		}
+		cipherKey, err := starkdb.getCipherKey(metaCID)
+		if err != nil {
+			return err
+		}
+		starkdb.cipherKey = cipherKey
+		starkdb.kdfID = metaCID
+		return nil
+	}
+	if starkdb.readOnly {
+		return nil
+	}
+
+	// generate the key derivation parameters and derive the key
+	params, err := starkcrypto.NewKDFParams()
+	if err != nil {
+		return err
+	}
+	cipherKey, err := starkcrypto.DeriveCipherKey(starkdb.password, params)
+	if err != nil {
+		return err
+	}
+
+	// store the parameters in the project metadata and link it to the snapshot
+	metaCID, err = starkdb.linkProjectMeta(ctx, &projectMeta{Project: starkdb.project, KDF: params}, newSnapshot)
+	if err != nil {
+		return err
+	}
+	starkdb.cipherKey = cipherKey
+	starkdb.kdfID = metaCID
+	starkdb.keyLock.Lock()
+	starkdb.cipherKeys[metaCID] = cipherKey
+	starkdb.keyLock.Unlock()
+	starkdb.send2log(fmt.Sprintf("generated key derivation parameters: %v", metaCID))
+	return nil
+}
+
+// getCipherKey is a helper method that returns the
+// cipher key for the key derivation parameters with
+// the provided CID (as stored in a Record's kdfID).
+//
+// An empty kdfID returns the MD5 derived key used by
+// earlier versions of stark. Keys are derived from the
+// database password and cached for the session, so
+// Records from other database instances (which may
+// use different parameters) can also be decrypted,
+// provided the parameters are linked to the database
+// snapshot lineage.
+func (starkdb *Db) getCipherKey(kdfID string) ([]byte, error) {
+	starkdb.keyLock.Lock()
+	cipherKey, ok := starkdb.cipherKeys[kdfID]
+	starkdb.keyLock.Unlock()
+	if ok {
+		return cipherKey, nil
+	}
+	if len(starkdb.password) == 0 {
+		return nil, starkcrypto.ErrCipherKeyMissing
+	}
+
+	// derive the key without holding the lock, as this is slow
+	cipherKey, err := starkdb.deriveCipherKey(starkdb.password, kdfID)
+	if err != nil {
+		return nil, err
+	}
+	starkdb.keyLock.Lock()
+	starkdb.cipherKeys[kdfID] = cipherKey
+	starkdb.keyLock.Unlock()
+	return cipherKey, nil
+}
+
+// deriveCipherKey is a helper method that derives the
+// cipher key for a password, using the key derivation
+// parameters with the provided CID (an empty kdfID
+// uses the MD5 hash of the password).
+//
+// Only parameters linked to the database snapshot
+// lineage are used, so that a Record can't make the
+// database fetch and run arbitrary key derivations.
+func (starkdb *Db) deriveCipherKey(password, kdfID string) ([]byte, error) {
+	if len(kdfID) == 0 {
+		return starkcrypto.Password2cipherkey(password)
+	}
+	if err := starkdb.checkProjectMeta(kdfID); err != nil {
+		return nil, err
+	}
+	meta, err := starkdb.getProjectMeta(kdfID)
+	if err != nil {
+		return nil, err
+	}
+	return starkcrypto.DeriveCipherKey(password, meta.KDF)
+}
+
+// checkProjectMeta is a helper method that checks the
+// project metadata with the provided CID is linked to
+// the database snapshot lineage.
+//
+// It returns ErrProjectMeta if it isn't.
+func (starkdb *Db) checkProjectMeta(metaCID string) error {
+	starkdb.keyLock.Lock()
+	_, ok := starkdb.projectMetas[metaCID]
+	starkdb.keyLock.Unlock()
+	if ok {
+		return nil
+	}
+	if err := starkdb.findProjectMetas(starkdb.snapshotCID); err != nil {
+		return err
+	}
+	starkdb.keyLock.Lock()
+	_, ok = starkdb.projectMetas[metaCID]
+	starkdb.keyLock.Unlock()
+	if !ok {
+		return ErrProjectMeta
+	}
+	return nil
+}
+
+// findProjectMetas is a helper method that steps back
+// through the lineage of the provided snapshot and
+// records the project metadata linked to each snapshot,
+// stopping at any snapshot which has already been
+// checked.
+func (starkdb *Db) findProjectMetas(snapshotCID string) error {
+	var snapshots, metas []string
+	iter := starkdb.newSnapshotIterator(snapshotCID)
+	for iter.Next() {
+		snapshot := iter.Snapshot()
+		starkdb.keyLock.Lock()
+		_, checked := starkdb.metaLineage[snapshot.CID]
+		starkdb.keyLock.Unlock()
+		if checked {
+			break
+		}
+		snapshots = append(snapshots, snapshot.CID)
+		if len(snapshot.ProjectMeta) != 0 {
+			metas = append(metas, snapshot.ProjectMeta)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	// only mark the snapshots once the whole lineage has been checked
+	starkdb.keyLock.Lock()
+	defer starkdb.keyLock.Unlock()
+	for _, cid := range snapshots {
+		starkdb.metaLineage[cid] = struct{}{}
+	}
+	for _, cid := range metas {
+		starkdb.projectMetas[cid] = struct{}{}
+	}
+	return nil
+}
+
+// getProjectMetaCID is a helper method that finds the
+// project metadata linked to the current snapshot.
+//
+// It returns an empty string if there is no project
+// metadata.
+func (starkdb *Db) getProjectMetaCID(ctx context.Context) (string, error) {
+	var metaCID string
+	err := starkdb.forEachSnapshotLink(ctx, starkdb.snapshotCID, func(link *ipld.Link) error {
+		if link.Name == ProjectMetaLink {
+			metaCID = link.Cid.String()
+		}
+		return nil
+	})
+	return metaCID, err
+}
+
+// linkProjectMeta is a helper method that adds project
+// metadata to the IPFS and links it to the current
+// snapshot, taking a new snapshot.
+//
+// It returns the CID of the project metadata.
+//
+// Note: the caller must hold the database lock (unless
+// the database is being opened).
+func (starkdb *Db) linkProjectMeta(ctx context.Context, meta *projectMeta, newSnapshot bool) (string, error) {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return "", err
+	}
+	metaCID, err := starkdb.backend.DagPut(ctx, data, starkdb.pinning)
+	if err != nil {
+		return "", err
+	}
+	var snapshotUpdate string
+	if newSnapshot {
+		snapshotUpdate, err = starkdb.backend.AddLink(ctx, starkdb.snapshotCID, metaCID, ProjectMetaLink)
+	} else {
+		snapshotUpdate, err = starkdb.linkSnapshot(ctx, starkdb.snapshotCID, starkdb.snapshotCID, map[string]string{ProjectMetaLink: metaCID}, "")
+	}
+	if err != nil {
+		return "", errors.Wrap(err, ErrSnapshotUpdate.Error())
+	}
+	starkdb.snapshotCID = snapshotUpdate
+	return metaCID, nil
+}
+
+// getProjectMeta is a helper method that collects
+// the project metadata from the IPFS, giving up after
+// the DefaultProjectMetaTimeout.
+func (starkdb *Db) getProjectMeta(cid string) (*projectMeta, error) {
+	ctx, cancel := context.WithTimeout(starkdb.ctx, DefaultProjectMetaTimeout)
+	defer cancel()
+	retrievedNode, err := starkdb.backend.DagGet(ctx, cid)
+	if err != nil {
+		return nil, err
+	}
+	cborNode, isCborNode := retrievedNode.(*cbor.Node)
+	if !isCborNode {
+		return nil, fmt.Errorf("%v: %v", ErrNodeFormat, cid)
+	}
+	data, err := cborNode.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	meta := &projectMeta{}
+	if err := json.Unmarshal(data, meta); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// encryptRecord is a helper method that encrypts a
+// Record which isn't already encrypted.
+//
+// Records with recipients are encrypted for those
+// recipients (see encryptForRecipients). Otherwise,
+// if the database is encrypted, the Record is
+// encrypted with the database cipher key and the key
+// derivation parameters which were used are recorded.
+//
+// It returns ErrRotationUnfinished if the database
+// cipher key is needed while a rotation is unfinished.
+func (starkdb *Db) encryptRecord(record *Record) error {
+	if record.GetEncrypted() {
+		return nil
+	}
+	if len(record.GetRecipients()) != 0 {
+		return starkdb.encryptForRecipients(record)
+	}
+	if len(starkdb.cipherKey) == 0 {
+		return nil
+	}
+	if starkdb.rotating {
+		return ErrRotationUnfinished
+	}
+	if err := record.Encrypt(starkdb.cipherKey, starkdb.clearFields...); err != nil {
+		return err
+	}
+	record.KdfID = starkdb.kdfID
+	return nil
+}
+
+// decryptRecord is a helper method that decrypts a
+// Record using the cipher key for the key derivation
+// parameters it was encrypted with, or the data key
+// wrapped for the database if the Record was shared
+// with recipients.
+func (starkdb *Db) decryptRecord(record *Record) error {
+	if len(record.GetRecipients()) != 0 {
+		return starkdb.decryptAsRecipient(record)
+	}
+	cipherKey, err := starkdb.getCipherKey(record.GetKdfID())
+	if err != nil {
+		return err
+	}
+	return record.Decrypt(cipherKey)
+}
@@ -0,0 +1,289 @@
+package stark
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	starkhelpers "github.com/will-rowe/stark/src/helpers"
+)
+
+// MergeSnapshots will perform a three-way merge of two
+// project snapshot heads (A and B), using their common
+// ancestor snapshot to work out what changed in each
+// head. If no ancestor is provided, the snapshot
+// lineage of the heads is used to find one.
+//
+// Keys changed in only one head are taken from that
+// head. Keys changed in both heads are resolved using
+// the Record UUIDs and histories, in the same way that
+// Set checks an incoming Record: if one version is a
+// more recent descendant of the other, it is kept.
+//
+// Any keys which can't be resolved are returned as
+// conflicts and the version from head A is kept in
+// the merged snapshot. Records from head B which fail
+// the signature checks (see WithRequireSigned) are
+// also returned as conflicts.
+//
+// The merged snapshot is not applied to the database,
+// use the Merge RPC for that.
+func (starkdb *Db) MergeSnapshots(ancestor, headA, headB string) (*MergeResponse, error) {
+	if len(headA) == 0 || len(headB) == 0 {
+		return nil, ErrNoCID
+	}
+
+	// find the common ancestor if none provided
+	if len(ancestor) == 0 {
+		var err error
+		ancestor, err = starkdb.findCommonAncestor(headA, headB)
+		if err != nil {
+			return nil, err
+		}
+	}
+	resp := &MergeResponse{
+		Ancestor:  ancestor,
+		Conflicts: []*MergeConflict{},
+	}
+
+	// check for a fast-forward (head B is already merged into head A)
+	//
+	// Note: head B is never fast-forwarded to, so that its
+	// changes get the signature checks below.
+	if ancestor == headB {
+		resp.Success = true
+		resp.SnapshotCID = headA
+		return resp, nil
+	}
+
+	// get the key->CID pairs for each snapshot
+	pairsO, err := starkdb.getSnapshotPairs(ancestor)
+	if err != nil {
+		return nil, err
+	}
+	pairsA, err := starkdb.getSnapshotPairs(headA)
+	if err != nil {
+		return nil, err
+	}
+	pairsB, err := starkdb.getSnapshotPairs(headB)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]struct{})
+	for _, pairs := range []map[string]string{pairsO, pairsA, pairsB} {
+		for key := range pairs {
+			keys[key] = struct{}{}
+		}
+	}
+
+	// work out the changes needed to head A
+	changes := make(map[string]string)
+	for key := range keys {
+		cidO, cidA, cidB := pairsO[key], pairsA[key], pairsB[key]
+		switch {
+
+		// unchanged in head B, or changed the same way in both heads
+		case cidB == cidO, cidA == cidB:
+			continue
+
+		// only changed in head B (checking the signature of the new version)
+		case cidA == cidO:
+			if len(cidB) != 0 {
+				record, err := starkdb.getEncryptedRecord(cidB)
+				if err != nil {
+					return nil, err
+				}
+				if err := starkdb.verifyAuthor(record); err != nil {
+					resp.Conflicts = append(resp.Conflicts, &MergeConflict{
+						Key:         key,
+						CidAncestor: cidO,
+						CidA:        cidA,
+						CidB:        cidB,
+						Reason:      err.Error(),
+					})
+					continue
+				}
+			}
+			changes[key] = cidB
+
+		// changed in both heads
+		default:
+			resolved, reason, err := starkdb.resolveMerge(cidA, cidB)
+			if err != nil {
+				return nil, err
+			}
+			if len(reason) != 0 {
+				resp.Conflicts = append(resp.Conflicts, &MergeConflict{
+					Key:         key,
+					CidAncestor: cidO,
+					CidA:        cidA,
+					CidB:        cidB,
+					Reason:      reason,
+				})
+				continue
+			}
+			if resolved != cidA {
+				changes[key] = resolved
+			}
+		}
+	}
+	sort.Slice(resp.Conflicts, func(i, j int) bool {
+		return resp.Conflicts[i].GetKey() < resp.Conflicts[j].GetKey()
+	})
+
+	// apply the changes to head A to create the merged snapshot,
+	// removing the deleted keys and then adding the new links
+	// in a single update
+	merged := headA
+	links := make(map[string]string, len(changes))
+	for key, cid := range changes {
+		if len(cid) != 0 {
+			links[key] = cid
+			continue
+		}
+		if merged, err = starkdb.backend.RmLink(starkdb.ctx, merged, key); err != nil {
+			return nil, errors.Wrap(err, ErrSnapshotUpdate.Error())
+		}
+	}
+	merged, err = starkdb.linkSnapshot(starkdb.ctx, headA, merged, links, headB)
+	if err != nil {
+		return nil, errors.Wrap(err, ErrSnapshotUpdate.Error())
+	}
+	resp.Success = len(resp.Conflicts) == 0
+	resp.SnapshotCID = merged
+	starkdb.send2log(fmt.Sprintf("snapshots merged: %v+%v->%v (%d changes, %d conflicts)", headA, headB, merged, len(changes), len(resp.Conflicts)))
+	return resp, nil
+}
+
+// applyMerge is a helper method that updates the
+// database to a merged snapshot and clears any pending
+// snapshot announcements for the merged head B.
+//
+// If the database is pinning, the Records adopted from
+// head B are pinned before the database is updated.
+//
+// Note: the caller must hold the database lock.
+func (starkdb *Db) applyMerge(mergedCID, headB string) error {
+	pairs, err := starkdb.getSnapshotPairs(mergedCID)
+	if err != nil {
+		return err
+	}
+	changed := 0
+	for key, cid := range pairs {
+		if starkdb.cidLookup[key] == cid {
+			continue
+		}
+		if starkdb.pinning {
+			if err := starkdb.backend.Pin(starkdb.ctx, cid, false); err != nil {
+				return err
+			}
+		}
+		changed++
+	}
+	for key := range starkdb.cidLookup {
+		if _, ok := pairs[key]; !ok {
+			changed++
+		}
+	}
+	starkdb.snapshotCID = mergedCID
+	starkdb.cidLookup = pairs
+	starkdb.currentNumEntries = len(pairs)
+	for sender, cid := range starkdb.pendingSnapshots {
+		if cid == headB {
+			delete(starkdb.pendingSnapshots, sender)
+		}
+	}
+	return starkdb.runSessionIntervals(changed)
+}
+
+// findCommonAncestor is a helper method that returns
+// the most recent snapshot in the lineage of head B
+// which is also in the lineage of head A.
+func (starkdb *Db) findCommonAncestor(headA, headB string) (string, error) {
+	lineageA := make(map[string]struct{})
+	iter := starkdb.newSnapshotIterator(headA)
+	for iter.Next() {
+		lineageA[iter.Snapshot().CID] = struct{}{}
+	}
+	if err := iter.Err(); err != nil {
+		return "", err
+	}
+	iter = starkdb.newSnapshotIterator(headB)
+	for iter.Next() {
+		if _, ok := lineageA[iter.Snapshot().CID]; ok {
+			return iter.Snapshot().CID, nil
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return "", err
+	}
+	return "", ErrNoCommonAncestor
+}
+
+// resolveMerge is a helper method that decides which
+// of two Record CIDs to keep when a key was changed in
+// both merge heads.
+//
+// It returns the CID to keep, or the reason the
+// versions could not be resolved.
+func (starkdb *Db) resolveMerge(cidA, cidB string) (string, string, error) {
+	if len(cidA) == 0 || len(cidB) == 0 {
+		return "", "Record was deleted in one head and changed in the other", nil
+	}
+
+	// collect the Records
+	recordA, err := starkdb.getStoredRecord(cidA)
+	if err != nil {
+		return "", "", err
+	}
+	recordB, err := starkdb.getIncomingRecord(cidB)
+	if isAuthorError(err) {
+		return "", err.Error(), nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	// check UUIDs
+	if recordA.GetUuid() != recordB.GetUuid() {
+		return "", ErrAttemptedOverwrite.Error(), nil
+	}
+
+	// check if one version descends from the other and is more recent
+	for _, pair := range [][2]string{{cidA, cidB}, {cidB, cidA}} {
+		descends, err := starkdb.isDescendant(pair[1], pair[0])
+		if err != nil {
+			return "", "", err
+		}
+		if !descends {
+			continue
+		}
+		older, newer := recordA, recordB
+		if pair[1] == cidA {
+			older, newer = recordB, recordA
+		}
+		if !starkhelpers.CheckTimeStamp(older.GetLastUpdatedTimestamp(), newer.GetLastUpdatedTimestamp()) {
+			return "", ErrAttemptedUpdate.Error(), nil
+		}
+		return pair[1], "", nil
+	}
+	return "", ErrRecordHistory.Error(), nil
+}
+
+// isDescendant is a helper method that returns true if
+// the ancestor CID is found by following the previousCID
+// links back through the history of the child Record.
+func (starkdb *Db) isDescendant(childCID, ancestorCID string) (bool, error) {
+	cid := childCID
+	for len(cid) != 0 {
+		record, err := starkdb.getEncryptedRecord(cid)
+		if err != nil {
+			return false, err
+		}
+		cid = record.GetPreviousCID()
+		if cid == ancestorCID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,217 @@
+package stark
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	merkle "github.com/ipfs/go-merkledag"
+	starkshard "github.com/will-rowe/stark/src/shard"
+)
+
+// Mirror will check the mirror source for a new head
+// snapshot and, if there is one, pin every Record in
+// it (along with previous versions of the Records and
+// any linked content) before updating the database to
+// the new snapshot.
+//
+// This is done automatically when the WithMirror option
+// is used, but can also be called directly.
+//
+// It returns the mirrored snapshot CID.
+func (starkdb *Db) Mirror(ctx context.Context) (string, error) {
+	if len(starkdb.mirrorSource) == 0 {
+		return "", ErrNoMirror
+	}
+	starkdb.mirrorLock.Lock()
+	defer starkdb.mirrorLock.Unlock()
+
+	// get the head snapshot of the source
+	head := starkdb.mirrorSource
+	if strings.HasPrefix(head, "/ipns/") {
+		resolveCtx, cancel := context.WithTimeout(ctx, DefaultIPNSTimeout)
+		resolved, err := starkdb.backend.ResolveName(resolveCtx, head)
+		cancel()
+		if err != nil {
+			return "", err
+		}
+		head = resolved
+	}
+	if _, ok := starkdb.mirrored[head]; ok {
+		return head, nil
+	}
+
+	// find the key derivation parameters in the source lineage
+	if len(starkdb.password) != 0 {
+		if err := starkdb.findProjectMetas(head); err != nil {
+			return "", err
+		}
+	}
+
+	// pin each Record
+	pairs, err := starkdb.getSnapshotPairs(head)
+	if err != nil {
+		return "", err
+	}
+	for key, recordCID := range pairs {
+		if err := starkdb.pinRecord(ctx, recordCID); err != nil {
+			return "", fmt.Errorf("could not mirror %v: %w", key, err)
+		}
+	}
+
+	// pin the snapshot shards and update the database
+	if err := starkdb.pinSnapshot(ctx, head); err != nil {
+		return "", err
+	}
+	starkdb.mirrored[head] = struct{}{}
+	starkdb.Lock()
+	starkdb.snapshotCID = head
+	starkdb.cidLookup = pairs
+	starkdb.currentNumEntries = len(pairs)
+	starkdb.Unlock()
+	starkdb.send2log(fmt.Sprintf("mirrored snapshot: %v (%d records)", head, len(pairs)))
+	return head, nil
+}
+
+// pinSnapshot is a helper method that pins the nodes
+// of a sharded snapshot directory, along with the
+// project metadata linked to the snapshot. The nodes
+// are not pinned recursively, as that would also pin
+// the whole snapshot lineage.
+func (starkdb *Db) pinSnapshot(ctx context.Context, snapshotCID string) error {
+	if err := starkdb.backend.Pin(ctx, snapshotCID, false); err != nil {
+		return err
+	}
+	node, err := starkdb.backend.DagGet(ctx, snapshotCID)
+	if err != nil {
+		return err
+	}
+	protoNode, ok := node.(*merkle.ProtoNode)
+	if !ok {
+		return fmt.Errorf("%v: %v", ErrInvalidSnapshot, snapshotCID)
+	}
+	for _, link := range protoNode.Links() {
+		if link.Name != ProjectMetaLink {
+			continue
+		}
+		if err := starkdb.pinProjectMeta(ctx, link.Cid.String()); err != nil {
+			return err
+		}
+	}
+	shards, err := starkshard.ChildShards(protoNode)
+	if err != nil {
+		return err
+	}
+	for _, shard := range shards {
+		if err := starkdb.pinSnapshot(ctx, shard.Cid.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pinRecord is a helper method that pins a Record and
+// any content linked to it, then follows the previousCID
+// links back through the Record history and does the
+// same for each previous version, until it reaches a
+// version that has already been mirrored.
+//
+// The Records are pinned as stored, so a mirror doesn't
+// need the project password. The project metadata
+// needed to derive the key for each encrypted version is
+// also pinned, but the linked content of an encrypted
+// version is only pinned if the mirror can decrypt it.
+func (starkdb *Db) pinRecord(ctx context.Context, recordCID string) error {
+	for len(recordCID) != 0 {
+		if _, ok := starkdb.mirrored[recordCID]; ok {
+			return nil
+		}
+		if err := starkdb.backend.Pin(ctx, recordCID, true); err != nil {
+			return err
+		}
+		record, err := starkdb.getEncryptedRecord(recordCID)
+		if err != nil {
+			return err
+		}
+		if err := starkdb.verifyAuthor(record); err != nil {
+			return err
+		}
+		previousCID := record.GetPreviousCID()
+		if len(record.GetKdfID()) != 0 {
+			if err := starkdb.pinProjectMeta(ctx, record.GetKdfID()); err != nil {
+				return err
+			}
+		}
+		if record.GetEncrypted() {
+			if err := starkdb.decryptRecord(record); err != nil {
+				starkdb.send2log(fmt.Sprintf("mirror could not pin linked content for encrypted record %v: %v", recordCID, err))
+			}
+		}
+
+		// pin the linked content, skipping any links which aren't CIDs
+		for _, links := range []map[string]string{record.GetLinkedSamples(), record.GetLinkedLibraries()} {
+			for _, link := range links {
+				if _, err := cid.Decode(strings.TrimPrefix(link, "/ipfs/")); err != nil {
+					continue
+				}
+				if err := starkdb.backend.Pin(ctx, link, true); err != nil {
+					starkdb.send2log(fmt.Sprintf("mirror could not pin linked content %v: %v", link, err))
+				}
+			}
+		}
+		starkdb.mirrored[recordCID] = struct{}{}
+		recordCID = previousCID
+	}
+	return nil
+}
+
+// pinProjectMeta is a helper method that pins the
+// project metadata (e.g. the key derivation parameters)
+// with the provided CID, if it hasn't been mirrored.
+func (starkdb *Db) pinProjectMeta(ctx context.Context, metaCID string) error {
+	if _, ok := starkdb.mirrored[metaCID]; ok {
+		return nil
+	}
+	if err := starkdb.backend.Pin(ctx, metaCID, false); err != nil {
+		return err
+	}
+	starkdb.mirrored[metaCID] = struct{}{}
+	return nil
+}
+
+// startMirror will check the mirror source for a new
+// head snapshot straight away and then every
+// DefaultMirrorInterval, until the database is closed.
+func (starkdb *Db) startMirror() {
+	ctx, cancel := context.WithCancel(starkdb.ctx)
+	starkdb.mirrorCancel = cancel
+	starkdb.mirrorDone = make(chan struct{})
+	go func() {
+		defer close(starkdb.mirrorDone)
+		ticker := time.NewTicker(DefaultMirrorInterval)
+		defer ticker.Stop()
+		for {
+			if _, err := starkdb.Mirror(ctx); err != nil && ctx.Err() == nil {
+				starkdb.send2log(fmt.Sprintf("mirror error: %v", err))
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// stopMirror will stop the mirror, cancelling any
+// snapshot being mirrored.
+func (starkdb *Db) stopMirror() {
+	if starkdb.mirrorCancel == nil {
+		return
+	}
+	starkdb.mirrorCancel()
+	<-starkdb.mirrorDone
+	starkdb.mirrorCancel = nil
+}
@@ -0,0 +1,185 @@
+package stark
+
+import (
+	"context"
+	"fmt"
+
+	starkcrypto "github.com/will-rowe/stark/src/crypto"
+)
+
+// RecipientKey returns the recipient key for the
+// database. It is an X25519 public key (base64
+// encoded) which is derived from the identity of the
+// IPFS node, so it stays the same each time the
+// database is opened with the same IPFS repo.
+//
+// Give the recipient key to the owners of Records you
+// need to read, so they can share them with the
+// database using AddRecipient.
+func (starkdb *Db) RecipientKey() (string, error) {
+	_, recipientKey, err := starkdb.getRecipientKeys()
+	return recipientKey, err
+}
+
+// AddRecipient will share a Record with the holder of
+// the provided recipient key (see RecipientKey).
+//
+// Records with recipients are encrypted with a random
+// data key instead of the database password. The data
+// key is wrapped for each recipient (and the database
+// itself), so the recipients can decrypt the Record
+// without knowing the database password or being able
+// to decrypt any other Records.
+//
+// The Record is added to the IPFS as a new version
+// with a comment in its history and any Set of the
+// Record keeps its recipients.
+//
+// It will return a response, which contains a copy of
+// the updated Record.
+func (starkdb *Db) AddRecipient(ctx context.Context, key, recipientKey string) (*Response, error) {
+	if err := starkcrypto.RecipientKeyCheck(recipientKey); err != nil {
+		return nil, err
+	}
+	return starkdb.updateRecipients(ctx, key, func(record *Record) error {
+		if _, ok := record.GetRecipients()[recipientKey]; ok {
+			return ErrRecipientExists
+		}
+		if record.Recipients == nil {
+			record.Recipients = make(map[string]string)
+		}
+		record.Recipients[recipientKey] = ""
+		record.AddComment("AddRecipient: record shared with new recipient.")
+		return nil
+	})
+}
+
+// RemoveRecipient will stop sharing a Record with
+// the holder of the provided recipient key.
+//
+// The Record is encrypted with a new data key, which
+// is wrapped for the remaining recipients, and added
+// to the IPFS as a new version.
+//
+// Note: the removed recipient can still decrypt the
+// previous versions of the Record.
+func (starkdb *Db) RemoveRecipient(ctx context.Context, key, recipientKey string) (*Response, error) {
+	return starkdb.updateRecipients(ctx, key, func(record *Record) error {
+		if _, ok := record.GetRecipients()[recipientKey]; !ok {
+			return ErrRecipientNotFound
+		}
+		if _, localKey, err := starkdb.getRecipientKeys(); err == nil && localKey == recipientKey {
+			return ErrRecipientLocal
+		}
+		delete(record.Recipients, recipientKey)
+		record.AddComment("RemoveRecipient: record no longer shared with recipient.")
+		return nil
+	})
+}
+
+// updateRecipients is a helper method that collects
+// the current version of a Record, updates it with
+// the provided function and commits the new version.
+func (starkdb *Db) updateRecipients(ctx context.Context, key string, update func(*Record) error) (*Response, error) {
+	starkdb.Lock()
+	defer starkdb.Unlock()
+	if len(key) == 0 {
+		return nil, ErrNoKey
+	}
+	if starkdb.readOnly {
+		return nil, ErrReadOnly
+	}
+	cid, ok := starkdb.cidLookup[key]
+	if !ok {
+		return nil, ErrNotFound(key)
+	}
+	record, err := starkdb.getStoredRecord(cid)
+	if err != nil {
+		return nil, err
+	}
+	record.PreviousCID = cid
+	if err := update(record); err != nil {
+		return nil, err
+	}
+	newCID, err := starkdb.commitRecord(ctx, key, record)
+	if err != nil {
+		return nil, err
+	}
+	starkdb.send2log(fmt.Sprintf("record recipients updated: %v->%v", key, newCID))
+	if err := starkdb.runSessionIntervals(1); err != nil {
+		return nil, err
+	}
+	record.PreviousCID = newCID
+	return &Response{Success: true, Record: record}, nil
+}
+
+// encryptForRecipients is a helper method that encrypts
+// a Record with a new data key and wraps the data key
+// for each of the Record's recipients. The database is
+// added as a recipient, so it can still decrypt the
+// Record.
+func (starkdb *Db) encryptForRecipients(record *Record) error {
+	if _, localKey, err := starkdb.getRecipientKeys(); err == nil {
+		record.Recipients[localKey] = ""
+	} else {
+		starkdb.send2log(fmt.Sprintf("database is not a recipient of the Record it is encrypting: %v", err))
+	}
+	dataKey, err := starkcrypto.NewDataKey()
+	if err != nil {
+		return err
+	}
+	for recipientKey := range record.Recipients {
+		wrappedKey, err := starkcrypto.WrapKey(dataKey, recipientKey)
+		if err != nil {
+			return fmt.Errorf("%w (%v)", err, recipientKey)
+		}
+		record.Recipients[recipientKey] = wrappedKey
+	}
+	if err := record.Encrypt(dataKey, starkdb.clearFields...); err != nil {
+		return err
+	}
+	record.KdfID = ""
+	return nil
+}
+
+// decryptAsRecipient is a helper method that decrypts
+// a Record using the data key wrapped for the database.
+func (starkdb *Db) decryptAsRecipient(record *Record) error {
+	privateKey, recipientKey, err := starkdb.getRecipientKeys()
+	if err != nil {
+		return err
+	}
+	wrappedKey, ok := record.GetRecipients()[recipientKey]
+	if !ok {
+		return ErrNotRecipient
+	}
+	dataKey, err := starkcrypto.UnwrapKey(wrappedKey, privateKey)
+	if err != nil {
+		return err
+	}
+	return record.Decrypt(dataKey)
+}
+
+// getRecipientKeys is a helper method that derives the
+// recipient key pair for the database from the private
+// key of the IPFS node.
+func (starkdb *Db) getRecipientKeys() ([]byte, string, error) {
+	starkdb.keyLock.Lock()
+	defer starkdb.keyLock.Unlock()
+	if len(starkdb.recipientKey) != 0 {
+		return starkdb.recipientPriv, starkdb.recipientKey, nil
+	}
+	privKey, err := starkdb.backend.PrivateKey()
+	if err != nil {
+		return nil, "", err
+	}
+	seed, err := privKey.Raw()
+	if err != nil {
+		return nil, "", err
+	}
+	starkdb.recipientPriv, starkdb.recipientKey, err = starkcrypto.NewRecipientKeys(seed)
+	if err != nil {
+		return nil, "", err
+	}
+	return starkdb.recipientPriv, starkdb.recipientKey, nil
+}
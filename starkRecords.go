@@ -10,6 +10,8 @@ import (
 	"github.com/golang/protobuf/ptypes"
 	"github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/google/uuid"
+	libp2pcrypto "github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
 	starkcrypto "github.com/will-rowe/stark/src/crypto"
 	"google.golang.org/protobuf/proto"
 )
@@ -135,52 +137,210 @@ func (x *Record) GetCreatedTimestamp() *timestamp.Timestamp {
 	return x.GetHistory()[0].Timestamp
 }
 
-// Encrypt will encrypt certain fields of a Record.
+// Encrypt will encrypt the user updatable fields of a
+// Record, including the map fields and the text of the
+// history comments. Any fields named in clearFields (see
+// the Field constants) are left unencrypted.
 //
-// Note: Currently only the Record UUID is encrypted.
-func (x *Record) Encrypt(cipherKey []byte) error {
+// The encrypted fields are sealed together into the
+// Ciphertext field and cleared from the Record, so
+// nothing but the clear fields, the history timestamps
+// and CIDs, and the Record status is left readable.
+func (x *Record) Encrypt(cipherKey []byte, clearFields ...string) error {
 	if x.Encrypted {
 		return ErrEncrypted
 	}
-
-	// encrypt the UUID
-	encField, err := starkcrypto.Encrypt(x.Uuid, cipherKey)
+	clearSet, err := getClearFields(clearFields)
 	if err != nil {
 		return err
 	}
-	x.Uuid = encField
 
-	// TODO: encrypt other fields
+	// move the fields to encrypt into a sealed Record
+	sealed := &Record{}
+	if !clearSet[FieldUUID] {
+		sealed.Uuid, x.Uuid = x.Uuid, ""
+	}
+	if !clearSet[FieldAlias] {
+		sealed.Alias, x.Alias = x.Alias, ""
+	}
+	if !clearSet[FieldDescription] {
+		sealed.Description, x.Description = x.Description, ""
+	}
+	if !clearSet[FieldSequencerOutputDir] {
+		sealed.LocalSequencerOutputDir, x.LocalSequencerOutputDir = x.LocalSequencerOutputDir, ""
+	}
+	if !clearSet[FieldLinkedSamples] {
+		sealed.LinkedSamples, x.LinkedSamples = x.LinkedSamples, nil
+	}
+	if !clearSet[FieldLinkedLibraries] {
+		sealed.LinkedLibraries, x.LinkedLibraries = x.LinkedLibraries, nil
+	}
+	if !clearSet[FieldBarcodes] {
+		sealed.Barcodes, x.Barcodes = x.Barcodes, nil
+	}
+	if !clearSet[FieldHistory] {
+		sealed.History = make([]*RecordComment, len(x.History))
+		for i, comment := range x.History {
+			sealed.History[i] = &RecordComment{Text: comment.GetText()}
+			comment.Text = ""
+		}
+	}
+
+	// encrypt the sealed Record
+	data, err := proto.Marshal(sealed)
+	if err != nil {
+		return err
+	}
+	ciphertext, err := starkcrypto.Encrypt(string(data), cipherKey)
+	if err != nil {
+		return err
+	}
+	x.Ciphertext = ciphertext
 
 	// set the Record to encrypted
 	x.Encrypted = true
 	return nil
 }
 
-// Decrypt will decrypt certain fields of a Record.
-// Unencrypted Records are ignored and errors are
-// reported for unsuccessful decrypts.
+// Decrypt will decrypt the fields of a Record which
+// were encrypted by Encrypt. Unencrypted Records are
+// ignored and errors are reported for unsuccessful
+// decrypts.
+//
+// The cipher key must match the key derivation
+// parameters recorded in the KdfID field (an empty KdfID
+// means the key was derived from an MD5 hash of the
+// password).
 //
-// Note: Currently only the Record UUID is decrypted.
+// Note: Records encrypted by earlier versions of stark
+// only have an encrypted UUID, these are also decrypted.
 func (x *Record) Decrypt(cipherKey []byte) error {
 	if !x.Encrypted {
 		return nil
 	}
 
-	// decrypt the UUID
-	decField, err := starkcrypto.Decrypt(x.Uuid, cipherKey)
+	// decrypt the UUID of older Records
+	if len(x.Ciphertext) == 0 {
+		decField, err := starkcrypto.Decrypt(x.Uuid, cipherKey)
+		if err != nil {
+			return err
+		}
+		x.Uuid = decField
+		x.Encrypted = false
+		return nil
+	}
+
+	// decrypt the sealed Record
+	data, err := starkcrypto.Decrypt(x.Ciphertext, cipherKey)
 	if err != nil {
 		return err
 	}
-	x.Uuid = decField
+	sealed := &Record{}
+	if err := proto.Unmarshal([]byte(data), sealed); err != nil {
+		return err
+	}
 
-	// TODO: decrypt other fields
+	// restore the sealed fields
+	if len(sealed.Uuid) != 0 {
+		x.Uuid = sealed.Uuid
+	}
+	if len(sealed.Alias) != 0 {
+		x.Alias = sealed.Alias
+	}
+	if len(sealed.Description) != 0 {
+		x.Description = sealed.Description
+	}
+	if len(sealed.LocalSequencerOutputDir) != 0 {
+		x.LocalSequencerOutputDir = sealed.LocalSequencerOutputDir
+	}
+	if len(sealed.LinkedSamples) != 0 {
+		x.LinkedSamples = sealed.LinkedSamples
+	}
+	if len(sealed.LinkedLibraries) != 0 {
+		x.LinkedLibraries = sealed.LinkedLibraries
+	}
+	if len(sealed.Barcodes) != 0 {
+		x.Barcodes = sealed.Barcodes
+	}
+	for i, comment := range sealed.History {
+		if i < len(x.History) && len(comment.GetText()) != 0 {
+			x.History[i].Text = comment.GetText()
+		}
+	}
 
 	// set the Record to decrypted
+	x.Ciphertext = ""
+	x.KdfID = ""
 	x.Encrypted = false
 	return nil
 }
 
+// Sign will sign the Record with a private key (e.g.
+// the identity key of an IPFS node) and set the author
+// to the peer ID for the key.
+//
+// The signature covers every other field of the Record,
+// so the Record should be signed as it will be stored
+// (i.e. after it is encrypted).
+func (x *Record) Sign(privKey libp2pcrypto.PrivKey) error {
+	author, err := peer.IDFromPrivateKey(privKey)
+	if err != nil {
+		return err
+	}
+	authorKey, err := libp2pcrypto.MarshalPublicKey(privKey.GetPublic())
+	if err != nil {
+		return err
+	}
+	x.Author = author.Pretty()
+	x.AuthorKey = authorKey
+	x.Signature = nil
+	data, err := x.signedData()
+	if err != nil {
+		return err
+	}
+	x.Signature, err = privKey.Sign(data)
+	return err
+}
+
+// Verify will check the signature of a Record which
+// was signed by Sign and that the author matches the
+// key used.
+//
+// It returns the peer ID of the author, or ErrUnsigned
+// if the Record has not been signed.
+func (x *Record) Verify() (string, error) {
+	if len(x.Signature) == 0 {
+		if len(x.Author) != 0 || len(x.AuthorKey) != 0 {
+			return "", ErrSignature
+		}
+		return "", ErrUnsigned
+	}
+	pubKey, err := libp2pcrypto.UnmarshalPublicKey(x.AuthorKey)
+	if err != nil {
+		return "", ErrSignature
+	}
+	author, err := peer.IDFromPublicKey(pubKey)
+	if err != nil || author.Pretty() != x.Author {
+		return "", ErrSignature
+	}
+	data, err := x.signedData()
+	if err != nil {
+		return "", err
+	}
+	if ok, err := pubKey.Verify(data, x.Signature); err != nil || !ok {
+		return "", ErrSignature
+	}
+	return x.Author, nil
+}
+
+// signedData returns the bytes of the Record which are
+// covered by its signature.
+func (x *Record) signedData() ([]byte, error) {
+	unsigned := proto.Clone(x).(*Record)
+	unsigned.Signature = nil
+	return proto.MarshalOptions{Deterministic: true}.Marshal(unsigned)
+}
+
 // GetLastUpdatedTimestamp returns the timestamp for when the record was created.
 func (x *Record) GetLastUpdatedTimestamp() *timestamp.Timestamp {
 	histLength := len(x.GetHistory())
@@ -203,3 +363,19 @@ func (x *Record) setDescription(description string) error {
 	}
 	return nil
 }
+
+// getClearFields is a helper function that checks the
+// fields in an encryption policy and returns them as a
+// set.
+func getClearFields(fields []string) (map[string]bool, error) {
+	clearSet := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		switch field {
+		case FieldUUID, FieldAlias, FieldDescription, FieldSequencerOutputDir, FieldLinkedSamples, FieldLinkedLibraries, FieldBarcodes, FieldHistory:
+			clearSet[field] = true
+		default:
+			return nil, fmt.Errorf("%v: %v", ErrRecordField, field)
+		}
+	}
+	return clearSet, nil
+}
@@ -1,13 +1,17 @@
 package stark
 
 import (
+	"crypto/rand"
 	"strings"
 	"testing"
 
+	"github.com/google/uuid"
+	libp2pcrypto "github.com/libp2p/go-libp2p-core/crypto"
 	starkcrypto "github.com/will-rowe/stark/src/crypto"
+	"google.golang.org/protobuf/proto"
 )
 
-// TestRecord tests the record constructor and the UUID field encryption.
+// TestRecord tests the record constructor and the field encryption.
 func TestRecord(t *testing.T) {
 
 	// construct a record
@@ -27,19 +31,98 @@ func TestRecord(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	// encrypt
-	if err := rec.Encrypt(cipherKey); err != nil {
+	// encrypt, leaving the alias clear
+	if err := rec.Encrypt(cipherKey, "not a field"); err == nil {
+		t.Fatal("encrypted a record with an unknown clear field")
+	}
+	if err := rec.LinkSample(uuid.New(), "sample location"); err != nil {
+		t.Fatal(err)
+	}
+	original := proto.Clone(rec).(*Record)
+	if err := rec.Encrypt(cipherKey, FieldAlias); err != nil {
 		t.Fatal(err)
 	}
-	if rec.GetUuid() == originalUUID {
-		t.Fatal("record UUID field was not encrypted")
+	if rec.GetUuid() == originalUUID || len(rec.GetDescription()) != 0 || len(rec.GetLinkedSamples()) != 0 {
+		t.Fatal("record fields were not encrypted")
+	}
+	for _, comment := range rec.GetHistory() {
+		if len(comment.GetText()) != 0 {
+			t.Fatal("record history was not encrypted")
+		}
+	}
+	if rec.GetAlias() != original.GetAlias() {
+		t.Fatal("clear field was encrypted")
 	}
 
 	// decrypt
 	if err := rec.Decrypt(cipherKey); err != nil {
 		t.Fatal(err)
 	}
-	if rec.GetUuid() != originalUUID {
+	if !proto.Equal(rec, original) {
+		t.Fatal("decrypted record does not match the original")
+	}
+
+	// check records with only an encrypted UUID can be decrypted
+	encUUID, err := starkcrypto.Encrypt(originalUUID, cipherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := &Record{Uuid: encUUID, Encrypted: true}
+	if err := legacy.Decrypt(cipherKey); err != nil {
+		t.Fatal(err)
+	}
+	if legacy.GetUuid() != originalUUID {
 		t.Fatal("record UUID field was not decrypted")
 	}
 }
+
+// TestRecordSignature tests signing and verifying a record.
+func TestRecordSignature(t *testing.T) {
+	rec, err := NewRecord(SetAlias("test record"), SetDescription("signed record"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rec.Verify(); err != ErrUnsigned {
+		t.Fatalf("expected %v, got %v", ErrUnsigned, err)
+	}
+
+	// sign and verify
+	privKey, _, err := libp2pcrypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Sign(privKey); err != nil {
+		t.Fatal(err)
+	}
+	author, err := rec.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if author != rec.GetAuthor() || len(author) == 0 {
+		t.Fatalf("unexpected author: %v", author)
+	}
+
+	// check changes to the record or author are detected
+	tampered := proto.Clone(rec).(*Record)
+	tampered.Description = "tampered record"
+	if _, err := tampered.Verify(); err != ErrSignature {
+		t.Fatalf("expected %v, got %v", ErrSignature, err)
+	}
+	otherKey, _, err := libp2pcrypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := proto.Clone(rec).(*Record)
+	if err := other.Sign(otherKey); err != nil {
+		t.Fatal(err)
+	}
+	tampered = proto.Clone(rec).(*Record)
+	tampered.Author = other.GetAuthor()
+	if _, err := tampered.Verify(); err != ErrSignature {
+		t.Fatalf("expected %v, got %v", ErrSignature, err)
+	}
+	tampered.Signature = nil
+	if _, err := tampered.Verify(); err != ErrSignature {
+		t.Fatalf("expected %v, got %v", ErrSignature, err)
+	}
+}
@@ -0,0 +1,229 @@
+package stark
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	starkcrypto "github.com/will-rowe/stark/src/crypto"
+)
+
+// RotateReport summarises a cipher key rotation.
+type RotateReport struct {
+	Rekeyed int // the number of Records re-encrypted during this call
+	Resumed int // the number of Records already re-encrypted by an interrupted rotation
+	Skipped int // the number of Records not encrypted with the password (unencrypted or shared with recipients)
+}
+
+// RotateKey will change the password used to encrypt
+// the Records in the current snapshot.
+//
+// New key derivation parameters (with a new salt) are
+// linked to the snapshot and every encrypted Record is
+// decrypted using the old password, given a history
+// comment and re-encrypted under the new password. The
+// new Record versions are linked to the snapshot in
+// batches (see DefaultRotateBatchSize) and progress is
+// reported via the logger (see WithLogging).
+//
+// If the rotation is interrupted (e.g. by cancelling
+// the context), the snapshots taken so far are kept and
+// calling RotateKey again with the same passwords will
+// resume it, skipping the Records already re-encrypted.
+// Until the rotation is finished, writes which encrypt
+// Records with the database password return
+// ErrRotationUnfinished (including after the database
+// is reopened with either password).
+//
+// If the database is announcing, the re-encrypted
+// Records are announced as they are linked.
+//
+// Once finished, the database uses the new password for
+// any further writes. The keys for the old password are
+// kept for the session, but once the database is
+// reopened with the new password, previous Record
+// versions encrypted with the old password can't be
+// decrypted: GetHistory returns them encrypted and
+// Rollback to them needs the old password.
+func (starkdb *Db) RotateKey(ctx context.Context, oldPassword, newPassword string) (*RotateReport, error) {
+	starkdb.Lock()
+	defer starkdb.Unlock()
+	if starkdb.readOnly {
+		return nil, ErrReadOnly
+	}
+	if len(oldPassword) == 0 || len(newPassword) == 0 {
+		return nil, starkcrypto.ErrCipherPassword
+	}
+
+	// get the key derivation parameters for the new key, resuming an unfinished rotation if there is one
+	metaCID, err := starkdb.getProjectMetaCID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var meta *projectMeta
+	if len(metaCID) != 0 {
+		if meta, err = starkdb.getProjectMeta(metaCID); err != nil {
+			return nil, err
+		}
+	}
+	if meta != nil && meta.Rotating {
+		starkdb.send2log(fmt.Sprintf("resuming cipher key rotation: %v", metaCID))
+	} else {
+		params, err := starkcrypto.NewKDFParams()
+		if err != nil {
+			return nil, err
+		}
+		meta = &projectMeta{Project: starkdb.project, KDF: params, Rotating: true}
+		if metaCID, err = starkdb.linkProjectMeta(ctx, meta, false); err != nil {
+			return nil, err
+		}
+		starkdb.send2log(fmt.Sprintf("started cipher key rotation: %v", metaCID))
+	}
+	starkdb.rotating = true
+	newKey, err := starkcrypto.DeriveCipherKey(newPassword, meta.KDF)
+	if err != nil {
+		return nil, err
+	}
+
+	// re-encrypt the Records in batches, taking a snapshot after each one
+	report := &RotateReport{}
+	oldKeys := make(map[string][]byte)
+	keys := make([]string, 0, len(starkdb.cidLookup))
+	for key := range starkdb.cidLookup {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	batchKeys := []string{}
+	batchRecords := []*Record{}
+	batchData := [][]byte{}
+
+	// the batches are written using the database context so that an interrupted rotation keeps its progress
+	flush := func() error {
+		if len(batchKeys) == 0 {
+			return nil
+		}
+		cids, err := starkdb.backend.DagPutBatch(starkdb.ctx, batchData, starkdb.pinning)
+		if err != nil {
+			return err
+		}
+		links := make(map[string]string, len(batchKeys))
+		for i, key := range batchKeys {
+			links[key] = cids[i]
+		}
+		if err := starkdb.linkRecords(starkdb.ctx, links); err != nil {
+			return err
+		}
+		report.Rekeyed += len(batchKeys)
+		starkdb.send2log(fmt.Sprintf("re-encrypted %d/%d records->%v", report.Rekeyed+report.Resumed+report.Skipped, len(keys), starkdb.snapshotCID))
+
+		// if announcing, do it now (the batch is already linked so failures are only logged)
+		if starkdb.announcing {
+			for i, key := range batchKeys {
+				if err := starkdb.publishAnnouncement(starkdb.newAnnouncement(Announcement_SET, key, cids[i], batchRecords[i])); err != nil {
+					starkdb.send2log(fmt.Sprintf("could not announce %v: %v", key, err))
+				}
+			}
+		}
+		numAdded := len(batchKeys)
+		batchKeys, batchRecords, batchData = batchKeys[:0], batchRecords[:0], batchData[:0]
+		return starkdb.runSessionIntervals(numAdded)
+	}
+	rekey := func(key string) error {
+		cid := starkdb.cidLookup[key]
+		record, err := starkdb.getEncryptedRecord(cid)
+		if err != nil {
+			return err
+		}
+
+		// skip Records which don't need re-encrypting
+		if !record.GetEncrypted() || len(record.GetRecipients()) != 0 {
+			report.Skipped++
+			return nil
+		}
+		if record.GetKdfID() == metaCID {
+			if err := record.Decrypt(newKey); err != nil {
+				return errors.Wrapf(err, "%v (%v)", ErrCipherPasswordMismatch, key)
+			}
+			report.Resumed++
+			return nil
+		}
+
+		// decrypt with the old key and re-encrypt with the new one
+		oldKey, ok := oldKeys[record.GetKdfID()]
+		if !ok {
+			if oldKey, err = starkdb.deriveCipherKey(oldPassword, record.GetKdfID()); err != nil {
+				return err
+			}
+			oldKeys[record.GetKdfID()] = oldKey
+		}
+		if err := record.Decrypt(oldKey); err != nil {
+			return errors.Wrapf(err, "%v (%v)", ErrCipherPasswordMismatch, key)
+		}
+		record.PreviousCID = cid
+		record.AddComment("RotateKey: record re-encrypted with new cipher key.")
+		if err := record.Encrypt(newKey, starkdb.clearFields...); err != nil {
+			return err
+		}
+		record.KdfID = metaCID
+		if err := starkdb.signRecord(record); err != nil {
+			return err
+		}
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		batchKeys = append(batchKeys, key)
+		batchRecords = append(batchRecords, record)
+		batchData = append(batchData, data)
+		return nil
+	}
+	for _, key := range keys {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		if err = rekey(key); err != nil {
+			break
+		}
+		if len(batchKeys) == DefaultRotateBatchSize {
+			if err := flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	// link any re-encrypted Records before returning an error, so the rotation can be resumed
+	if flushErr := flush(); flushErr != nil {
+		return report, flushErr
+	}
+	if err != nil {
+		return report, err
+	}
+
+	// finish the rotation and switch the database to the new key
+	finalCID, err := starkdb.linkProjectMeta(ctx, &projectMeta{Project: starkdb.project, KDF: meta.KDF}, false)
+	if err != nil {
+		return report, err
+	}
+	legacyKey, err := starkcrypto.Password2cipherkey(oldPassword)
+	if err != nil {
+		return report, err
+	}
+
+	// keep the old keys so that previous Record versions can still be decrypted this session
+	starkdb.keyLock.Lock()
+	starkdb.password = newPassword
+	starkdb.cipherKey = newKey
+	starkdb.kdfID = finalCID
+	starkdb.rotating = false
+	for kdfID, oldKey := range oldKeys {
+		starkdb.cipherKeys[kdfID] = oldKey
+	}
+	starkdb.cipherKeys[""] = legacyKey
+	starkdb.cipherKeys[metaCID] = newKey
+	starkdb.cipherKeys[finalCID] = newKey
+	starkdb.keyLock.Unlock()
+	starkdb.send2log(fmt.Sprintf("finished cipher key rotation: %d records re-encrypted->%v", report.Rekeyed, starkdb.snapshotCID))
+	return report, nil
+}
@@ -0,0 +1,74 @@
+package stark
+
+import (
+	"fmt"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/pkg/errors"
+)
+
+// signRecord is a helper method that signs a Record
+// with the private key of the database's node, so
+// that the Record can be verified by other databases.
+//
+// If the private key isn't available, the Record is
+// left unsigned (and a warning is logged) unless the
+// database requires signed Records, in which case an
+// error is returned. The private key is never available
+// when attached to an IPFS daemon via its HTTP API (see
+// WithIPFSAPI), so those databases can't sign Records.
+//
+// Note: the Record must be signed after it has been
+// encrypted and must not be changed before it is stored.
+func (starkdb *Db) signRecord(record *Record) error {
+	privKey, err := starkdb.backend.PrivateKey()
+	if err != nil {
+		if starkdb.requireSigned {
+			return errors.Wrap(err, ErrUnsigned.Error())
+		}
+		starkdb.send2log(fmt.Sprintf("storing unsigned record, no private key available: %v", err))
+		record.Author, record.AuthorKey, record.Signature = "", nil, nil
+		return nil
+	}
+	return record.Sign(privKey)
+}
+
+// verifyAuthor is a helper method that checks the
+// signature of a stored Record and that its author
+// is trusted by the database (see WithRequireSigned).
+//
+// It is used for Records arriving from other databases
+// (see getIncomingRecord and the merge and mirror
+// methods) and for Records returned to the caller by
+// Get and Listen. Records read internally (e.g. to check
+// an update or to roll back) are not checked.
+//
+// Records with an invalid signature are always
+// rejected. Unsigned Records are only rejected if the
+// database requires signed Records.
+func (starkdb *Db) verifyAuthor(record *Record) error {
+	author, err := record.Verify()
+	if err == ErrUnsigned && !starkdb.requireSigned {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(starkdb.trustedAuthors) == 0 || starkdb.trustedAuthors[author] {
+		return nil
+	}
+
+	// Records signed by this node are always trusted
+	if privKey, err := starkdb.backend.PrivateKey(); err == nil {
+		if self, err := peer.IDFromPrivateKey(privKey); err == nil && self.Pretty() == author {
+			return nil
+		}
+	}
+	return ErrUntrustedAuthor
+}
+
+// isAuthorError returns true if the error was issued
+// by verifyAuthor.
+func isAuthorError(err error) bool {
+	return err == ErrSignature || err == ErrUnsigned || err == ErrUntrustedAuthor
+}
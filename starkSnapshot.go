@@ -0,0 +1,237 @@
+package stark
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	cbor "github.com/ipfs/go-ipld-cbor"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// Snapshot describes a single project snapshot
+// in the lineage of a starkDB.
+type Snapshot struct {
+	CID         string    // the CID of the snapshot node
+	Parent      string    // the CID of the parent snapshot (empty if this is the first snapshot)
+	Merged      string    // the CID of the snapshot which was merged into the parent (empty if not a merge)
+	Project     string    // the project which the snapshot belongs to
+	Timestamp   time.Time // when the snapshot was taken (zero if the snapshot has no metadata)
+	NumEntries  int       // the number of Records linked to the snapshot
+	ProjectMeta string    // the CID of the project metadata linked to the snapshot (empty if there is none)
+}
+
+// SnapshotIterator steps back through the lineage of
+// project snapshots, starting with the most recent.
+// For merged snapshots, the iterator follows the
+// parent snapshot (head A of the merge).
+//
+// Use Next to advance the iterator and Snapshot to get
+// the current snapshot. Once Next returns false, Err
+// will report any error encountered.
+type SnapshotIterator struct {
+	starkdb *Db
+	next    string
+	current *Snapshot
+	err     error
+}
+
+// snapshotMeta is the metadata linked to each
+// snapshot node.
+type snapshotMeta struct {
+	Project   string    `json:"project"`
+	Timestamp time.Time `json:"timestamp"`
+	Merged    string    `json:"merged,omitempty"`
+}
+
+// Snapshots returns an iterator over the current
+// database snapshot and all of its ancestors.
+func (starkdb *Db) Snapshots() *SnapshotIterator {
+	starkdb.Lock()
+	defer starkdb.Unlock()
+	return starkdb.newSnapshotIterator(starkdb.snapshotCID)
+}
+
+// newSnapshotIterator returns an iterator over the
+// provided snapshot and all of its ancestors.
+func (starkdb *Db) newSnapshotIterator(snapshotCID string) *SnapshotIterator {
+	return &SnapshotIterator{
+		starkdb: starkdb,
+		next:    snapshotCID,
+	}
+}
+
+// Next will advance the iterator to the parent of the
+// current snapshot. It returns false when there are no
+// more snapshots or an error was encountered.
+func (iter *SnapshotIterator) Next() bool {
+	if iter.err != nil || len(iter.next) == 0 {
+		iter.current = nil
+		return false
+	}
+	snapshot, err := iter.starkdb.getSnapshot(iter.next)
+	if err != nil {
+		iter.err = err
+		iter.current = nil
+		return false
+	}
+	iter.current = snapshot
+	iter.next = snapshot.Parent
+	return true
+}
+
+// Snapshot returns the current snapshot.
+func (iter *SnapshotIterator) Snapshot() *Snapshot {
+	return iter.current
+}
+
+// Err returns any error encountered by the iterator.
+func (iter *SnapshotIterator) Err() error {
+	return iter.err
+}
+
+// IsReadOnly returns true if the database was opened
+// read-only at a historic snapshot or as a mirror.
+func (starkdb *Db) IsReadOnly() bool {
+	return starkdb.readOnly
+}
+
+// getSnapshot is a helper method that collects a
+// snapshot and its metadata from the IPFS.
+func (starkdb *Db) getSnapshot(cid string) (*Snapshot, error) {
+	snapshot := &Snapshot{CID: cid}
+	var metaCID string
+	if err := starkdb.forEachSnapshotLink(starkdb.ctx, cid, func(link *ipld.Link) error {
+		switch link.Name {
+		case SnapshotParentLink:
+			snapshot.Parent = link.Cid.String()
+		case SnapshotMetaLink:
+			metaCID = link.Cid.String()
+		case ProjectMetaLink:
+			snapshot.ProjectMeta = link.Cid.String()
+		default:
+			snapshot.NumEntries++
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if len(metaCID) != 0 {
+		meta, err := starkdb.getSnapshotMeta(metaCID)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Project = meta.Project
+		snapshot.Timestamp = meta.Timestamp
+		snapshot.Merged = meta.Merged
+	}
+	return snapshot, nil
+}
+
+// getSnapshotMeta is a helper method that collects
+// the metadata for a snapshot from the IPFS.
+func (starkdb *Db) getSnapshotMeta(cid string) (*snapshotMeta, error) {
+	retrievedNode, err := starkdb.backend.DagGet(starkdb.ctx, cid)
+	if err != nil {
+		return nil, err
+	}
+	cborNode, isCborNode := retrievedNode.(*cbor.Node)
+	if !isCborNode {
+		return nil, fmt.Errorf("%v: %v", ErrNodeFormat, cid)
+	}
+	data, err := cborNode.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	meta := &snapshotMeta{}
+	if err := json.Unmarshal(data, meta); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// forEachSnapshotLink is a helper method that calls
+// the provided function for each link from a snapshot
+// node. The project directory is sharded, so the links
+// are paged in from the backend as they are walked.
+func (starkdb *Db) forEachSnapshotLink(ctx context.Context, cid string, f func(*ipld.Link) error) error {
+	return starkdb.backend.ForEachLink(ctx, cid, f)
+}
+
+// loadSnapshotLinks is a helper method that calls the
+// provided function for each link from a snapshot node
+// (see forEachSnapshotLink). There is no limit on the
+// time taken to load every link, so large projects can
+// be fetched over the network, but the load is cancelled
+// if no link arrives within the DefaultShardTimeout.
+func (starkdb *Db) loadSnapshotLinks(cid string, f func(*ipld.Link) error) error {
+	ctx, cancel := context.WithCancel(starkdb.ctx)
+	defer cancel()
+	timer := time.AfterFunc(DefaultShardTimeout, cancel)
+	defer timer.Stop()
+	return starkdb.forEachSnapshotLink(ctx, cid, func(link *ipld.Link) error {
+		timer.Reset(DefaultShardTimeout)
+		return f(link)
+	})
+}
+
+// linkSnapshot is a helper method that creates a new
+// snapshot from a base snapshot node by adding the
+// provided links (using the map keys as the link names),
+// along with the links to the parent snapshot and the
+// snapshot metadata. All the links are added in a single
+// update of the project directory. If the snapshot is
+// the result of a merge, the CID of the merged snapshot
+// head should be given (otherwise an empty string).
+//
+// It returns the CID of the linked snapshot.
+func (starkdb *Db) linkSnapshot(ctx context.Context, parentCID, baseCID string, links map[string]string, mergedCID string) (string, error) {
+
+	// add the metadata
+	meta, err := json.Marshal(&snapshotMeta{
+		Project:   starkdb.project,
+		Timestamp: time.Now().UTC(),
+		Merged:    mergedCID,
+	})
+	if err != nil {
+		return "", err
+	}
+	metaCID, err := starkdb.backend.DagPut(ctx, meta, starkdb.pinning)
+	if err != nil {
+		return "", err
+	}
+
+	// link everything to the base snapshot
+	snapshotLinks := make(map[string]string, len(links)+2)
+	for name, cid := range links {
+		snapshotLinks[name] = cid
+	}
+	snapshotLinks[SnapshotParentLink] = parentCID
+	snapshotLinks[SnapshotMetaLink] = metaCID
+	return starkdb.backend.AddLinks(ctx, baseCID, snapshotLinks)
+}
+
+// findSnapshot is a helper method that steps back
+// through the snapshot lineage from the current
+// snapshot until it finds the most recent snapshot
+// taken at or before the provided time.
+func (starkdb *Db) findSnapshot(asOf time.Time) (string, error) {
+	iter := starkdb.newSnapshotIterator(starkdb.snapshotCID)
+	for iter.Next() {
+		snapshot := iter.Snapshot()
+		if !snapshot.Timestamp.IsZero() && !snapshot.Timestamp.After(asOf) {
+			return snapshot.CID, nil
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return "", err
+	}
+	return "", ErrSnapshotNotFound
+}
+
+// isReservedKey returns true if the key is used
+// for the snapshot lineage or project metadata links.
+func isReservedKey(key string) bool {
+	return key == SnapshotParentLink || key == SnapshotMetaLink || key == ProjectMetaLink
+}
@@ -0,0 +1,555 @@
+package stark
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// SyncReport summarises a sync of Records from the
+// snapshots of other databases on the project.
+type SyncReport struct {
+	Snapshots []string         // the snapshots which were synced from
+	Imported  int              // the number of Records imported (including accepted and forked conflicts)
+	Skipped   int              // the number of Records already held (or deleted by another database)
+	Conflicts []*ConflictEvent // the conflicts which were rejected by the ConflictPolicy
+}
+
+// ApplyAnnouncement will apply a change announced by
+// another database on the project (e.g. via
+// ListenAnnouncements) to the starkDB.
+//
+// SET announcements are applied using ApplyRecord.
+//
+// DELETE announcements remove the Record if the database
+// holds the deleted version. If the database holds a
+// different version, the ConflictPolicy of the database
+// decides if the Record is removed (ConflictAccept) or
+// kept. If the Record has not arrived yet, the delete is
+// queued and the Record will be refused when it does.
+//
+// SNAPSHOT announcements are applied key by key if the
+// announced snapshot shares an ancestor with the database
+// snapshot: the Records changed in the announced snapshot
+// are applied using ApplyRecord and removed Records are
+// deleted as for DELETE announcements. If there is no
+// common ancestor, or any change is refused, the snapshot
+// is queued and can be retrieved with GetPendingSnapshots,
+// ready for a manual Merge.
+//
+// SYNC_REQUEST announcements are answered with a SNAPSHOT
+// announcement if the database is announcing.
+//
+// Announcements sent by this database are ignored.
+func (starkdb *Db) ApplyAnnouncement(ctx context.Context, ann *Announcement) error {
+	if self := starkdb.backend.PrintNodeID(); len(self) != 0 && ann.GetSender() == self {
+		return nil
+	}
+	switch ann.GetType() {
+	case Announcement_SET:
+		record, err := starkdb.getAnnouncedRecord(ann)
+		if err != nil {
+			return err
+		}
+		_, err = starkdb.applyRecord(ctx, ann.GetKey(), record)
+		return err
+	case Announcement_DELETE:
+		return starkdb.applyDelete(ctx, ann)
+	case Announcement_SNAPSHOT:
+		return starkdb.applySnapshot(ctx, ann)
+	case Announcement_SYNC_REQUEST:
+		return starkdb.answerSyncRequest(ann)
+	default:
+		return fmt.Errorf("%v: unknown type %v", ErrAnnouncement, ann.GetType())
+	}
+}
+
+// SyncFromPeers will catch up a database with the other
+// databases on the project. It sends a sync request on the
+// PubSub topic for the project and then, for the provided
+// wait duration, collects the head snapshots announced by
+// project peers and imports any missing Records from them
+// using SyncFromSnapshot.
+//
+// Peers answer sync requests if they are announcing and
+// listening (see WithAnnouncing and WithAutoSync). Head
+// snapshots announced periodically by announcing peers
+// are also used, so a wait duration longer than the
+// DefaultSnapshotInterval will pick up every announcing
+// peer.
+//
+// It returns a report of the sync. If no peers announced
+// a snapshot, the report is empty and ErrNoSyncResponse
+// is returned.
+func (starkdb *Db) SyncFromPeers(ctx context.Context, wait time.Duration) (*SyncReport, error) {
+	if starkdb.readOnly {
+		return nil, ErrReadOnly
+	}
+	if starkdb.syncTerminator != nil {
+		return nil, ErrSyncActive
+	}
+
+	// subscribe to the project and send the sync request
+	terminator := make(chan struct{})
+	anns, errs, err := starkdb.ListenAnnouncements(terminator)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		close(terminator)
+		for anns != nil || errs != nil {
+			select {
+			case _, ok := <-anns:
+				if !ok {
+					anns = nil
+				}
+			case _, ok := <-errs:
+				if !ok {
+					errs = nil
+				}
+			}
+		}
+	}()
+	starkdb.Lock()
+	err = starkdb.publishAnnouncement(starkdb.newAnnouncement(Announcement_SYNC_REQUEST, "", "", nil))
+	starkdb.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	starkdb.send2log(fmt.Sprintf("sync: requested head snapshots on %v", starkdb.project))
+
+	// import from each announced snapshot until the wait is over
+	report := &SyncReport{}
+	seen := make(map[string]struct{})
+	self := starkdb.backend.PrintNodeID()
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		select {
+		case ann := <-anns:
+			if ann.GetType() != Announcement_SNAPSHOT || ann.GetSender() == self {
+				continue
+			}
+			if _, ok := seen[ann.GetSnapshotCID()]; ok {
+				continue
+			}
+			seen[ann.GetSnapshotCID()] = struct{}{}
+			starkdb.send2log(fmt.Sprintf("sync: importing snapshot from %v: %v", ann.GetSender(), ann.GetSnapshotCID()))
+			if err := starkdb.syncSnapshot(ctx, ann.GetSnapshotCID(), report); err != nil {
+				return report, err
+			}
+		case err := <-errs:
+			starkdb.send2log(fmt.Sprintf("sync: %v", err))
+		case <-timer.C:
+			if len(report.Snapshots) == 0 {
+				return report, ErrNoSyncResponse
+			}
+			return report, nil
+		case <-ctx.Done():
+			return report, ctx.Err()
+		}
+	}
+}
+
+// SyncFromSnapshot will import any Records held in the
+// provided snapshot (e.g. the head snapshot of another
+// database on the project) which are missing from the
+// database.
+//
+// Each Record is applied under its snapshot key using the
+// same checks as Set (see ApplyRecord), so conflicting
+// Records are handled by the database ConflictPolicy.
+// Records which are held in the database but not in the
+// provided snapshot are left alone.
+//
+// It returns a report of the sync.
+func (starkdb *Db) SyncFromSnapshot(ctx context.Context, snapshotCID string) (*SyncReport, error) {
+	if starkdb.readOnly {
+		return nil, ErrReadOnly
+	}
+	report := &SyncReport{}
+	return report, starkdb.syncSnapshot(ctx, snapshotCID, report)
+}
+
+// syncSnapshot is a helper method that imports the
+// missing Records from a snapshot and adds the result
+// to the provided report.
+func (starkdb *Db) syncSnapshot(ctx context.Context, snapshotCID string, report *SyncReport) error {
+	if len(snapshotCID) == 0 {
+		return ErrNoCID
+	}
+	pairs, err := starkdb.getSnapshotPairs(snapshotCID)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(pairs))
+	for key := range pairs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// apply each Record that isn't already held
+	starkdb.Lock()
+	held := make(map[string]string, len(starkdb.cidLookup))
+	for key, cid := range starkdb.cidLookup {
+		held[key] = cid
+	}
+	starkdb.Unlock()
+	for _, key := range keys {
+		cid := pairs[key]
+		if held[key] == cid {
+			report.Skipped++
+			continue
+		}
+		record, err := starkdb.getRecordFromCID(cid)
+		if err != nil {
+			return err
+		}
+		_, err = starkdb.applyRecord(ctx, key, record)
+		switch err {
+		case nil:
+			report.Imported++
+		case ErrRecordDeleted:
+			report.Skipped++
+		default:
+			event, isConflict := err.(*ConflictEvent)
+			if !isConflict {
+				return err
+			}
+			report.Conflicts = append(report.Conflicts, event)
+		}
+	}
+	report.Snapshots = append(report.Snapshots, snapshotCID)
+	starkdb.send2log(fmt.Sprintf("sync: snapshot %v synced (%d imported, %d skipped, %d conflicts)", snapshotCID, report.Imported, report.Skipped, len(report.Conflicts)))
+	return nil
+}
+
+// GetPendingSnapshots returns the snapshots announced by
+// other databases which could not be merged automatically.
+// The returned map is a copy, keyed by the sender of each
+// snapshot announcement.
+func (starkdb *Db) GetPendingSnapshots() map[string]string {
+	starkdb.Lock()
+	defer starkdb.Unlock()
+	pending := make(map[string]string, len(starkdb.pendingSnapshots))
+	for sender, cid := range starkdb.pendingSnapshots {
+		pending[sender] = cid
+	}
+	return pending
+}
+
+// applyDelete is a helper method that applies a DELETE
+// announcement to the database.
+func (starkdb *Db) applyDelete(ctx context.Context, ann *Announcement) error {
+	starkdb.Lock()
+	defer starkdb.Unlock()
+
+	// check the key and database
+	key, deletedCID := ann.GetKey(), ann.GetCid()
+	if len(key) == 0 {
+		return ErrNoKey
+	}
+	if len(deletedCID) == 0 {
+		return ErrNoCID
+	}
+	if isReservedKey(key) {
+		return ErrReservedKey
+	}
+	if starkdb.readOnly {
+		return ErrReadOnly
+	}
+
+	// queue the delete if the Record hasn't arrived yet
+	existingCID, exists := starkdb.cidLookup[key]
+	if !exists {
+		starkdb.pendingDeletes[key] = deletedCID
+		starkdb.send2log(fmt.Sprintf("delete queued: %v->%v", key, deletedCID))
+		return nil
+	}
+
+	// remove the Record if the deleted version is held
+	if existingCID == deletedCID {
+		if err := starkdb.removeRecord(ctx, key, existingCID); err != nil {
+			return err
+		}
+		starkdb.send2log(fmt.Sprintf("record deleted by %v: %v->%v", ann.GetSender(), key, existingCID))
+		return starkdb.runSessionIntervals(1)
+	}
+
+	// otherwise use the conflict policy
+	existing, err := starkdb.getRecordFromCID(existingCID)
+	if err != nil {
+		return err
+	}
+	event := &ConflictEvent{
+		Conflict: &Conflict{
+			Key:         key,
+			Reason:      ErrDeleteConflict,
+			ExistingCID: existingCID,
+			IncomingCID: deletedCID,
+			Existing:    existing,
+			Delete:      true,
+			Timestamp:   ann.GetTimestamp(),
+		},
+	}
+	event.Resolution = starkdb.conflictPolicy(event.Conflict)
+	starkdb.send2log(event)
+	if event.Resolution != ConflictAccept {
+		return event
+	}
+	if err := starkdb.removeRecord(ctx, key, existingCID); err != nil {
+		return err
+	}
+	return starkdb.runSessionIntervals(1)
+}
+
+// answerSyncRequest is a helper method that answers a
+// SYNC_REQUEST announcement with a SNAPSHOT announcement,
+// provided the database is announcing.
+func (starkdb *Db) answerSyncRequest(ann *Announcement) error {
+	if !starkdb.announcing {
+		return nil
+	}
+	starkdb.Lock()
+	defer starkdb.Unlock()
+	starkdb.send2log(fmt.Sprintf("sync requested by %v, announcing snapshot: %v", ann.GetSender(), starkdb.snapshotCID))
+	return starkdb.publishAnnouncement(starkdb.newAnnouncement(Announcement_SNAPSHOT, "", "", nil))
+}
+
+// applySnapshot is a helper method that applies a
+// SNAPSHOT announcement to the database.
+//
+// The changes made in the announced snapshot since its
+// common ancestor with the database snapshot are applied
+// key by key, using applyRecord for new and changed
+// Records and applyDelete for removed Records, so that
+// every change gets the same checks as Set and the
+// signature checks (see WithRequireSigned). If any change
+// fails a check, the snapshot is queued for a manual Merge.
+func (starkdb *Db) applySnapshot(ctx context.Context, ann *Announcement) error {
+	starkdb.Lock()
+	if starkdb.readOnly {
+		starkdb.Unlock()
+		return ErrReadOnly
+	}
+	announced, head := ann.GetSnapshotCID(), starkdb.snapshotCID
+	starkdb.Unlock()
+	if len(announced) == 0 {
+		return ErrNoCID
+	}
+	if announced == head {
+		return nil
+	}
+
+	// find the changes made in the announced snapshot
+	ancestor, err := starkdb.findCommonAncestor(head, announced)
+	if err == ErrNoCommonAncestor {
+		starkdb.queueSnapshot(ann, "no common ancestor")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if ancestor == announced {
+		return nil
+	}
+	pairsO, err := starkdb.getSnapshotPairs(ancestor)
+	if err != nil {
+		return err
+	}
+	pairsB, err := starkdb.getSnapshotPairs(announced)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(pairsB))
+	for key, cid := range pairsB {
+		if pairsO[key] != cid {
+			keys = append(keys, key)
+		}
+	}
+	for key := range pairsO {
+		if _, ok := pairsB[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	// apply each change, queuing the snapshot if any fail the checks
+	failed := 0
+	for _, key := range keys {
+		err := starkdb.applySnapshotChange(ctx, ann, key, pairsO[key], pairsB[key])
+		if _, isConflict := err.(*ConflictEvent); isConflict || isAuthorError(err) {
+			starkdb.send2log(fmt.Sprintf("snapshot change refused for %v: %v", key, err))
+			failed++
+			continue
+		}
+		if err != nil && err != ErrRecordDeleted {
+			return err
+		}
+	}
+	if failed != 0 {
+		starkdb.queueSnapshot(ann, fmt.Sprintf("%d changes refused", failed))
+		return nil
+	}
+	starkdb.Lock()
+	for sender, cid := range starkdb.pendingSnapshots {
+		if cid == announced {
+			delete(starkdb.pendingSnapshots, sender)
+		}
+	}
+	starkdb.Unlock()
+	starkdb.send2log(fmt.Sprintf("database updated from snapshot announced by %v: %v (%d changes)", ann.GetSender(), announced, len(keys)))
+	return nil
+}
+
+// applySnapshotChange is a helper method that applies
+// a key changed in an announced snapshot, using
+// applyRecord for new and changed Records and
+// applyDelete for removed Records.
+//
+// Changes which the database already holds a later
+// version of are skipped, as are removed keys which
+// the database doesn't hold.
+func (starkdb *Db) applySnapshotChange(ctx context.Context, ann *Announcement, key, ancestorCID, cid string) error {
+	starkdb.Lock()
+	heldCID, exists := starkdb.cidLookup[key]
+	starkdb.Unlock()
+	if len(cid) == 0 {
+		if !exists {
+			return nil
+		}
+		return starkdb.applyDelete(ctx, &Announcement{
+			Type:      Announcement_DELETE,
+			Key:       key,
+			Cid:       ancestorCID,
+			Sender:    ann.GetSender(),
+			Timestamp: ann.GetTimestamp(),
+		})
+	}
+	if exists && heldCID != cid {
+		superseded, err := starkdb.isDescendant(heldCID, cid)
+		if err != nil {
+			return err
+		}
+		if superseded {
+			return nil
+		}
+	}
+	record, err := starkdb.getRecordFromCID(cid)
+	if err != nil {
+		return err
+	}
+	_, err = starkdb.applyRecord(ctx, key, record)
+	return err
+}
+
+// queueSnapshot is a helper method that queues an
+// announced snapshot which could not be applied, ready
+// for a manual Merge.
+func (starkdb *Db) queueSnapshot(ann *Announcement, reason string) {
+	starkdb.Lock()
+	starkdb.pendingSnapshots[ann.GetSender()] = ann.GetSnapshotCID()
+	starkdb.Unlock()
+	starkdb.send2log(fmt.Sprintf("snapshot queued from %v: %v (%v)", ann.GetSender(), ann.GetSnapshotCID(), reason))
+}
+
+// startAutoSync will start a ListenAnnouncements
+// subscription for the database project and apply every
+// announced change to the database using
+// ApplyAnnouncement, until the database is closed.
+//
+// Progress and errors are reported via the logger.
+func (starkdb *Db) startAutoSync() error {
+	terminator := make(chan struct{})
+	anns, errs, err := starkdb.ListenAnnouncements(terminator)
+	if err != nil {
+		return err
+	}
+	starkdb.syncTerminator = terminator
+	starkdb.syncDone = make(chan struct{})
+	starkdb.send2log(fmt.Sprintf("auto sync: listening on %v", starkdb.project))
+
+	// process the listener channels until both are closed by the terminator
+	go func() {
+		defer close(starkdb.syncDone)
+		synced := 0
+		for anns != nil || errs != nil {
+			select {
+			case ann, ok := <-anns:
+				if !ok {
+					anns = nil
+					continue
+				}
+				ctx, cancel := context.WithTimeout(starkdb.ctx, DefaultSyncTimeout)
+				err := starkdb.ApplyAnnouncement(ctx, ann)
+				cancel()
+				if err != nil {
+
+					// conflict events are already reported by ApplyAnnouncement
+					if _, isConflict := err.(*ConflictEvent); !isConflict {
+						starkdb.send2log(fmt.Sprintf("auto sync: could not apply %v announcement for %v: %v", ann.GetType(), ann.GetKey(), err))
+					}
+					continue
+				}
+				synced++
+				starkdb.send2log(fmt.Sprintf("auto sync: applied %v announcement from %v (%d synced)", ann.GetType(), ann.GetSender(), synced))
+			case err, ok := <-errs:
+				if !ok {
+					errs = nil
+					continue
+				}
+				starkdb.send2log(fmt.Sprintf("auto sync: %v", err))
+			}
+		}
+	}()
+	return nil
+}
+
+// stopAutoSync will stop the auto sync subscription
+// and wait for any announcement being applied to finish.
+func (starkdb *Db) stopAutoSync() {
+	if starkdb.syncTerminator == nil {
+		return
+	}
+	close(starkdb.syncTerminator)
+	<-starkdb.syncDone
+	starkdb.syncTerminator = nil
+}
+
+// startSnapshotAnnouncer will broadcast the database
+// head snapshot on the project every snapshot interval,
+// until the database is closed.
+func (starkdb *Db) startSnapshotAnnouncer() {
+	starkdb.announcerStop = make(chan struct{})
+	starkdb.announcerDone = make(chan struct{})
+	go func() {
+		defer close(starkdb.announcerDone)
+		ticker := time.NewTicker(starkdb.snapshotInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				starkdb.Lock()
+				err := starkdb.publishAnnouncement(starkdb.newAnnouncement(Announcement_SNAPSHOT, "", "", nil))
+				starkdb.Unlock()
+				if err != nil {
+					starkdb.send2log(fmt.Sprintf("could not announce snapshot: %v", err))
+				}
+			case <-starkdb.announcerStop:
+				return
+			}
+		}
+	}()
+}
+
+// stopSnapshotAnnouncer will stop the head snapshot
+// announcements.
+func (starkdb *Db) stopSnapshotAnnouncer() {
+	if starkdb.announcerStop == nil {
+		return
+	}
+	close(starkdb.announcerStop)
+	<-starkdb.announcerDone
+	starkdb.announcerStop = nil
+}
@@ -1,14 +1,32 @@
 package stark
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	icore "github.com/ipfs/interface-go-ipfs-core"
+	libp2pcrypto "github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	starkcrypto "github.com/will-rowe/stark/src/crypto"
 	starkipfs "github.com/will-rowe/stark/src/ipfs"
+	starkmemory "github.com/will-rowe/stark/src/memory"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 var (
@@ -32,7 +50,7 @@ var (
 // IPFS package tests are combined in the stark tests file as the
 // plugin injection can only be run once per session.
 // Running multiple test files via `go test ./...` will result
-// in IPFS lock file contention and a panic.
+// in IPFS lock file contention.
 
 // TestIpfsClient will test out the client.
 func TestIpfsNewClient(t *testing.T) {
@@ -63,6 +81,105 @@ func TestIpfsNewClient(t *testing.T) {
 	}
 }
 
+// TestIpfsInitRepo will test creating a new IPFS repo.
+func TestIpfsInitRepo(t *testing.T) {
+	repoPath, err := ioutil.TempDir("", "stark-test-repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(repoPath)
+
+	// initialise the repo
+	if err := starkipfs.InitRepo(repoPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := starkipfs.InitRepo(repoPath); err != starkipfs.ErrRepoAlreadyInitialised {
+		t.Fatal("initialised an existing repo")
+	}
+
+	// open a database using the new repo
+	starkdb, teardown, err := OpenDB(SetProject(testProject), WithRepoPath(repoPath), WithOffline())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if starkdb.repoPath != repoPath {
+		t.Fatal("starkDB did not use the provided repo")
+	}
+	if err := teardown(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestIpfsBadRepo will test that a repo which can't be
+// initialised returns an error instead of panicking.
+func TestIpfsBadRepo(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tmpFile, err := ioutil.TempFile("", "stark-test-file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpFile.Close()
+	defer os.Remove(tmpFile.Name())
+
+	// a repo can't be created inside a file
+	repoPath := filepath.Join(tmpFile.Name(), "repo")
+	if _, err := starkipfs.NewIPFSclient(ctx, starkipfs.SetRepoPath(repoPath)); err == nil {
+		t.Fatal("opened an IPFS client with a bad repo path")
+	}
+	if _, _, err := OpenDB(SetProject(testProject), WithRepoPath(repoPath), WithOffline()); err == nil {
+		t.Fatal("opened a database with a bad repo path")
+	}
+}
+
+// TestIpfsHTTPClient will test attaching to an IPFS daemon
+// via its HTTP API, using a fake API.
+func TestIpfsHTTPClient(t *testing.T) {
+	daemonID := "QmUd6zHcbkbcs7SMxwLs48qZVX3vpcM8errYS7xEczwRMA"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v0/id":
+			fmt.Fprintf(w, `{"ID": "%v"}`, daemonID)
+		case "/api/v0/swarm/addrs/local":
+			fmt.Fprint(w, `{"Strings": ["/ip4/127.0.0.1/tcp/4001"]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	apiAddr := fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", srv.Listener.Addr().(*net.TCPAddr).Port)
+
+	// attach to the fake daemon
+	client, err := starkipfs.NewHTTPClient(apiAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.PrintNodeID() != daemonID {
+		t.Fatalf("expected daemon ID %v, got %v", daemonID, client.PrintNodeID())
+	}
+	if !client.Online() {
+		t.Fatal("daemon should be online")
+	}
+	if _, err := client.PrivateKey(); err != starkipfs.ErrNoPrivateKey {
+		t.Fatalf("expected %v, got %v", starkipfs.ErrNoPrivateKey, err)
+	}
+	if err := client.EndSession(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the daemon can't be used offline
+	if _, _, err := OpenDB(SetProject(testProject), WithIPFSAPI(apiAddr), WithOffline()); err != ErrOfflineOpt {
+		t.Fatalf("expected %v, got %v", ErrOfflineOpt, err)
+	}
+
+	// a daemon which isn't running returns an error
+	srv.Close()
+	if _, err := starkipfs.NewHTTPClient(apiAddr); err == nil {
+		t.Fatal("attached to a daemon which isn't running")
+	}
+}
+
 // TestIpfsFileIO will test file add and get operations to the IPFS.
 func TestIpfsFileIO(t *testing.T) {
 
@@ -92,6 +209,9 @@ func TestIpfsFileIO(t *testing.T) {
 	if err := client.Unpin(ctx, cid); err != nil {
 		t.Fatal(err)
 	}
+	if err := client.Unpin(ctx, cid); !errors.Is(err, ErrNotPinned) {
+		t.Fatalf("expected %v, got %v", ErrNotPinned, err)
+	}
 
 	// keep it as a byte slice for ease
 	retrievedFile, err := ioutil.ReadFile(tmpFile)
@@ -285,9 +405,17 @@ func TestReopenDB(t *testing.T) {
 	t.Log(retrievedSample)
 
 	// try deleting
-	if err := starkdb.Delete(testKey); err != nil {
+	deletedCID := cids[testKey]
+	deleteResponse, err := starkdb.Delete(ctx, &Key{Key: testKey})
+	if err != nil {
 		t.Fatal(err)
 	}
+	if deleteResponse.GetRemovedCID() != deletedCID {
+		t.Fatal("delete response did not return the removed Record CID")
+	}
+	if _, err := starkdb.Delete(ctx, &Key{Key: testKey}); status.Code(err) != codes.NotFound {
+		t.Fatal("deleting a missing key did not return a NotFound status")
+	}
 	if starkdb.currentNumEntries != 0 {
 		t.Fatal("db is not empty after delete operation on sole entry")
 	}
@@ -367,6 +495,2176 @@ func TestMessages(t *testing.T) {
 	t.Log("snapshot: ", testSnapshot)
 }
 
+// TestOfflineDB will check a database with networking disabled.
+func TestOfflineDB(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// check option conflicts are caught
+	if _, _, err := OpenDB(SetProject(testProject), WithOffline(), WithAnnouncing()); err != ErrOfflineOpt {
+		t.Fatal("offline starkDB was allowed to announce")
+	}
+
+	// open the db offline
+	starkdb, teardown, err := OpenDB(SetProject(testProject), WithOffline())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+	if starkdb.isOnline() {
+		t.Fatal("offline starkDB has an online IPFS node")
+	}
+
+	// check Records can still be added locally
+	testRecord, err := NewRecord(SetAlias(testKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := starkdb.Set(ctx, &KeyRecordPair{Key: testKey, Record: testRecord}); err != nil {
+		t.Fatal(err)
+	}
+
+	// check networking features are refused
+	if _, _, err := starkdb.Listen(make(chan struct{})); err != ErrNodeOffline {
+		t.Fatal("offline starkDB should not be able to Listen")
+	}
+	if _, err := starkdb.Dump(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestInMemoryDB will check a database using the in-memory backend.
+func TestInMemoryDB(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// open the db with the in-memory backend
+	starkdb, teardown, err := OpenDB(SetProject(testProject), WithInMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+	if starkdb.isOnline() {
+		t.Fatal("in-memory starkDB should be offline")
+	}
+
+	// check Set and Get
+	testRecord, err := NewRecord(SetAlias(testKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := starkdb.Set(ctx, &KeyRecordPair{Key: testKey, Record: testRecord}); err != nil {
+		t.Fatal(err)
+	}
+	response, err := starkdb.Get(ctx, &Key{Key: testKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.GetRecord().GetUuid() != testRecord.GetUuid() {
+		t.Fatal("record retrieved from in-memory starkDB does not match original")
+	}
+	if starkdb.GetSnapshot() == "" {
+		t.Fatal("no snapshot produced by in-memory starkdb")
+	}
+
+	// check listening is refused
+	if _, _, err := starkdb.Listen(make(chan struct{})); err != ErrNodeOffline {
+		t.Fatal("in-memory starkDB should not be able to Listen")
+	}
+
+	// check Delete
+	if _, err := starkdb.Delete(ctx, &Key{Key: testKey}); err != nil {
+		t.Fatal(err)
+	}
+	if starkdb.GetNumEntries() != 0 {
+		t.Fatal("db is not empty after delete operation on sole entry")
+	}
+}
+
+// TestRollback will check Records can be rolled back to previous versions.
+func TestRollback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	starkdb, teardown, err := OpenDB(SetProject(testProject), WithInMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	// add a record and then update it
+	testRecord, err := NewRecord(SetAlias(testKey), SetDescription(testDescription))
+	if err != nil {
+		t.Fatal(err)
+	}
+	original, err := starkdb.Set(ctx, &KeyRecordPair{Key: testKey, Record: testRecord})
+	if err != nil {
+		t.Fatal(err)
+	}
+	update, err := starkdb.Get(ctx, &Key{Key: testKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SetDescription("an updated description")(update.GetRecord()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := starkdb.Set(ctx, &KeyRecordPair{Key: testKey, Record: update.GetRecord()}); err != nil {
+		t.Fatal(err)
+	}
+
+	// roll back one step
+	rolledBack, err := starkdb.Rollback(ctx, &RollbackRequest{Key: testKey, Steps: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rolledBack.GetRecord().GetDescription() != testDescription {
+		t.Fatal("rollback did not restore the original Record")
+	}
+	if starkdb.GetCIDs()[testKey] != rolledBack.GetRecord().GetPreviousCID() {
+		t.Fatal("rollback did not update the database entry")
+	}
+
+	// roll back to the original CID (the rollback itself is now a version)
+	if _, err := starkdb.Rollback(ctx, &RollbackRequest{Key: testKey, TargetCID: original.GetRecord().GetPreviousCID()}); err != nil {
+		t.Fatal(err)
+	}
+
+	// check going too far back fails
+	if _, err := starkdb.Rollback(ctx, &RollbackRequest{Key: testKey, Steps: 42}); status.Code(err) != codes.OutOfRange {
+		t.Fatal("rolled back beyond the start of the Record history")
+	}
+}
+
+// TestHistory will check all versions of a Record can be retrieved.
+func TestHistory(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	starkdb, teardown, err := OpenDB(SetProject(testProject), WithInMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	// add a record and then update it
+	testRecord, err := NewRecord(SetAlias(testKey), SetDescription(testDescription))
+	if err != nil {
+		t.Fatal(err)
+	}
+	original, err := starkdb.Set(ctx, &KeyRecordPair{Key: testKey, Record: testRecord})
+	if err != nil {
+		t.Fatal(err)
+	}
+	update, err := starkdb.Get(ctx, &Key{Key: testKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SetDescription("an updated description")(update.GetRecord()); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := starkdb.Set(ctx, &KeyRecordPair{Key: testKey, Record: update.GetRecord()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// get the history, most recent first
+	versions, err := starkdb.GetHistory(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("expected 2 Record versions, got %d", len(versions))
+	}
+	if versions[0].GetCid() != updated.GetRecord().GetPreviousCID() || versions[1].GetCid() != original.GetRecord().GetPreviousCID() {
+		t.Fatal("history returned the wrong CIDs")
+	}
+	if versions[1].GetRecord().GetDescription() != testDescription {
+		t.Fatal("history did not return the original Record")
+	}
+	if versions[0].GetRecord().GetPreviousCID() != versions[1].GetCid() {
+		t.Fatal("history versions are not linked")
+	}
+
+	// check a missing key fails
+	if _, err := starkdb.GetHistory("missing key"); err == nil {
+		t.Fatal("got history for a missing key")
+	}
+}
+
+// TestSnapshots will check the snapshot lineage and
+// opening a database at a historic snapshot.
+func TestSnapshots(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := starkmemory.NewStore()
+	starkdb, teardown, err := OpenDB(SetProject(testProject), WithBackend(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	// add two records, then delete one
+	testRecord, err := NewRecord(SetAlias(testKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := starkdb.Set(ctx, &KeyRecordPair{Key: testKey, Record: testRecord}); err != nil {
+		t.Fatal(err)
+	}
+	firstSnapshot := starkdb.GetSnapshot()
+	time.Sleep(10 * time.Millisecond)
+	asOf := time.Now()
+	time.Sleep(10 * time.Millisecond)
+	testRecord2, err := NewRecord(SetAlias("another key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := starkdb.Set(ctx, &KeyRecordPair{Key: "another key", Record: testRecord2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := starkdb.Delete(ctx, &Key{Key: testKey}); err != nil {
+		t.Fatal(err)
+	}
+	headSnapshot := starkdb.GetSnapshot()
+
+	// check reserved keys can't be set
+	if _, err := starkdb.Set(ctx, &KeyRecordPair{Key: SnapshotParentLink, Record: testRecord2}); err != ErrReservedKey {
+		t.Fatal("set a Record using a reserved key")
+	}
+
+	// walk the lineage (3 updates plus the initial empty snapshot)
+	expEntries := []int{1, 2, 1, 0}
+	iter := starkdb.Snapshots()
+	i := 0
+	for iter.Next() {
+		snapshot := iter.Snapshot()
+		if i >= len(expEntries) {
+			t.Fatal("too many snapshots in lineage")
+		}
+		if snapshot.NumEntries != expEntries[i] {
+			t.Fatalf("snapshot %d has %d entries, expected %d", i, snapshot.NumEntries, expEntries[i])
+		}
+		if i < 3 && snapshot.Project != testProject {
+			t.Fatal("snapshot metadata missing")
+		}
+		i++
+	}
+	if err := iter.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if i != len(expEntries) {
+		t.Fatalf("expected %d snapshots, got %d", len(expEntries), i)
+	}
+
+	// open at the first snapshot and check it is read-only
+	historicDB, historicTeardown, err := OpenDB(SetProject(testProject), WithBackend(store), WithHistoricSnapshot(firstSnapshot))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := historicDB.Get(ctx, &Key{Key: testKey}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := historicDB.Set(ctx, &KeyRecordPair{Key: "new key", Record: testRecord2}); err != ErrReadOnly {
+		t.Fatal("wrote to a read-only database")
+	}
+	historicTeardown()
+
+	// open at a time and check the right snapshot is found
+	historicDB, historicTeardown, err = OpenDB(SetProject(testProject), WithBackend(store), SetSnapshotCID(headSnapshot), WithHistoricTime(asOf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer historicTeardown()
+	if historicDB.GetSnapshot() != firstSnapshot {
+		t.Fatal("did not open the database at the requested time")
+	}
+	if _, _, err := OpenDB(SetProject(testProject), WithBackend(store), SetSnapshotCID(headSnapshot), WithHistoricTime(time.Time{})); !errors.Is(err, ErrNoHistoricTime) {
+		t.Fatalf("expected %v, got %v", ErrNoHistoricTime, err)
+	}
+}
+
+// TestDiff will check the differences between two
+// snapshots are reported.
+func TestDiff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	starkdb, teardown, err := OpenDB(SetProject(testProject), WithInMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	// add two records and take the snapshot
+	for _, key := range []string{"key A", "key B"} {
+		testRecord, err := NewRecord(SetAlias(key), SetDescription(testDescription))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := starkdb.Set(ctx, &KeyRecordPair{Key: key, Record: testRecord}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snapshotA := starkdb.GetSnapshot()
+
+	// remove one, update one and add one
+	if _, err := starkdb.Delete(ctx, &Key{Key: "key A"}); err != nil {
+		t.Fatal(err)
+	}
+	update, err := starkdb.Get(ctx, &Key{Key: "key B"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SetDescription("an updated description")(update.GetRecord()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := starkdb.Set(ctx, &KeyRecordPair{Key: "key B", Record: update.GetRecord()}); err != nil {
+		t.Fatal(err)
+	}
+	testRecord, err := NewRecord(SetAlias("key C"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := starkdb.Set(ctx, &KeyRecordPair{Key: "key C", Record: testRecord}); err != nil {
+		t.Fatal(err)
+	}
+	snapshotB := starkdb.GetSnapshot()
+
+	// check the diff
+	diff, err := starkdb.DiffSnapshots(snapshotA, snapshotB, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.GetAdded()) != 1 || diff.GetAdded()[0] != "key C" {
+		t.Fatalf("unexpected added keys: %v", diff.GetAdded())
+	}
+	if len(diff.GetRemoved()) != 1 || diff.GetRemoved()[0] != "key A" {
+		t.Fatalf("unexpected removed keys: %v", diff.GetRemoved())
+	}
+	if len(diff.GetChanged()) != 1 || diff.GetChanged()[0].GetKey() != "key B" {
+		t.Fatalf("unexpected changed keys: %v", diff.GetChanged())
+	}
+	found := false
+	for _, field := range diff.GetChanged()[0].GetFields() {
+		if field.GetField() == "description" {
+			found = true
+			if field.GetValueB() != `"an updated description"` {
+				t.Fatalf("unexpected field diff: %v", field)
+			}
+		}
+	}
+	if !found {
+		t.Fatal("no field-level diff for the updated description")
+	}
+
+	// check a snapshot has no diff with itself
+	diff, err = starkdb.DiffSnapshots(snapshotB, snapshotB, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.GetAdded())+len(diff.GetRemoved())+len(diff.GetChanged()) != 0 {
+		t.Fatal("snapshot differs from itself")
+	}
+}
+
+// TestMerge will check two divergent snapshots can be
+// merged and that conflicts are reported.
+func TestMerge(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := starkmemory.NewStore()
+	db1, teardown1, err := OpenDB(SetProject(testProject), WithBackend(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown1()
+
+	// add two records to the first database and use it as the ancestor
+	for _, key := range []string{"key A", "key B"} {
+		testRecord, err := NewRecord(SetAlias(key), SetDescription(testDescription))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db1.Set(ctx, &KeyRecordPair{Key: key, Record: testRecord}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ancestor := db1.GetSnapshot()
+	db2, teardown2, err := OpenDB(SetProject(testProject), WithBackend(store), SetSnapshotCID(ancestor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown2()
+
+	// update a record in both databases so that they diverge
+	update := func(db *Db, key, description string) {
+		resp, err := db.Get(ctx, &Key{Key: key})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := SetDescription(description)(resp.GetRecord()); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Set(ctx, &KeyRecordPair{Key: key, Record: resp.GetRecord()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	update(db1, "key A", "updated in db1")
+	update(db2, "key A", "updated in db2")
+
+	// make some non-conflicting changes in the second database
+	update(db2, "key B", "updated in db2")
+	testRecord, err := NewRecord(SetAlias("key C"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db2.Set(ctx, &KeyRecordPair{Key: "key C", Record: testRecord}); err != nil {
+		t.Fatal(err)
+	}
+
+	// merge the second database into the first
+	headA := db1.GetSnapshot()
+	resp, err := db1.Merge(ctx, &MergeRequest{HeadB: db2.GetSnapshot()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetAncestor() != ancestor {
+		t.Fatalf("wrong common ancestor found: %v", resp.GetAncestor())
+	}
+	if resp.GetSuccess() || len(resp.GetConflicts()) != 1 || resp.GetConflicts()[0].GetKey() != "key A" {
+		t.Fatalf("expected a single conflict for key A: %v", resp.GetConflicts())
+	}
+	if resp.GetConflicts()[0].GetReason() != ErrRecordHistory.Error() {
+		t.Fatalf("unexpected conflict reason: %v", resp.GetConflicts()[0].GetReason())
+	}
+	if db1.GetSnapshot() != headA {
+		t.Fatal("database was updated despite merge conflicts")
+	}
+
+	// check the merged snapshot
+	merged, err := db1.getSnapshotPairs(resp.GetSnapshotCID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged["key A"] != db1.GetCIDs()["key A"] || merged["key B"] != db2.GetCIDs()["key B"] || merged["key C"] != db2.GetCIDs()["key C"] {
+		t.Fatal("merged snapshot does not contain the expected Records")
+	}
+
+	// check merging an ancestor is a fast-forward
+	resp, err = db1.MergeSnapshots("", headA, ancestor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.GetSuccess() || resp.GetSnapshotCID() != headA {
+		t.Fatal("merging an ancestor did not fast-forward")
+	}
+
+	// check a clean merge is applied and the adopted Records are pinned
+	db3, teardown3, err := OpenDB(SetProject(testProject), WithBackend(store), SetSnapshotCID(ancestor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown3()
+	testRecord, err = NewRecord(SetAlias("key D"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db3.Set(ctx, &KeyRecordPair{Key: "key D", Record: testRecord}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db3.Delete(ctx, &Key{Key: "key B"}); err != nil {
+		t.Fatal(err)
+	}
+	adopted := db3.GetCIDs()["key D"]
+	if err := store.Unpin(ctx, adopted); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = db1.Merge(ctx, &MergeRequest{HeadB: db3.GetSnapshot()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.GetSuccess() || db1.GetSnapshot() != resp.GetSnapshotCID() {
+		t.Fatalf("clean merge was not applied: %v", resp.GetConflicts())
+	}
+	if _, ok := db1.GetCIDs()["key B"]; ok || db1.GetCIDs()["key D"] != adopted {
+		t.Fatal("merged database does not contain the expected Records")
+	}
+	if err := store.Unpin(ctx, adopted); err != nil {
+		t.Fatalf("adopted Record was not pinned: %v", err)
+	}
+}
+
+// TestConflictPolicy will check Records received from
+// another database are applied using the conflict policy.
+func TestConflictPolicy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := starkmemory.NewStore()
+	remote, teardown, err := OpenDB(SetProject(testProject), WithBackend(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	// add records to the remote database and take the snapshot
+	for _, key := range []string{"keyA", "keyB"} {
+		testRecord, err := NewRecord(SetAlias(key), SetDescription(testDescription))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := remote.Set(ctx, &KeyRecordPair{Key: key, Record: testRecord}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ancestor := remote.GetSnapshot()
+
+	// open a local database for each policy at the same snapshot and make a local change to keyA
+	update := func(db *Db, key, description string) *Record {
+		resp, err := db.Get(ctx, &Key{Key: key})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := SetDescription(description)(resp.GetRecord()); err != nil {
+			t.Fatal(err)
+		}
+		resp, err = db.Set(ctx, &KeyRecordPair{Key: key, Record: resp.GetRecord()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.GetRecord()
+	}
+	policies := []ConflictPolicy{RejectAndReport, LastWriterWins, KeepBothAsFork}
+	locals := make([]*Db, len(policies))
+	for i, policy := range policies {
+		local, localTeardown, err := OpenDB(SetProject(testProject), WithBackend(store), SetSnapshotCID(ancestor), WithConflictPolicy(policy))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer localTeardown()
+		update(local, "keyA", "updated locally")
+		locals[i] = local
+	}
+
+	// update both records in the remote database, keyA will now conflict
+	remoteA := update(remote, "keyA", "updated remotely")
+	remoteB := update(remote, "keyB", "updated remotely")
+
+	// check each local database
+	for i, local := range locals {
+
+		// keyB is a straight update
+		if _, err := local.ApplyRecord(ctx, remoteB); err != nil {
+			t.Fatal(err)
+		}
+		if local.GetCIDs()["keyB"] != remoteB.GetPreviousCID() {
+			t.Fatal("update was not applied")
+		}
+
+		// keyA conflicts
+		resp, err := local.ApplyRecord(ctx, remoteA)
+		switch i {
+		case 0:
+			event, ok := err.(*ConflictEvent)
+			if !ok {
+				t.Fatalf("expected a conflict event, got: %v", err)
+			}
+			if event.Reason != ErrRecordHistory || event.Resolution != ConflictReject {
+				t.Fatalf("unexpected conflict event: %v", event)
+			}
+		case 1:
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.GetRecord().GetDescription() != "updated remotely" {
+				t.Fatal("last writer did not win")
+			}
+		case 2:
+			if err != nil {
+				t.Fatal(err)
+			}
+			if local.GetCIDs()["keyA"+DefaultForkSeparator+remoteA.GetPreviousCID()] != remoteA.GetPreviousCID() {
+				t.Fatal("conflicting Record was not forked")
+			}
+		}
+	}
+}
+
+// TestApplyPinning will check Records received from
+// another database are pinned and can be deleted.
+func TestApplyPinning(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	remoteStore, localStore := starkmemory.NewStore(), starkmemory.NewStore()
+	remote, remoteTeardown, err := OpenDB(SetProject(testProject), WithBackend(remoteStore))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remoteTeardown()
+	local, localTeardown, err := OpenDB(SetProject(testProject), WithBackend(localStore))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer localTeardown()
+
+	// copy a Record from the remote store without pinning it
+	testRecord, err := NewRecord(SetAlias(testKey), SetDescription(testDescription))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := remote.Set(ctx, &KeyRecordPair{Key: testKey, Record: testRecord})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cid := resp.GetRecord().GetPreviousCID()
+	node, err := remoteStore.DagGet(ctx, cid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := node.(*cbor.Node).MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := localStore.DagPut(ctx, data, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := localStore.Unpin(ctx, cid); !errors.Is(err, ErrNotPinned) {
+		t.Fatalf("expected %v, got %v", ErrNotPinned, err)
+	}
+
+	// apply it and check it was pinned
+	if _, err := local.ApplyRecord(ctx, resp.GetRecord()); err != nil {
+		t.Fatal(err)
+	}
+	if err := localStore.Unpin(ctx, cid); err != nil {
+		t.Fatalf("applied record was not pinned: %v", err)
+	}
+
+	// the Record is now unpinned, but can still be deleted
+	if _, err := local.Delete(ctx, &Key{Key: testKey}); err != nil {
+		t.Fatal(err)
+	}
+	if local.GetNumEntries() != 0 {
+		t.Fatal("deleted record was not removed from the keystore")
+	}
+	local.Lock()
+	snapshot := local.snapshotCID
+	local.Unlock()
+	pairs, err := local.getSnapshotPairs(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := pairs[testKey]; ok {
+		t.Fatal("deleted record is still in the snapshot")
+	}
+}
+
+// TestAutoSync will check the auto sync option.
+func TestAutoSync(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// check option conflicts are caught
+	if _, _, err := OpenDB(SetProject(testProject), WithOffline(), WithAutoSync()); err != ErrOfflineOpt {
+		t.Fatal("offline starkDB was allowed to auto sync")
+	}
+	if _, _, err := OpenDB(SetProject(testProject), WithInMemory(), WithAutoSync()); err != ErrNodeOffline {
+		t.Fatal("in-memory starkDB was allowed to auto sync")
+	}
+
+	// open an online db which syncs and check it closes down cleanly
+	starkdb, teardown, err := OpenDB(SetProject(testProject), WithNoPinning(), WithAutoSync())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if starkdb.syncTerminator == nil {
+		t.Fatal("auto sync listener was not started")
+	}
+	if err := teardown(); err != nil {
+		t.Fatal(err)
+	}
+
+	// open a remote db and a syncing db which share a store, feeding the announcements from the remote to the syncing db
+	store := starkmemory.NewStore()
+	remote, remoteTeardown, err := OpenDB(SetProject(testProject), WithBackend(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer remoteTeardown()
+	privKey, err := starkmemory.NewStore().PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, err := peer.IDFromPrivateKey(privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	psStore := &pubsubStore{Store: store, msgs: make(chan icore.PubSubMessage), errs: make(chan error)}
+	local, localTeardown, err := OpenDB(SetProject(testProject), WithBackend(psStore), WithAutoSync())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer localTeardown()
+
+	// announce two Records
+	keys := []string{"key A", "key B"}
+	for _, key := range keys {
+		testRecord, err := NewRecord(SetAlias(key), SetDescription(testDescription))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := remote.Set(ctx, &KeyRecordPair{Key: key, Record: testRecord})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ann := remote.newAnnouncement(Announcement_SET, key, resp.GetRecord().GetPreviousCID(), nil)
+		ann.Sender = sender.String()
+		data, err := proto.Marshal(ann)
+		if err != nil {
+			t.Fatal(err)
+		}
+		psStore.msgs <- &pubsubMessage{from: sender, data: data}
+	}
+
+	// check both were applied by the listener
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		cids := local.GetCIDs()
+		if cids[keys[0]] == remote.GetCIDs()[keys[0]] && cids[keys[1]] == remote.GetCIDs()[keys[1]] {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("announced records were not applied: %v", cids)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if local.GetNumEntries() != len(keys) {
+		t.Fatalf("expected %d entries, got %d", len(keys), local.GetNumEntries())
+	}
+}
+
+// pubsubStore is an in-memory store with a PubSub
+// subscription which is fed by the test. The sent
+// messages are passed to the sent channel (if set)
+// and the node identity of the store can be replaced
+// with the identity of another store (if set), so that
+// two databases can share the blocks but not the peer ID.
+type pubsubStore struct {
+	*starkmemory.Store
+	msgs     chan icore.PubSubMessage
+	errs     chan error
+	sent     chan []byte
+	identity *starkmemory.Store
+}
+
+func (store *pubsubStore) Online() bool                                      { return true }
+func (store *pubsubStore) Subscribe(ctx context.Context, topic string) error { return nil }
+func (store *pubsubStore) Unsubscribe() error                                { return nil }
+func (store *pubsubStore) GetPSMchan() chan icore.PubSubMessage              { return store.msgs }
+func (store *pubsubStore) GetPSEchan() chan error                            { return store.errs }
+func (store *pubsubStore) SendMessage(ctx context.Context, topic string, message []byte) error {
+	if store.sent != nil {
+		store.sent <- message
+	}
+	return nil
+}
+func (store *pubsubStore) PrintNodeID() string {
+	if store.identity != nil {
+		return store.identity.PrintNodeID()
+	}
+	return store.Store.PrintNodeID()
+}
+func (store *pubsubStore) PrivateKey() (libp2pcrypto.PrivKey, error) {
+	if store.identity != nil {
+		return store.identity.PrivateKey()
+	}
+	return store.Store.PrivateKey()
+}
+
+// pubsubMessage is a PubSub message sent to a pubsubStore.
+type pubsubMessage struct {
+	from peer.ID
+	data []byte
+}
+
+func (msg *pubsubMessage) From() peer.ID    { return msg.from }
+func (msg *pubsubMessage) Data() []byte     { return msg.data }
+func (msg *pubsubMessage) Seq() []byte      { return nil }
+func (msg *pubsubMessage) Topics() []string { return []string{testProject} }
+
+// TestAnnouncement will check Announcements can be
+// encoded, decoded and used to collect Records.
+func TestAnnouncement(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	starkdb, teardown, err := OpenDB(SetProject(testProject), WithInMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	// add a record and announce it
+	testRecord, err := NewRecord(SetAlias(testKey), SetDescription(testDescription))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := starkdb.Set(ctx, &KeyRecordPair{Key: testKey, Record: testRecord})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cid := resp.GetRecord().GetPreviousCID()
+	stored, err := starkdb.getStoredRecord(cid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ann := starkdb.newAnnouncement(Announcement_SET, testKey, cid, stored)
+	if ann.GetRecord() == nil {
+		t.Fatal("small Record was not included in the announcement")
+	}
+	data, err := proto.Marshal(ann)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// check the announcement is read and the Record collected
+	received, err := readAnnouncement(data, ann.GetSender())
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err := starkdb.getAnnouncedRecord(received)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.GetDescription() != testDescription || record.GetPreviousCID() != cid {
+		t.Fatal("announced Record was not collected")
+	}
+
+	// check bad announcements are rejected
+	if _, err := readAnnouncement(data, "another peer"); err == nil {
+		t.Fatal("accepted an announcement from the wrong sender")
+	}
+	received.SchemaVersion = AnnouncementSchemaVersion + 1
+	data, err = proto.Marshal(received)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readAnnouncement(data, ann.GetSender()); err == nil {
+		t.Fatal("accepted an announcement with an unknown schema version")
+	}
+	received.Record.Description = "tampered with"
+	if _, err := starkdb.getAnnouncedRecord(received); err == nil {
+		t.Fatal("accepted an inline Record which does not match the announced CID")
+	}
+
+	// check the mismatched Record was not stored
+	tamperedData, err := json.Marshal(received.GetRecord())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered, err := cbor.FromJSON(bytes.NewReader(tamperedData), starkmemory.DefaultMhType, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := starkdb.backend.DagGet(ctx, tampered.Cid().String()); err == nil {
+		t.Fatal("stored an inline Record which does not match the announced CID")
+	}
+
+	// check a failed announcement does not fail the write
+	announcer, announcerTeardown, err := OpenDB(SetProject(testProject), WithInMemory(), WithAnnouncing())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer announcerTeardown()
+	testRecord, err = NewRecord(SetAlias(testKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := announcer.Set(ctx, &KeyRecordPair{Key: testKey, Record: testRecord}); err != nil {
+		t.Fatalf("failed announcement failed the Set: %v", err)
+	}
+	if announcer.GetNumEntries() != 1 {
+		t.Fatal("database entries are out of step after a failed announcement")
+	}
+	if _, err := announcer.Delete(ctx, &Key{Key: testKey}); err != nil {
+		t.Fatalf("failed announcement failed the Delete: %v", err)
+	}
+	if announcer.GetNumEntries() != 0 {
+		t.Fatal("database entries are out of step after a failed announcement")
+	}
+}
+
+// TestApplyAnnouncement will check deletes and snapshots
+// announced by another database are applied or queued.
+func TestApplyAnnouncement(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := starkmemory.NewStore()
+	remote, teardown, err := OpenDB(SetProject(testProject), WithBackend(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+	set := func(db *Db, key, description string) string {
+		testRecord, err := NewRecord(SetAlias(key), SetDescription(description))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp, err := db.Get(ctx, &Key{Key: key}); err == nil {
+			testRecord = resp.GetRecord()
+			if err := SetDescription(description)(testRecord); err != nil {
+				t.Fatal(err)
+			}
+		}
+		resp, err := db.Set(ctx, &KeyRecordPair{Key: key, Record: testRecord})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.GetRecord().GetPreviousCID()
+	}
+	announce := func(annType Announcement_Type, key, cid string) *Announcement {
+		ann := remote.newAnnouncement(annType, key, cid, nil)
+		ann.Sender = "remote peer"
+		return ann
+	}
+	del := func(key string) string {
+		resp, err := remote.Delete(ctx, &Key{Key: key})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.GetRemovedCID()
+	}
+
+	// open a local database at the remote snapshot
+	for _, key := range []string{"key A", "key B"} {
+		set(remote, key, testDescription)
+	}
+	local, localTeardown, err := OpenDB(SetProject(testProject), WithBackend(store), SetSnapshotCID(remote.GetSnapshot()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer localTeardown()
+
+	// check a snapshot announcement is applied
+	cid := set(remote, "key C", testDescription)
+	if err := local.ApplyAnnouncement(ctx, announce(Announcement_SNAPSHOT, "", "")); err != nil {
+		t.Fatal(err)
+	}
+	if local.GetCIDs()["key C"] != cid {
+		t.Fatal("announced snapshot was not applied")
+	}
+	if len(local.GetPendingSnapshots()) != 0 {
+		t.Fatal("applied snapshot was queued")
+	}
+
+	// check a delete announcement is applied
+	cid = del("key A")
+	if err := local.ApplyAnnouncement(ctx, announce(Announcement_DELETE, "key A", cid)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := local.GetCIDs()["key A"]; ok {
+		t.Fatal("announced delete was not applied")
+	}
+
+	// check a delete of a different version is a conflict
+	set(local, "key B", "updated locally")
+	cid = del("key B")
+	err = local.ApplyAnnouncement(ctx, announce(Announcement_DELETE, "key B", cid))
+	event, ok := err.(*ConflictEvent)
+	if !ok {
+		t.Fatalf("expected a conflict event, got: %v", err)
+	}
+	if !event.Delete || event.Reason != ErrDeleteConflict {
+		t.Fatalf("unexpected conflict event: %v", event)
+	}
+	if _, ok := local.GetCIDs()["key B"]; !ok {
+		t.Fatal("conflicting delete removed the local Record")
+	}
+
+	// check a snapshot which can't be merged is queued
+	if err := local.ApplyAnnouncement(ctx, announce(Announcement_SNAPSHOT, "", "")); err != nil {
+		t.Fatal(err)
+	}
+	if local.GetPendingSnapshots()["remote peer"] != remote.GetSnapshot() {
+		t.Fatal("conflicting snapshot was not queued")
+	}
+
+	// check a delete which arrives before the Record is queued
+	set(remote, "key D", testDescription)
+	cid = del("key D")
+	if err := local.ApplyAnnouncement(ctx, announce(Announcement_DELETE, "key D", cid)); err != nil {
+		t.Fatal(err)
+	}
+	record, err := local.getRecordFromCID(cid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := local.applyRecord(ctx, "key D", record); err != ErrRecordDeleted {
+		t.Fatalf("deleted Record was applied: %v", err)
+	}
+
+	// check the changes in an announced snapshot are checked key by key
+	signed, signedTeardown, err := OpenDB(SetProject(testProject), WithBackend(store), SetSnapshotCID(remote.GetSnapshot()), WithRequireSigned())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer signedTeardown()
+	cid = set(remote, "key E", testDescription)
+	del("key C")
+	unsignedRecord, err := NewRecord(SetAlias("unsigned"), SetDescription(testDescription))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsignedData, err := json.Marshal(unsignedRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsignedCID, err := store.DagPut(ctx, unsignedData, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote.Lock()
+	err = remote.linkRecord(ctx, "unsigned", unsignedCID)
+	remote.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := signed.ApplyAnnouncement(ctx, announce(Announcement_SNAPSHOT, "", "")); err != nil {
+		t.Fatal(err)
+	}
+	held := signed.GetCIDs()
+	if held["key E"] != cid {
+		t.Fatal("signed Record in announced snapshot was not applied")
+	}
+	if _, ok := held["key C"]; ok {
+		t.Fatal("Record removed in announced snapshot was not deleted")
+	}
+	if _, ok := held["unsigned"]; ok {
+		t.Fatal("unsigned Record in announced snapshot was applied")
+	}
+	if signed.GetPendingSnapshots()["remote peer"] != remote.GetSnapshot() {
+		t.Fatal("announced snapshot with an unsigned Record was not queued")
+	}
+}
+
+// TestSync will check missing Records are imported
+// from the snapshot of another database.
+func TestSync(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := starkmemory.NewStore()
+	remote, teardown, err := OpenDB(SetProject(testProject), WithBackend(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+	local, localTeardown, err := OpenDB(SetProject(testProject), WithBackend(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer localTeardown()
+
+	// add records to both databases, key C is used by both
+	for db, keys := range map[*Db][]string{remote: {"key A", "key B", "key C"}, local: {"key C"}} {
+		for _, key := range keys {
+			testRecord, err := NewRecord(SetAlias(key), SetDescription(testDescription))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := db.Set(ctx, &KeyRecordPair{Key: key, Record: testRecord}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// check the missing records are imported and the conflict is reported
+	report, err := local.SyncFromSnapshot(ctx, remote.GetSnapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Imported != 2 || len(report.Conflicts) != 1 || report.Conflicts[0].Key != "key C" {
+		t.Fatalf("unexpected sync report: %+v", report)
+	}
+	for _, key := range []string{"key A", "key B"} {
+		if local.GetCIDs()[key] != remote.GetCIDs()[key] {
+			t.Fatalf("%v was not imported", key)
+		}
+	}
+
+	// check a second sync skips the held records
+	report, err = local.SyncFromSnapshot(ctx, remote.GetSnapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Imported != 0 || report.Skipped != 2 {
+		t.Fatalf("unexpected sync report: %+v", report)
+	}
+
+	// check syncing from peers needs PubSub
+	if _, err := local.SyncFromPeers(ctx, time.Second); err != ErrNodeOffline {
+		t.Fatal("in-memory starkDB was allowed to sync from peers")
+	}
+
+	// open a peer which answers sync requests at the remote snapshot, and a late joiner, each with its own peer ID
+	peerStore := &pubsubStore{Store: store, msgs: make(chan icore.PubSubMessage), errs: make(chan error), sent: make(chan []byte, 1), identity: starkmemory.NewStore()}
+	responder, responderTeardown, err := OpenDB(SetProject(testProject), WithBackend(peerStore), SetSnapshotCID(remote.GetSnapshot()), WithAnnouncing(), WithSnapshotInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer responderTeardown()
+	lateStore := &pubsubStore{Store: store, msgs: make(chan icore.PubSubMessage), errs: make(chan error), sent: make(chan []byte, 1), identity: starkmemory.NewStore()}
+	late, lateTeardown, err := OpenDB(SetProject(testProject), WithBackend(lateStore))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lateTeardown()
+
+	// pass the sync request from the late joiner to the peer, and the answer back
+	peerID, err := peer.Decode(peerStore.PrintNodeID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	relayed := make(chan error, 1)
+	go func() {
+		request, err := readAnnouncement(<-lateStore.sent, lateStore.PrintNodeID())
+		if err != nil {
+			relayed <- err
+			return
+		}
+		if request.GetType() != Announcement_SYNC_REQUEST {
+			relayed <- fmt.Errorf("expected a sync request, got: %v", request.GetType())
+			return
+		}
+		if err := responder.ApplyAnnouncement(ctx, request); err != nil {
+			relayed <- err
+			return
+		}
+		lateStore.msgs <- &pubsubMessage{from: peerID, data: <-peerStore.sent}
+		relayed <- nil
+	}()
+
+	// check the late joiner imports the records from the peer's head snapshot
+	report, err = late.SyncFromPeers(ctx, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-relayed; err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Snapshots) != 1 || report.Snapshots[0] != responder.GetSnapshot() || report.Imported != 3 {
+		t.Fatalf("unexpected sync report: %+v", report)
+	}
+	for key, cid := range remote.GetCIDs() {
+		if late.GetCIDs()[key] != cid {
+			t.Fatalf("%v was not imported by the late joiner", key)
+		}
+	}
+}
+
+// TestIPNS will check the database snapshot is published
+// to IPNS and can be opened using the IPNS name.
+func TestIPNS(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, _, err := OpenDB(SetProject(testProject), WithOffline(), WithIPNS(1)); err != ErrOfflineOpt {
+		t.Fatal("offline starkDB was allowed to publish to IPNS")
+	}
+
+	// open a db which publishes every 2 records
+	store := starkmemory.NewStore()
+	publisher, teardown, err := OpenDB(SetProject(testProject), WithBackend(store), WithIPNS(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+	name := publisher.GetIPNSName()
+	if name != "/ipns/"+DefaultIPNSKeyPrefix+testProject {
+		t.Fatalf("unexpected IPNS name: %v", name)
+	}
+	for _, key := range []string{"key A", "key B"} {
+		testRecord, err := NewRecord(SetAlias(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := publisher.Set(ctx, &KeyRecordPair{Key: key, Record: testRecord}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	publisher.ipnsPublishing.Wait()
+
+	// check the published snapshot can be opened
+	follower, followerTeardown, err := OpenDB(SetProject(testProject), WithBackend(store), SetSnapshotCID(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer followerTeardown()
+	if follower.GetSnapshot() != publisher.GetSnapshot() || follower.GetNumEntries() != 2 {
+		t.Fatal("database opened from IPNS name does not match the published snapshot")
+	}
+
+	// check deletes also count towards the publishing interval
+	for _, key := range []string{"key A", "key B"} {
+		if _, err := publisher.Delete(ctx, &Key{Key: key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	publisher.ipnsPublishing.Wait()
+	resolved, err := store.ResolveName(ctx, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publisher.Lock()
+	head := publisher.snapshotCID
+	publisher.Unlock()
+	if resolved != head {
+		t.Fatal("snapshot was not published after the deletes")
+	}
+}
+
+// TestMirror will check a database can be mirrored
+// read-only using an IPNS name.
+func TestMirror(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, _, err := OpenDB(SetProject(testProject), WithInMemory(), WithNoPinning(), WithMirror("/ipns/test")); err != ErrMirrorOpt {
+		t.Fatal("mirror was allowed without pinning")
+	}
+
+	// add a record with linked content to a source db and publish it
+	store := starkmemory.NewStore()
+	source, teardown, err := OpenDB(SetProject(testProject), WithBackend(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+	linkedCID, err := store.DagPut(ctx, []byte(`{"sample": "linked content"}`), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := func(key string) {
+		testRecord, err := NewRecord(SetAlias(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		testRecord.LinkedSamples = map[string]string{"sample": linkedCID}
+		if _, err := source.Set(ctx, &KeyRecordPair{Key: key, Record: testRecord}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	set("key A")
+	name, err := source.PublishIPNS(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// check the mirror follows the source
+	mirror, mirrorTeardown, err := OpenDB(SetProject(testProject), WithBackend(store), WithMirror(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mirrorTeardown()
+	head, err := mirror.Mirror(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head != source.GetSnapshot() || mirror.GetSnapshot() != head {
+		t.Fatal("mirror did not follow the source snapshot")
+	}
+	set("key B")
+	if _, err := source.PublishIPNS(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mirror.Mirror(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if mirror.GetNumEntries() != 2 {
+		t.Fatal("mirror did not pick up the new Record")
+	}
+	if _, err := mirror.Get(ctx, &Key{Key: "key B"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// check the mirror is read-only
+	testRecord, err := NewRecord(SetAlias("key C"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mirror.Set(ctx, &KeyRecordPair{Key: "key C", Record: testRecord}); err != ErrReadOnly {
+		t.Fatal("mirror accepted a write")
+	}
+
+	// check an encrypted project can be mirrored without the password
+	if err := os.Setenv("STARK_DB_PASSWORD", "dummy password"); err != nil {
+		t.Fatal(err)
+	}
+	encryptedStore := starkmemory.NewStore()
+	encrypted, encryptedTeardown, err := OpenDB(SetProject(testProject), WithBackend(encryptedStore), WithEncryption())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer encryptedTeardown()
+	testRecord, err = NewRecord(SetAlias("key A"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := encrypted.Set(ctx, &KeyRecordPair{Key: "key A", Record: testRecord}); err != nil {
+		t.Fatal(err)
+	}
+	recordCID := encrypted.GetCIDs()["key A"]
+	for _, cid := range []string{recordCID, encrypted.kdfID} {
+		if err := encryptedStore.Unpin(ctx, cid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	encryptedMirror, encryptedMirrorTeardown, err := OpenDB(SetProject(testProject), WithBackend(encryptedStore), WithMirror(encrypted.GetSnapshot()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer encryptedMirrorTeardown()
+	if _, err := encryptedMirror.Mirror(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := encryptedStore.Unpin(ctx, recordCID); err != nil {
+		t.Fatalf("encrypted record was not mirrored: %v", err)
+	}
+	if err := encryptedStore.Unpin(ctx, encrypted.kdfID); err != nil {
+		t.Fatalf("project metadata was not mirrored: %v", err)
+	}
+}
+
+// TestShardedSnapshot will check a project with enough
+// Records to shard the snapshot can be reopened.
+func TestShardedSnapshot(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	numRecords := 500
+	store := starkmemory.NewStore()
+	starkdb, teardown, err := OpenDB(SetProject(testProject), WithBackend(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < numRecords; i++ {
+		key := fmt.Sprintf("key %d", i)
+		testRecord, err := NewRecord(SetAlias(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := starkdb.Set(ctx, &KeyRecordPair{Key: key, Record: testRecord}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := starkdb.Delete(ctx, &Key{Key: "key 0"}); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := starkdb.GetSnapshot()
+	if err := teardown(); err != nil {
+		t.Fatal(err)
+	}
+
+	// reopen the snapshot and check the Records
+	reopened, reopenedTeardown, err := OpenDB(SetProject(testProject), WithBackend(store), SetSnapshotCID(snapshot))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopenedTeardown()
+	if reopened.GetNumEntries() != numRecords-1 {
+		t.Fatalf("expected %d Records in the reopened snapshot, got %d", numRecords-1, reopened.GetNumEntries())
+	}
+	if _, err := reopened.Get(ctx, &Key{Key: fmt.Sprintf("key %d", numRecords-1)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reopened.Get(ctx, &Key{Key: "key 0"}); err == nil {
+		t.Fatal("deleted Record was found in the reopened snapshot")
+	}
+}
+
+// TestSetBatch will check a batch of Records is added
+// in a single snapshot update, or not at all.
+func TestSetBatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	starkdb, teardown, err := OpenDB(SetProject(testProject), WithInMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+	if _, err := starkdb.SetBatch(ctx, nil); err != ErrEmptyBatch {
+		t.Fatal("empty batch was accepted")
+	}
+	newBatch := func(keys ...string) []*KeyRecordPair {
+		batch := []*KeyRecordPair{}
+		for _, key := range keys {
+			testRecord, err := NewRecord(SetAlias(key))
+			if err != nil {
+				t.Fatal(err)
+			}
+			batch = append(batch, &KeyRecordPair{Key: key, Record: testRecord})
+		}
+		return batch
+	}
+
+	// add a batch and check only one snapshot was taken
+	starkdb.Lock()
+	initialSnapshot := starkdb.snapshotCID
+	starkdb.Unlock()
+	response, err := starkdb.SetBatch(ctx, newBatch("key A", "key B", "key C"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if starkdb.GetNumEntries() != 3 || response.GetSnapshotCID() != starkdb.GetSnapshot() {
+		t.Fatal("batch was not added to the database")
+	}
+	if response.GetPairs()[1].GetRecord().GetPreviousCID() != starkdb.GetCIDs()["key B"] {
+		t.Fatal("batch response does not match the database")
+	}
+	iter := starkdb.Snapshots()
+	if !iter.Next() || iter.Snapshot().Parent != initialSnapshot {
+		t.Fatal("batch did not produce a single snapshot update")
+	}
+
+	// check a batch with a failing entry is rejected
+	batchSnapshot := starkdb.GetSnapshot()
+	_, err = starkdb.SetBatch(ctx, newBatch("key D", "key A"))
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != 1 || !errors.Is(err, ErrAttemptedOverwrite) {
+		t.Fatalf("expected batch to be rejected at entry 1, got: %v", err)
+	}
+	if _, err := starkdb.SetBatch(ctx, newBatch("key D", "key D")); !errors.Is(err, ErrBatchDuplicate) {
+		t.Fatal("batch with a duplicate key was accepted")
+	}
+	if starkdb.GetSnapshot() != batchSnapshot || starkdb.GetNumEntries() != 3 {
+		t.Fatal("rejected batch changed the database")
+	}
+}
+
+// TestFieldEncryption will check encrypted Records don't
+// leak their fields and round trip through Get and the
+// announcements used by Listen.
+func TestFieldEncryption(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := os.Setenv("STARK_DB_PASSWORD", "dummy password"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := OpenDB(SetProject(testProject), WithInMemory(), WithEncryption(), WithClearFields("not a field")); err == nil {
+		t.Fatal("opened a db with an unknown clear field")
+	}
+	store := starkmemory.NewStore()
+	starkdb, teardown, err := OpenDB(SetProject(testProject), WithBackend(store), WithEncryption(), WithClearFields(FieldAlias, FieldBarcodes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	// set a Record with sensitive fields
+	testRecord, err := NewRecord(SetAlias(testKey), SetDescription("patient sample"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testRecord.LocalSequencerOutputDir = "/data/patient"
+	testRecord.Barcodes["library"] = 12
+	if err := testRecord.LinkSample(uuid.New(), "patient sample location"); err != nil {
+		t.Fatal(err)
+	}
+	original := proto.Clone(testRecord).(*Record)
+	response, err := starkdb.Set(ctx, &KeyRecordPair{Key: testKey, Record: testRecord})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cid := response.GetRecord().GetPreviousCID()
+
+	// check the stored Record only has the clear fields readable
+	node, err := store.DagGet(ctx, cid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := node.(*cbor.Node).MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, leak := range []string{"patient", original.GetUuid(), "record created."} {
+		if strings.Contains(string(stored), leak) {
+			t.Fatalf("stored Record contains plaintext: %v", leak)
+		}
+	}
+	if !strings.Contains(string(stored), testKey) || !strings.Contains(string(stored), "library") {
+		t.Fatal("stored Record is missing the clear fields")
+	}
+
+	// check the Record round trips through Get
+	retrieved, err := starkdb.Get(ctx, &Key{Key: testKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(record *Record) {
+		if record.GetUuid() != original.GetUuid() || record.GetDescription() != original.GetDescription() || record.GetLocalSequencerOutputDir() != original.GetLocalSequencerOutputDir() {
+			t.Fatal("decrypted Record fields do not match the original")
+		}
+		if !proto.Equal(&Record{LinkedSamples: record.GetLinkedSamples(), Barcodes: record.GetBarcodes()}, &Record{LinkedSamples: original.GetLinkedSamples(), Barcodes: original.GetBarcodes()}) {
+			t.Fatal("decrypted Record maps do not match the original")
+		}
+		for i, comment := range original.GetHistory() {
+			if record.GetHistory()[i].GetText() != comment.GetText() {
+				t.Fatal("decrypted Record history does not match the original")
+			}
+		}
+	}
+	check(retrieved.GetRecord())
+
+	// check the Record round trips through an announcement
+	inline := proto.Clone(response.GetRecord()).(*Record)
+	inline.PreviousCID = ""
+	announced, err := starkdb.getAnnouncedRecord(starkdb.newAnnouncement(Announcement_SET, testKey, cid, inline))
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(announced)
+}
+
+// TestKeyDerivation will check the cipher key is derived
+// from project key derivation parameters, and that Records
+// encrypted with the MD5 derived key are still readable.
+func TestKeyDerivation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := os.Setenv("STARK_DB_PASSWORD", "dummy password"); err != nil {
+		t.Fatal(err)
+	}
+	store := starkmemory.NewStore()
+	starkdb, teardown, err := OpenDB(SetProject(testProject), WithBackend(store), WithEncryption())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(starkdb.kdfID) == 0 {
+		t.Fatal("encrypted db has no key derivation parameters")
+	}
+	legacyKey, err := starkcrypto.Password2cipherkey("dummy password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(starkdb.cipherKey) == string(legacyKey) {
+		t.Fatal("encrypted db is using the MD5 derived key")
+	}
+	kdfID, cipherKey := starkdb.kdfID, starkdb.cipherKey
+
+	// check new Records record the key derivation parameters
+	testRecord, err := NewRecord(SetAlias(testKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testUUID := testRecord.GetUuid()
+	response, err := starkdb.Set(ctx, &KeyRecordPair{Key: testKey, Record: testRecord})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.GetRecord().GetKdfID() != kdfID {
+		t.Fatal("encrypted Record does not have the kdfID set")
+	}
+
+	// add a Record encrypted by an earlier version
+	legacyRecord, err := NewRecord(SetAlias("legacy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacyUUID := legacyRecord.GetUuid()
+	if legacyRecord.Uuid, err = starkcrypto.Encrypt(legacyUUID, legacyKey); err != nil {
+		t.Fatal(err)
+	}
+	legacyRecord.Encrypted = true
+	data, err := json.Marshal(legacyRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cid, err := store.DagPut(ctx, data, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	starkdb.Lock()
+	err = starkdb.linkRecord(ctx, "legacy", cid)
+	starkdb.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot := starkdb.GetSnapshot()
+	if err := teardown(); err != nil {
+		t.Fatal(err)
+	}
+
+	// reopen and check the same key is derived and both Records are readable
+	starkdb, teardown, err = OpenDB(SetProject(testProject), WithBackend(store), SetSnapshotCID(snapshot), WithEncryption())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+	if starkdb.kdfID != kdfID || string(starkdb.cipherKey) != string(cipherKey) {
+		t.Fatal("reopened db did not derive the same cipher key")
+	}
+	if starkdb.GetNumEntries() != 2 {
+		t.Fatalf("expected 2 entries, got %d", starkdb.GetNumEntries())
+	}
+	retrieved, err := starkdb.Get(ctx, &Key{Key: testKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retrieved.GetRecord().GetUuid() != testUUID || len(retrieved.GetRecord().GetKdfID()) != 0 {
+		t.Fatal("could not decrypt Record")
+	}
+	retrieved, err = starkdb.Get(ctx, &Key{Key: "legacy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retrieved.GetRecord().GetUuid() != legacyUUID {
+		t.Fatal("could not decrypt legacy Record")
+	}
+
+	// check the project metadata can't be used as a key
+	if _, err := starkdb.Set(ctx, &KeyRecordPair{Key: ProjectMetaLink, Record: testRecord}); err == nil {
+		t.Fatal("set a Record using the project metadata link")
+	}
+
+	// check a Record can't use key derivation parameters from outside the snapshot lineage
+	params, err := starkcrypto.NewKDFParams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	metaData, err := json.Marshal(&projectMeta{Project: testProject, KDF: params})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rogueCID, err := store.DagPut(ctx, metaData, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rogueRecord, err := NewRecord(SetAlias("rogue"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rogueRecord.Encrypt(cipherKey, starkdb.clearFields...); err != nil {
+		t.Fatal(err)
+	}
+	rogueRecord.KdfID = rogueCID
+	if data, err = json.Marshal(rogueRecord); err != nil {
+		t.Fatal(err)
+	}
+	if cid, err = store.DagPut(ctx, data, false); err != nil {
+		t.Fatal(err)
+	}
+	starkdb.Lock()
+	err = starkdb.linkRecord(ctx, "rogue", cid)
+	starkdb.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := starkdb.Get(ctx, &Key{Key: "rogue"}); !errors.Is(err, ErrProjectMeta) {
+		t.Fatalf("expected %v, got %v", ErrProjectMeta, err)
+	}
+}
+
+// TestRotateKey will check the Records in a project can be
+// re-encrypted with a new password, and that an interrupted
+// rotation can be resumed.
+func TestRotateKey(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := os.Setenv("STARK_DB_PASSWORD", "old password"); err != nil {
+		t.Fatal(err)
+	}
+	store := starkmemory.NewStore()
+	starkdb, teardown, err := OpenDB(SetProject(testProject), WithBackend(store), WithEncryption())
+	if err != nil {
+		t.Fatal(err)
+	}
+	uuids := make(map[string]string)
+	for _, key := range []string{"a", "b"} {
+		testRecord, err := NewRecord(SetAlias(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		uuids[key] = testRecord.GetUuid()
+		if _, err := starkdb.Set(ctx, &KeyRecordPair{Key: key, Record: testRecord}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// add a Record encrypted with a different password, which will interrupt the rotation
+	otherKey, err := starkcrypto.Password2cipherkey("other password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherRecord, err := NewRecord(SetAlias("c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := otherRecord.Encrypt(otherKey); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(otherRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cid, err := store.DagPut(ctx, data, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	starkdb.Lock()
+	err = starkdb.linkRecord(ctx, "c", cid)
+	starkdb.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := starkdb.RotateKey(ctx, "old password", "new password")
+	if err == nil || !strings.Contains(err.Error(), ErrCipherPasswordMismatch.Error()) {
+		t.Fatalf("expected a password mismatch, got: %v", err)
+	}
+	if report.Rekeyed != 2 {
+		t.Fatalf("expected 2 records to be re-encrypted before the interruption, got %d", report.Rekeyed)
+	}
+
+	// check every resumed Record is checked against the new password
+	rotatingCID, err := starkdb.getProjectMetaCID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rekeyedCID := starkdb.GetCIDs()["b"]
+	wrongRecord, err := NewRecord(SetAlias("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wrongRecord.Encrypt(otherKey); err != nil {
+		t.Fatal(err)
+	}
+	wrongRecord.KdfID = rotatingCID
+	if data, err = json.Marshal(wrongRecord); err != nil {
+		t.Fatal(err)
+	}
+	if cid, err = store.DagPut(ctx, data, false); err != nil {
+		t.Fatal(err)
+	}
+	relink := func(key, cid string) {
+		starkdb.Lock()
+		err := starkdb.linkRecord(ctx, key, cid)
+		starkdb.Unlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	relink("b", cid)
+	if _, err := starkdb.RotateKey(ctx, "old password", "new password"); err == nil || !strings.Contains(err.Error(), "(b)") {
+		t.Fatalf("expected a password mismatch for the second resumed record, got: %v", err)
+	}
+	relink("b", rekeyedCID)
+
+	// check encrypted writes are refused until the rotation is finished, including after reopening
+	refused, err := NewRecord(SetAlias("d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := starkdb.Set(ctx, &KeyRecordPair{Key: "d", Record: refused}); err != ErrRotationUnfinished {
+		t.Fatalf("expected %v, got: %v", ErrRotationUnfinished, err)
+	}
+	reopened, reopenedTeardown, err := OpenDB(SetProject(testProject), WithBackend(store), SetSnapshotCID(starkdb.GetSnapshot()), WithEncryption())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reopened.Set(ctx, &KeyRecordPair{Key: "d", Record: refused}); err != ErrRotationUnfinished {
+		t.Fatalf("expected %v after reopening, got: %v", ErrRotationUnfinished, err)
+	}
+	if err := reopenedTeardown(); err != nil {
+		t.Fatal(err)
+	}
+
+	// resume the rotation
+	if _, err := starkdb.Delete(ctx, &Key{Key: "c"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := starkdb.RotateKey(ctx, "old password", "wrong password"); err == nil {
+		t.Fatal("resumed a rotation with a different password")
+	}
+	if report, err = starkdb.RotateKey(ctx, "old password", "new password"); err != nil {
+		t.Fatal(err)
+	}
+	if report.Rekeyed != 0 || report.Resumed != 2 {
+		t.Fatalf("expected 2 resumed records, got %d (%d re-encrypted)", report.Resumed, report.Rekeyed)
+	}
+
+	// check the versions encrypted with the old password can still be read this session
+	versions, err := starkdb.GetHistory("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || versions[1].GetRecord().GetEncrypted() || versions[1].GetRecord().GetUuid() != uuids["a"] {
+		t.Fatal("could not decrypt the version from before the rotation")
+	}
+	snapshot := starkdb.GetSnapshot()
+	if err := teardown(); err != nil {
+		t.Fatal(err)
+	}
+
+	// check the old password no longer works and the new one does
+	starkdb, teardown, err = OpenDB(SetProject(testProject), WithBackend(store), SetSnapshotCID(snapshot), WithEncryption())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := starkdb.Get(ctx, &Key{Key: "a"}); err == nil {
+		t.Fatal("decrypted a re-encrypted Record with the old password")
+	}
+	if err := teardown(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Setenv("STARK_DB_PASSWORD", "new password"); err != nil {
+		t.Fatal(err)
+	}
+	starkdb, teardown, err = OpenDB(SetProject(testProject), WithBackend(store), SetSnapshotCID(snapshot), WithEncryption())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+	for key, uuid := range uuids {
+		response, err := starkdb.Get(ctx, &Key{Key: key})
+		if err != nil {
+			t.Fatal(err)
+		}
+		history := response.GetRecord().GetHistory()
+		if response.GetRecord().GetUuid() != uuid || !strings.HasPrefix(history[len(history)-1].GetText(), "RotateKey") {
+			t.Fatalf("could not decrypt re-encrypted Record: %v", key)
+		}
+	}
+
+	// check the versions encrypted with the old password are returned encrypted
+	versions, err = starkdb.GetHistory("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || versions[0].GetRecord().GetEncrypted() || !versions[1].GetRecord().GetEncrypted() {
+		t.Fatal("history did not report the version encrypted with the old password")
+	}
+
+	// check the re-encrypted Records are announced
+	psStore := &pubsubStore{Store: store, msgs: make(chan icore.PubSubMessage), errs: make(chan error), sent: make(chan []byte, 8)}
+	announcer, announcerTeardown, err := OpenDB(SetProject(testProject), WithBackend(psStore), SetSnapshotCID(starkdb.GetSnapshot()), WithEncryption(), WithAnnouncing(), WithSnapshotInterval(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer announcerTeardown()
+	if _, err := announcer.RotateKey(ctx, "new password", "newer password"); err != nil {
+		t.Fatal(err)
+	}
+	if len(psStore.sent) != len(uuids) {
+		t.Fatalf("expected %d announcements, got %d", len(uuids), len(psStore.sent))
+	}
+	for i := 0; i < len(uuids); i++ {
+		ann, err := readAnnouncement(<-psStore.sent, psStore.PrintNodeID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ann.GetType() != Announcement_SET || ann.GetCid() != announcer.GetCIDs()[ann.GetKey()] {
+			t.Fatalf("re-encrypted Record was not announced: %v", ann.GetKey())
+		}
+	}
+}
+
+// TestRecipients will check Records can be shared with
+// another database using its recipient key.
+func TestRecipients(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := os.Setenv("STARK_DB_PASSWORD", "dummy password"); err != nil {
+		t.Fatal(err)
+	}
+	storeA, storeB := starkmemory.NewStore(), starkmemory.NewStore()
+	dbA, teardownA, err := OpenDB(SetProject(testProject), WithBackend(storeA), WithEncryption())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardownA()
+	dbB, teardownB, err := OpenDB(SetProject(testProject), WithBackend(storeB))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardownB()
+	keyA, err := dbA.RecipientKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyB, err := dbB.RecipientKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyA == keyB {
+		t.Fatal("databases have the same recipient key")
+	}
+
+	// pass is used to pass the current version of a Record from A to B
+	pass := func(key string) {
+		node, err := storeA.DagGet(ctx, dbA.cidLookup[key])
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := node.(*cbor.Node).MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cid, err := storeB.DagPut(ctx, data, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dbB.Lock()
+		err = dbB.linkRecord(ctx, key, cid)
+		dbB.Unlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// share one of two Records with B
+	uuids := make(map[string]string)
+	for _, key := range []string{"shared", "private"} {
+		testRecord, err := NewRecord(SetAlias(key), SetDescription("run metadata"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		uuids[key] = testRecord.GetUuid()
+		if _, err := dbA.Set(ctx, &KeyRecordPair{Key: key, Record: testRecord}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := dbA.AddRecipient(ctx, "shared", "not a key"); err == nil {
+		t.Fatal("added an invalid recipient key")
+	}
+	response, err := dbA.AddRecipient(ctx, "shared", keyB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.GetRecord().GetRecipients()) != 2 {
+		t.Fatal("shared Record does not have both recipients")
+	}
+	if _, err := dbA.AddRecipient(ctx, "shared", keyB); err != ErrRecipientExists {
+		t.Fatal("added the same recipient twice")
+	}
+	pass("shared")
+	pass("private")
+	for _, db := range []*Db{dbA, dbB} {
+		retrieved, err := db.Get(ctx, &Key{Key: "shared"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if retrieved.GetRecord().GetUuid() != uuids["shared"] || retrieved.GetRecord().GetDescription() != "run metadata" {
+			t.Fatal("could not decrypt shared Record")
+		}
+	}
+	if _, err := dbB.Get(ctx, &Key{Key: "private"}); err == nil {
+		t.Fatal("decrypted a Record which was not shared")
+	}
+
+	// check the recipients are kept when the Record is updated
+	retrieved, err := dbA.Get(ctx, &Key{Key: "shared"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	retrieved.GetRecord().Description = "updated run metadata"
+	retrieved.GetRecord().AddComment("description updated.")
+	if _, err := dbA.Set(ctx, &KeyRecordPair{Key: "shared", Record: retrieved.GetRecord()}); err != nil {
+		t.Fatal(err)
+	}
+	pass("shared")
+	if retrieved, err = dbB.Get(ctx, &Key{Key: "shared"}); err != nil {
+		t.Fatal(err)
+	}
+	if retrieved.GetRecord().GetDescription() != "updated run metadata" {
+		t.Fatal("could not decrypt updated shared Record")
+	}
+
+	// stop sharing the Record with B
+	if _, err := dbA.RemoveRecipient(ctx, "shared", keyA); err != ErrRecipientLocal {
+		t.Fatal("removed the database's own recipient key")
+	}
+	if _, err := dbA.RemoveRecipient(ctx, "shared", keyB); err != nil {
+		t.Fatal(err)
+	}
+	pass("shared")
+	if _, err := dbB.Get(ctx, &Key{Key: "shared"}); err == nil || !strings.Contains(err.Error(), ErrNotRecipient.Error()) {
+		t.Fatalf("expected %v, got: %v", ErrNotRecipient, err)
+	}
+}
+
+// TestSignedRecords checks Records are signed with the
+// node identity and that the signatures and authors are
+// verified when Records are retrieved.
+func TestSignedRecords(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	storeA, storeB, storeC := starkmemory.NewStore(), starkmemory.NewStore(), starkmemory.NewStore()
+	dbA, teardownA, err := OpenDB(SetProject(testProject), WithBackend(storeA))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardownA()
+	dbB, teardownB, err := OpenDB(SetProject(testProject), WithBackend(storeB), WithRequireSigned())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardownB()
+	if _, _, err := OpenDB(SetProject(testProject), WithInMemory(), WithRequireSigned("not a peer ID")); err == nil {
+		t.Fatal("opened database with an invalid trusted author")
+	}
+
+	// get the peer IDs for the stores
+	authors := make([]string, 3)
+	for i, store := range []*starkmemory.Store{storeA, storeB, storeC} {
+		privKey, err := store.PrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := peer.IDFromPrivateKey(privKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		authors[i] = id.Pretty()
+	}
+
+	// C only trusts B
+	dbC, teardownC, err := OpenDB(SetProject(testProject), WithBackend(storeC), WithRequireSigned(authors[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardownC()
+
+	// the memory stores are identified by the peer IDs of their keys
+	if storeA.PrintNodeID() != authors[0] {
+		t.Fatalf("expected node ID %v, got %v", authors[0], storeA.PrintNodeID())
+	}
+
+	// apply is used to add Record data to a store and apply it to a database
+	apply := func(db *Db, store *starkmemory.Store, key string, data []byte) error {
+		cid, err := store.DagPut(ctx, data, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = db.applyRecord(ctx, key, &Record{PreviousCID: cid})
+		return err
+	}
+
+	// add a Record to A and check it was signed
+	testRecord, err := NewRecord(SetAlias(testKey), SetDescription(testDescription))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dbA.Set(ctx, &KeyRecordPair{Key: testKey, Record: testRecord}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := dbA.Get(ctx, &Key{Key: testKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetRecord().GetAuthor() != authors[0] || len(resp.GetRecord().GetSignature()) == 0 {
+		t.Fatalf("record not signed by A: %v", resp.GetRecord().GetAuthor())
+	}
+	stored, err := dbA.getEncryptedRecord(dbA.cidLookup[testKey])
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedData, err := json.Marshal(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the signed Record is accepted by B but not by C
+	if err := apply(dbB, storeB, testKey, signedData); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = dbB.Get(ctx, &Key{Key: testKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetRecord().GetAuthor() != authors[0] {
+		t.Fatalf("expected author %v, got %v", authors[0], resp.GetRecord().GetAuthor())
+	}
+	if err := apply(dbC, storeC, testKey, signedData); err != ErrUntrustedAuthor {
+		t.Fatalf("expected %v, got %v", ErrUntrustedAuthor, err)
+	}
+
+	// C accepts its own Records
+	ownRecord, err := NewRecord(SetAlias("own"), SetDescription(testDescription))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dbC.Set(ctx, &KeyRecordPair{Key: "own", Record: ownRecord}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dbC.Get(ctx, &Key{Key: "own"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a tampered Record is rejected, even when signatures aren't required
+	stored.Description = "tampered"
+	tamperedData, err := json.Marshal(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := apply(dbA, storeA, "tampered", tamperedData); err != ErrSignature {
+		t.Fatalf("expected %v, got %v", ErrSignature, err)
+	}
+
+	// a tampered Record already held is rejected by Get
+	tamperedCID, err := storeA.DagPut(ctx, tamperedData, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbA.Lock()
+	err = dbA.linkRecord(ctx, "tampered", tamperedCID)
+	dbA.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dbA.Get(ctx, &Key{Key: "tampered"}); err != ErrSignature {
+		t.Fatalf("expected %v, got %v", ErrSignature, err)
+	}
+
+	// a tampered Record included in an Announcement isn't stored
+	stored.Description = "tampered announcement"
+	tamperedData, err = json.Marshal(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tamperedCID, err = storeC.DagPut(ctx, tamperedData, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ann := &Announcement{Type: Announcement_SET, Key: "announced", Cid: tamperedCID, Record: stored, Sender: authors[1]}
+	if err := dbA.ApplyAnnouncement(ctx, ann); err != ErrSignature {
+		t.Fatalf("expected %v, got %v", ErrSignature, err)
+	}
+	if _, err := storeA.DagGet(ctx, tamperedCID); err == nil {
+		t.Fatal("tampered Record was added to the store")
+	}
+
+	// an unsigned Record is only rejected when signatures are required
+	unsignedRecord, err := NewRecord(SetAlias("unsigned"), SetDescription(testDescription))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsignedData, err := json.Marshal(unsignedRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := apply(dbA, storeA, "unsigned", unsignedData); err != nil {
+		t.Fatal(err)
+	}
+	if err := apply(dbB, storeB, "unsigned", unsignedData); err != ErrUnsigned {
+		t.Fatalf("expected %v, got %v", ErrUnsigned, err)
+	}
+
+	// unsigned Records already held can't be read when signatures are required
+	cid, err := storeB.DagPut(ctx, unsignedData, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbB.Lock()
+	err = dbB.linkRecord(ctx, "unsigned", cid)
+	dbB.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dbB.Get(ctx, &Key{Key: "unsigned"}); err != ErrUnsigned {
+		t.Fatalf("expected %v, got %v", ErrUnsigned, err)
+	}
+}
+
 // TestEncyption will test the Record encryption.
 func TestEncyption(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())